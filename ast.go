@@ -0,0 +1,216 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+)
+
+// A Location is a point in a ZPL document.
+//
+type Location struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // byte offset within the line, starting at 1
+}
+
+// A Span is the part of a ZPL document from Start up to, but not including,
+// End.
+//
+type Span struct {
+	Start Location
+	End   Location
+}
+
+// Contains reports whether offset lies within the span.
+func (s Span) Contains(offset int) bool {
+	return s.Start.Offset <= offset && offset < s.End.Offset
+}
+
+// A NodeKind identifies the kind of a Node.
+//
+type NodeKind int
+
+const (
+	CommentNode  NodeKind = iota // a line starting with "#"
+	PropertyNode                 // a key = value line
+	SectionNode                  // a section header and everything nested in it
+	BadNode                      // a line with a syntax error
+)
+
+// A Node is a line of a ZPL document, or for a SectionNode, a section header
+// and the nodes nested within it.
+//
+type Node struct {
+	Kind NodeKind
+
+	// Span covers the text of the node without its indentation or line
+	// ending.  The span of a section extends to the end of the last node
+	// nested within it.
+	Span Span
+
+	Key     string // the key of a property or the name of a section
+	KeySpan Span
+
+	Value     string // the value of a property, with any quotes removed
+	ValueSpan Span   // the value as written, including any quotes
+
+	Text string       // the whole text of a comment or bad line
+	Err  *SyntaxError // the error in a bad line
+
+	Children []*Node // the nodes nested within a section
+}
+
+// A Tree is the syntax tree of a ZPL document, for editors and other tools
+// that must relate a document's contents to their exact place in its text.
+//
+type Tree struct {
+	Nodes []*Node // the nodes at the top level
+}
+
+// ParseTree parses the ZPL-encoded data into a syntax tree that keeps every
+// comment, and the span of every key and value.  Blank lines are not part of
+// the tree.  A comment belongs to the same section as the line that follows
+// it, as FormatPreserve indents it, or to the top level at the end of the
+// document.
+//
+// Parsing continues past lines that are not valid ZPL, which appear in the
+// tree as bad nodes at the level of the lines before them, so that a tree is
+// returned for any document.  The error is then an ErrorList of the
+// *SyntaxError of each bad node.
+//
+func ParseTree(src []byte) (*Tree, error) {
+	var (
+		tree     Tree
+		errs     ErrorList
+		open     []*Node // the sections enclosing the current line
+		comments []*Node // comments waiting for the depth of the next line
+		offset   int
+		lineno   int
+	)
+	add := func(node *Node, depth int) {
+		for _, sec := range open[:depth] {
+			sec.Span.End = node.Span.End
+		}
+		if depth == 0 {
+			tree.Nodes = append(tree.Nodes, node)
+		} else {
+			parent := open[depth-1]
+			parent.Children = append(parent.Children, node)
+		}
+	}
+	flush := func(depth int) {
+		for _, comment := range comments {
+			add(comment, depth)
+		}
+		comments = comments[:0]
+	}
+	for offset < len(src) {
+		lineno++
+		start := offset
+		line, next := splitLine(src, start)
+		at := func(i int) Location {
+			return Location{Offset: start + i, Line: lineno, Column: i + 1}
+		}
+		offset = next
+		trimmed := bytes.TrimLeft(line, " \t")
+		indent := len(line) - len(trimmed)
+		end := len(bytes.TrimRight(line, " \t"))
+		if len(trimmed) == 0 {
+			continue
+		} else if trimmed[0] == '#' {
+			comments = append(comments, &Node{
+				Kind: CommentNode,
+				Span: Span{at(indent), at(end)},
+				Text: string(line[indent:end]),
+			})
+			continue
+		}
+		depth, key, value, hasValue, ok := scanLine(line)
+		if !ok || depth > len(open) {
+			err := &SyntaxError{
+				Line: uint64(lineno),
+				msg:  "is neither a comment, a section header, nor a key = value setting.",
+			}
+			if ok {
+				err.msg = "is indented more than its section allows."
+			}
+			errs = append(errs, err)
+			flush(len(open))
+			add(&Node{
+				Kind: BadNode,
+				Span: Span{at(indent), at(end)},
+				Text: string(line[indent:end]),
+				Err:  err,
+			}, len(open))
+			continue
+		}
+		open = open[:depth]
+		flush(depth)
+		node := &Node{
+			Key:     string(key),
+			KeySpan: Span{at(indent), at(indent + len(key))},
+		}
+		if hasValue {
+			node.Kind = PropertyNode
+			node.Span = Span{at(indent), at(len(line))}
+			node.Value = string(value)
+			node.ValueSpan = Span{at(len(line) - len(rawValueText(line))), at(len(line))}
+			add(node, depth)
+		} else {
+			node.Kind = SectionNode
+			node.Span = node.KeySpan
+			add(node, depth)
+			open = append(open, node)
+		}
+	}
+	// Comments at the end of the document belong to no particular section.
+	open = nil
+	flush(0)
+	if errs != nil {
+		return &tree, errs
+	}
+	return &tree, nil
+}
+
+// Return the line of src that starts at offset, without its line ending, and
+// the offset of the following line.  Line endings are recognized as by a
+// Decoder.
+func splitLine(src []byte, offset int) (line []byte, next int) {
+	rest := src[offset:]
+	n := bytes.IndexAny(rest, "\n\r")
+	if n < 0 {
+		return rest, len(src)
+	}
+	next = offset + n + 1
+	if n+1 < len(rest) && rest[n] != rest[n+1] && (rest[n+1] == '\n' || rest[n+1] == '\r') {
+		next++
+	}
+	return rest[:n], next
+}
+
+// NodeAt returns the innermost node whose span contains offset, and the
+// sections that enclose it, outermost first.  It returns nil if no node
+// contains offset.
+//
+func (t *Tree) NodeAt(offset int) (node *Node, path []*Node) {
+	nodes := t.Nodes
+	for {
+		var found *Node
+		for _, n := range nodes {
+			if n.Span.Contains(offset) {
+				found = n
+				break
+			}
+		}
+		if found == nil {
+			return
+		}
+		if node != nil {
+			path = append(path, node)
+		}
+		node, nodes = found, found.Children
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"testing"
+)
+
+func TestParseTree(t *testing.T) {
+	src := "# devices\r\nmain\r\n    type = zmq_queue\r\n\r\n    # the frontend\r\n    frontend\r\n        bind = \"tcp://eth0:5555\"\r\n# end\r\n"
+	tree, err := ParseTree([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Nodes) != 3 {
+		t.Fatalf("expected 3 top-level nodes, got %d", len(tree.Nodes))
+	}
+	text := func(s Span) string { return src[s.Start.Offset:s.End.Offset] }
+	comment, main, end := tree.Nodes[0], tree.Nodes[1], tree.Nodes[2]
+	if comment.Kind != CommentNode || comment.Text != "# devices" || text(comment.Span) != comment.Text {
+		t.Errorf("unexpected first node: %+v", comment)
+	}
+	if end.Kind != CommentNode || end.Span.Start.Line != 8 {
+		t.Errorf("unexpected last node: %+v", end)
+	}
+	if main.Kind != SectionNode || main.Key != "main" || len(main.Children) != 3 {
+		t.Fatalf("unexpected section: %+v", main)
+	}
+	if main.Span.Start.Line != 2 || main.Span.End.Line != 7 || text(main.Span)[len(text(main.Span))-1] != '"' {
+		t.Errorf("unexpected section span: %+v", main.Span)
+	}
+	typ, note, frontend := main.Children[0], main.Children[1], main.Children[2]
+	if typ.Kind != PropertyNode || text(typ.KeySpan) != "type" || text(typ.ValueSpan) != "zmq_queue" {
+		t.Errorf("unexpected property: %+v", typ)
+	}
+	if typ.KeySpan.Start != (Location{Offset: 21, Line: 3, Column: 5}) {
+		t.Errorf("unexpected key location: %+v", typ.KeySpan.Start)
+	}
+	if note.Kind != CommentNode || note.Text != "# the frontend" {
+		t.Errorf("unexpected comment: %+v", note)
+	}
+	bind := frontend.Children[0]
+	if bind.Value != "tcp://eth0:5555" || text(bind.ValueSpan) != `"tcp://eth0:5555"` {
+		t.Errorf("unexpected value: %q at %q", bind.Value, text(bind.ValueSpan))
+	}
+	if bind.ValueSpan.Start.Column != 16 || bind.ValueSpan.End.Column != 33 {
+		t.Errorf("unexpected value span: %+v", bind.ValueSpan)
+	}
+	node, path := tree.NodeAt(bind.ValueSpan.Start.Offset + 3)
+	if node != bind || len(path) != 2 || path[0] != main || path[1] != frontend {
+		t.Errorf("NodeAt returned %+v in %v", node, path)
+	}
+	if node, _ := tree.NodeAt(len(src)); node != nil {
+		t.Errorf("expected no node at the end, got %+v", node)
+	}
+}
+
+func TestParseTree_Errors(t *testing.T) {
+	src := "a\n    b = 1\n        c = 2\n\tbad\nd = 3\n"
+	tree, err := ParseTree([]byte(src))
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+	if errs[0].(*SyntaxError).Line != 3 || errs[1].(*SyntaxError).Line != 4 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	if len(tree.Nodes) != 2 || len(tree.Nodes[0].Children) != 3 {
+		t.Fatalf("unexpected tree: %+v", tree.Nodes)
+	}
+	bad := tree.Nodes[0].Children[2]
+	if bad.Kind != BadNode || bad.Text != "bad" || bad.Err != errs[1] || bad.Span.Start.Column != 2 {
+		t.Errorf("unexpected bad node: %+v", bad)
+	}
+	if tree.Nodes[1].Key != "d" || tree.Nodes[1].Value != "3" {
+		t.Errorf("unexpected last node: %+v", tree.Nodes[1])
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"strings"
+)
+
+// UnmarshalAt parses the ZPL-encoded data and stores the contents of the
+// section at path, a "/"-separated list of section names such as
+// "main/frontend", in the value pointed to by v.  Everything outside that
+// section is skipped, so a component can read its own part of a large shared
+// configuration.  If the section appears more than once, its contents are
+// merged as if it had appeared once.  If it does not appear at all, v is
+// left unchanged.
+//
+// See the documentation for Unmarshal for details about the conversion of ZPL
+// into a Go value.
+//
+func UnmarshalAt(src []byte, path string, v interface{}) error {
+	d := &Decoder{buffer: src, eof: true}
+	return d.DecodeAt(path, v)
+}
+
+// DecodeAt reads the next ZPL-encoded document from its input and stores the
+// contents of the section at path in the value pointed to by v.
+//
+// See the documentation for UnmarshalAt for details.
+//
+func (d *Decoder) DecodeAt(path string, v interface{}) error {
+	d.at = nil
+	if path != "" {
+		d.at = strings.Split(path, "/")
+	}
+	defer func() { d.at = nil }()
+	return d.Decode(v)
+}
+
+// A pathFilter passes on only the events within the section at path.
+type pathFilter struct {
+	Sink
+	path    []string
+	depth   int // depth of the current section
+	matched int // number of enclosing sections that match path
+}
+
+func (f *pathFilter) Consume(e *Event) error {
+	inside := f.matched == len(f.path)
+	switch e.Type {
+	case StartSection:
+		if !inside && f.depth == f.matched && e.Name == f.path[f.matched] {
+			f.matched++
+			f.depth++
+			return nil
+		}
+		f.depth++
+	case EndSection:
+		if f.depth == f.matched {
+			f.matched--
+			f.depth--
+			return nil
+		}
+		f.depth--
+	}
+	if inside {
+		return f.Sink.Consume(e)
+	}
+	return nil
+}
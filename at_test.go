@@ -0,0 +1,75 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalAt(t *testing.T) {
+	var frontend struct {
+		Bind   string            `zpl:"bind"`
+		Option map[string]string `zpl:"option"`
+	}
+	if err := UnmarshalAt(raw0, "main/frontend", &frontend); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	if frontend.Bind != "tcp://eth0:5555" || frontend.Option["hwm"] != "1000" {
+		t.Errorf("main/frontend = %+v", frontend)
+	}
+	backend := make(map[string][]string)
+	if err := UnmarshalAt(raw0, "main/backend", backend); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	if !reflect.DeepEqual(backend["bind"], []string{"tcp://eth0:5556", "inproc://device"}) || len(backend) != 1 {
+		t.Errorf("main/backend = %v", backend)
+	}
+	missing := map[string]string{"kept": "yes"}
+	if err := UnmarshalAt(raw0, "main/missing", missing); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	if !reflect.DeepEqual(missing, map[string]string{"kept": "yes"}) {
+		t.Errorf("missing = %v", missing)
+	}
+}
+
+func TestUnmarshalAt_Errors(t *testing.T) {
+	var v map[string]bool
+	err := UnmarshalAt(raw0, "main/frontend/option", &v)
+	if e, ok := err.(*UnmarshalTypeError); !ok {
+		t.Fatalf("expected *UnmarshalTypeError, got %T: %v", err, err)
+	} else if e.Path != "main/frontend/option/hwm" || e.Line != 17 {
+		t.Errorf("error at %s line %d", e.Path, e.Line)
+	}
+}
+
+func TestMarshalAt(t *testing.T) {
+	frontend := map[string]interface{}{"bind": "tcp://eth0:5555"}
+	backend := map[string]interface{}{"bind": "tcp://eth0:5556"}
+	a, err := MarshalAt("devices/main/frontend", frontend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "devices\n    main\n        frontend\n            bind = tcp://eth0:5555\n"
+	if string(a) != expect {
+		t.Errorf("unexpected result:\n%s", a)
+	}
+	b, err := MarshalAt("devices/main/backend", backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var whole Section
+	if err := Unmarshal(append(a, b...), &whole); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	main := whole.Section("devices").Section("main")
+	if main.Section("frontend").Value("bind") != "tcp://eth0:5555" || main.Section("backend").Value("bind") != "tcp://eth0:5556" {
+		t.Errorf("unexpected merge:\n%s", whole.String())
+	}
+	if c, err := MarshalAt("", frontend); err != nil || string(c) != "bind = tcp://eth0:5555\n" {
+		t.Errorf("unexpected result at top level: %q, %v", c, err)
+	}
+}
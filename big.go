@@ -0,0 +1,68 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"math/big"
+	"reflect"
+)
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+)
+
+// Report whether typ is one of the arbitrary precision number types of
+// package math/big, which are encoded as single values rather than sections.
+func isBigType(typ reflect.Type) bool {
+	return typ == bigIntType || typ == bigFloatType || typ == bigRatType
+}
+
+// Format a value for which isBigType is true: an integer in decimal, a float
+// with as many digits as necessary to represent it exactly, and a rational
+// number as a fraction, or as an integer if its denominator is 1.
+func formatBig(value reflect.Value) string {
+	ptr := reflect.New(value.Type())
+	ptr.Elem().Set(value)
+	switch x := ptr.Interface().(type) {
+	case *big.Int:
+		return x.String()
+	case *big.Float:
+		return x.Text('g', -1)
+	case *big.Rat:
+		return x.RatString()
+	}
+	panic("zpl: program error: unsupported big type??")
+}
+
+// Parse value into a new value of typ, for which isBigType is true.  Integers
+// are parsed in base, as returned by intBase, so that they may have a prefix
+// such as "0x" only if the Decoder allows it.  Rationals may be written either
+// as fractions such as "3/4" or as decimals.  Floats get enough precision for
+// every digit written.
+func parseBig(typ reflect.Type, value string, base int) (reflect.Value, error) {
+	var (
+		x  interface{}
+		ok = true
+	)
+	switch typ {
+	case bigIntType:
+		x, ok = new(big.Int).SetString(value, base)
+	case bigFloatType:
+		prec := uint(len(value)) * 4
+		if prec < 64 {
+			prec = 64
+		}
+		f, _, err := big.ParseFloat(value, 10, prec, big.ToNearestEven)
+		x, ok = f, err == nil
+	case bigRatType:
+		x, ok = new(big.Rat).SetString(value)
+	}
+	if !ok {
+		return reflect.Value{}, &UnmarshalTypeError{Value: value, Type: typ}
+	}
+	return reflect.ValueOf(x).Elem(), nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+type bigMock struct {
+	Count *big.Int   `zpl:"count"`
+	Ratio *big.Float `zpl:"ratio"`
+	Share big.Rat    `zpl:"share"`
+}
+
+func TestBigTypes(t *testing.T) {
+	src := "count = 123456789012345678901234567890\nratio = 0.1000000000000000000000000001\nshare = 3/4\n"
+	var v bigMock
+	if err := Unmarshal([]byte(src), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Count == nil || v.Count.String() != "123456789012345678901234567890" {
+		t.Errorf("count = %v", v.Count)
+	}
+	if v.Share.RatString() != "3/4" {
+		t.Errorf("share = %v", v.Share.RatString())
+	}
+	out, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != src {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	if err := Unmarshal([]byte("count = 12x\n"), &v); err == nil {
+		t.Errorf("expected an error for an invalid integer")
+	}
+	if err := Unmarshal([]byte("count = 0755\n"), &v); err != nil || v.Count.String() != "755" {
+		t.Errorf("count = %v, err = %v", v.Count, err)
+	}
+	if err := Unmarshal([]byte("count = 0x10\n"), &v); err == nil {
+		t.Errorf("expected an error for a base prefix that is not enabled")
+	}
+	d := NewDecoder(strings.NewReader("count = 0x10\n"))
+	d.SetBasePrefixes(true)
+	if err := d.Decode(&v); err != nil || v.Count.String() != "16" {
+		t.Errorf("count = %v, err = %v", v.Count, err)
+	}
+}
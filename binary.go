@@ -0,0 +1,56 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"reflect"
+)
+
+// Report whether a field of type typ with the given "format" tag option holds
+// binary data written as text, i.e. a []byte with "format=base64" or
+// "format=hex".
+func isBinaryFormat(typ reflect.Type, format string) bool {
+	return (format == "base64" || format == "hex") &&
+		typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8
+}
+
+// Encode data as text in the named format.
+func formatBinary(data []byte, format string) string {
+	if format == "hex" {
+		return hex.EncodeToString(data)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// Decode text written in the named format.  Base64 may be written with or
+// without padding.
+func parseBinary(text string, format string) ([]byte, error) {
+	if format == "hex" {
+		return hex.DecodeString(text)
+	}
+	data, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(text)
+	}
+	return data, err
+}
+
+// Decode value into a binary field, appending to the data from earlier lines
+// for the same field so that a long value can be split across several.
+func (b *builder) addBinaryValue(field reflect.Value, format string, value string) error {
+	data, err := parseBinary(value, format)
+	if err != nil {
+		return &UnmarshalTypeError{Value: format + " " + value, Type: field.Type(), Err: err}
+	}
+	id := arrayID{ptr: field.UnsafeAddr()}
+	if b.filled[id] > 0 {
+		data = append(field.Bytes(), data...)
+	}
+	b.filled[id]++
+	field.SetBytes(data)
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"testing"
+)
+
+type binaryMock struct {
+	Cert []byte `zpl:"cert,format=base64"`
+	Key  []byte `zpl:"key,format=hex"`
+}
+
+func TestBinaryFormat(t *testing.T) {
+	v := binaryMock{Cert: []byte("certificate\x00"), Key: []byte{0xde, 0xad, 0xbe, 0xef}}
+	out, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "cert = Y2VydGlmaWNhdGUA\nkey = deadbeef\n" {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	var back binaryMock
+	if err := Unmarshal(out, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back.Cert, v.Cert) || !bytes.Equal(back.Key, v.Key) {
+		t.Errorf("round trip gave %q, %x", back.Cert, back.Key)
+	}
+	var split binaryMock
+	if err := Unmarshal([]byte("cert = Y2Vy\ncert = dGlm\nkey = de\nkey = ad\n"), &split); err != nil {
+		t.Fatal(err)
+	}
+	if string(split.Cert) != "certif" || !bytes.Equal(split.Key, []byte{0xde, 0xad}) {
+		t.Errorf("split values gave %q, %x", split.Cert, split.Key)
+	}
+	if err := Unmarshal([]byte("key = xyz\n"), &split); err == nil {
+		t.Errorf("expected an error for invalid hex")
+	}
+}
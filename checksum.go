@@ -0,0 +1,37 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sort"
+)
+
+// Checksum returns the SHA-256 hash of the canonical form of doc, in which
+// keys are sorted and every value is quoted.  Two documents have the same
+// checksum exactly when Equal reports that they are the same, so deployment
+// tools can tell whether a regenerated configuration differs from the one in
+// use before restarting anything.
+//
+func Checksum(doc *Section) [32]byte {
+	var buf bytes.Buffer
+	writeCanonical(&buf, doc, "")
+	return sha256.Sum256(buf.Bytes())
+}
+
+func writeCanonical(buf *bytes.Buffer, s *Section, indent string) {
+	keys := append([]string(nil), s.keys...)
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range s.values[key] {
+			buf.WriteString(indent + key + " = \"" + value + "\"\n")
+		}
+		if sub, ok := s.sections[key]; ok {
+			buf.WriteString(indent + key + "\n")
+			writeCanonical(buf, sub, indent+"    ")
+		}
+	}
+}
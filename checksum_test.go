@@ -0,0 +1,32 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	sum := func(src string) [32]byte {
+		doc, err := Parse([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Checksum(doc)
+	}
+	base := sum("version = 1\nmain\n    bind = a\n    bind = b\n")
+	if sum("# regenerated\nmain\n    bind = \"a\"\n    bind=b\nversion = 1\n") != base {
+		t.Errorf("checksum changed with layout")
+	}
+	for _, src := range []string{
+		"version = 1\nmain\n    bind = b\n    bind = a\n",
+		"version = 1\nmain\n    bind = a\n    bind = b\nempty\n",
+		"version = 1\nmain\n    bind = a\n    bind = \"b \"\n",
+	} {
+		if sum(src) == base {
+			t.Errorf("checksum unchanged for %q", src)
+		}
+	}
+}
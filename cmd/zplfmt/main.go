@@ -0,0 +1,92 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command zplfmt formats ZPL files.
+//
+// Usage:
+//
+//     zplfmt [-w] [-zdcf-upgrade app] [file ...]
+//
+// Each file is formatted with zpl.FormatPreserve, which fixes indentation and
+// spacing but keeps comments and blank lines, and written to standard output,
+// or in place if -w is given.  With no files, standard input is formatted to
+// standard output.
+//
+// With -zdcf-upgrade, each file is instead read as a ZDCF 0.x document and
+// converted to the ZDCF 1.0 layout, placing its devices in the named
+// application (see zdcf.Upgrade).  Comments are not kept by the conversion.
+//
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jtacoma/go-zpl"
+	"github.com/jtacoma/go-zpl/zdcf"
+)
+
+var (
+	write   = flag.Bool("w", false, "write result to (source) file instead of stdout")
+	upgrade = flag.String("zdcf-upgrade", "", "convert ZDCF 0.x to 1.0, placing devices in the named application")
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fatal(err)
+		}
+		out, err := format(src)
+		if err != nil {
+			fatal(err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+	for _, path := range flag.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fatal(err)
+		}
+		out, err := format(src)
+		if err != nil {
+			fatal(fmt.Errorf("%s: %s", path, err))
+		}
+		if *write {
+			info, err := os.Stat(path)
+			if err != nil {
+				fatal(err)
+			}
+			if err = ioutil.WriteFile(path, out, info.Mode().Perm()); err != nil {
+				fatal(err)
+			}
+		} else {
+			os.Stdout.Write(out)
+		}
+	}
+}
+
+func format(src []byte) ([]byte, error) {
+	out, err := zpl.FormatPreserve(src)
+	if err != nil || *upgrade == "" {
+		return out, err
+	}
+	doc, err := zpl.Parse(out)
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = zdcf.Upgrade(doc, *upgrade); err != nil {
+		return nil, err
+	}
+	return []byte(doc.String()), nil
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,111 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command zplgen generates Go struct definitions from a sample ZPL file.
+//
+// Usage:
+//
+//     zplgen [-package name] [-type name] [-o file.go] [sample.zpl]
+//     zplgen -methods [-lite] [-o file_zpl.go] file.go
+//
+// The generated types are written to standard output, or to the file given by
+// -o.  With no sample file, the sample is read from standard input.  See
+// package zplgen for how the types are chosen.
+//
+// With -methods, zplgen instead reads a Go source file and generates
+// reflection-free MarshalZPL and UnmarshalZPL methods for the struct types in
+// it marked with a "//zplgen:methods" comment (see zplgen.GenerateMethods).
+// They are written to the file given by -o or, by default, to a file named
+// after the source file with "_zpl" added, e.g. config_zpl.go.  With -lite,
+// the methods use package zpllite so that they can be built with TinyGo.
+//
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/jtacoma/go-zpl"
+	"github.com/jtacoma/go-zpl/zplgen"
+)
+
+var (
+	pkg      = flag.String("package", "main", "package of the generated file")
+	typeName = flag.String("type", "Config", "name of the type of the whole document")
+	output   = flag.String("o", "", "write the generated file here instead of stdout")
+	methods  = flag.Bool("methods", false, "generate methods for the marked types of a Go file")
+	lite     = flag.Bool("lite", false, "with -methods, generate methods that use package zpllite")
+)
+
+func main() {
+	flag.Parse()
+	if *methods {
+		generateMethods()
+		return
+	}
+	var (
+		src []byte
+		err error
+	)
+	switch flag.NArg() {
+	case 0:
+		src, err = ioutil.ReadAll(os.Stdin)
+	case 1:
+		src, err = ioutil.ReadFile(flag.Arg(0))
+	default:
+		fmt.Fprintln(os.Stderr, "usage: zplgen [-package name] [-type name] [-o file.go] [sample.zpl]")
+		os.Exit(2)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	doc, err := zpl.Parse(src)
+	if err != nil {
+		fatal(err)
+	}
+	out, err := zplgen.Generate(doc, zplgen.Options{Package: *pkg, Type: *typeName})
+	if err != nil {
+		fatal(err)
+	}
+	if *output == "" {
+		os.Stdout.Write(out)
+	} else if err = ioutil.WriteFile(*output, out, 0644); err != nil {
+		fatal(err)
+	}
+}
+
+func generateMethods() {
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: zplgen -methods [-lite] [-o file_zpl.go] file.go")
+		os.Exit(2)
+	}
+	path := flag.Arg(0)
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		fatal(err)
+	}
+	generate := zplgen.GenerateMethods
+	if *lite {
+		generate = zplgen.GenerateLiteMethods
+	}
+	out, err := generate(path, src)
+	if err != nil {
+		fatal(err)
+	}
+	dest := *output
+	if dest == "" {
+		dest = strings.TrimSuffix(path, ".go") + "_zpl.go"
+	}
+	if err = ioutil.WriteFile(dest, out, 0644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
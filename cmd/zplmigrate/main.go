@@ -0,0 +1,84 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command zplmigrate renames keys and sections in ZPL files.
+//
+// Usage:
+//
+//     zplmigrate -map renames.zpl [-w] [file ...]
+//
+// The map file is itself ZPL, with one "old/path = new/path" property per
+// rename.  Each file is rewritten to standard output, or in place if -w is
+// given.  With no files, standard input is rewritten to standard output.
+//
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jtacoma/go-zpl"
+	"github.com/jtacoma/go-zpl/zplmigrate"
+)
+
+var (
+	mapFile = flag.String("map", "", "ZPL file of old/path = new/path renames")
+	write   = flag.Bool("w", false, "write result to (source) file instead of stdout")
+)
+
+func main() {
+	flag.Parse()
+	if *mapFile == "" {
+		fmt.Fprintln(os.Stderr, "usage: zplmigrate -map renames.zpl [-w] [file ...]")
+		os.Exit(2)
+	}
+	raw, err := ioutil.ReadFile(*mapFile)
+	if err != nil {
+		fatal(err)
+	}
+	renames := make(map[string]string)
+	if err = zpl.Unmarshal(raw, renames); err != nil {
+		fatal(err)
+	}
+	if flag.NArg() == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fatal(err)
+		}
+		out, err := zplmigrate.Migrate(src, renames)
+		if err != nil {
+			fatal(err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+	for _, path := range flag.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fatal(err)
+		}
+		out, err := zplmigrate.Migrate(src, renames)
+		if err != nil {
+			fatal(fmt.Errorf("%s: %s", path, err))
+		}
+		if *write {
+			info, err := os.Stat(path)
+			if err != nil {
+				fatal(err)
+			}
+			if err = ioutil.WriteFile(path, out, info.Mode().Perm()); err != nil {
+				fatal(err)
+			}
+		} else {
+			os.Stdout.Write(out)
+		}
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
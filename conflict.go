@@ -0,0 +1,92 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A ConflictError is returned when a document uses the same key for both a
+// property and a section, e.g. "foo = 1" followed later by a "foo" section in
+// the same enclosing section.  Such a document has no consistent meaning, so
+// it is rejected rather than decoded one way or the other.
+//
+type ConflictError struct {
+	Path         string // "/"-separated path of the key
+	PropertyLine uint64 // line on which the key first appeared as a property
+	SectionLine  uint64 // line on which the key first appeared as a section
+}
+
+func (e *ConflictError) Error() string {
+	return "zpl: " + e.Path + " is a property on line " +
+		strconv.FormatUint(e.PropertyLine, 10) + " and a section on line " +
+		strconv.FormatUint(e.SectionLine, 10)
+}
+
+// A keyChecker follows parse events to detect keys used both as properties
+// and as sections.  Since repeated sections are merged, it remembers every
+// key seen at every path, unless it is streaming: then, so that its memory
+// does not grow with the length of the document, it remembers only the keys
+// of the sections still open, and a conflict between the contents of two
+// appearances of a repeated section goes undetected.
+type keyChecker struct {
+	streaming bool
+	root      keyUse
+	stack     []*keyUse // the sections enclosing the current event
+	names     []string  // the names of those sections
+}
+
+// The first use of a key.
+type keyUse struct {
+	section bool
+	line    uint64
+	keys    map[string]*keyUse // keys within a section
+}
+
+// Record the key used by e on the given line, returning a *ConflictError if
+// it was used differently before.
+func (c *keyChecker) check(e *Event, line uint64) error {
+	if e.Type == EndSection {
+		if c.streaming {
+			c.stack[len(c.stack)-1].keys = nil
+		}
+		c.stack = c.stack[:len(c.stack)-1]
+		c.names = c.names[:len(c.names)-1]
+		return nil
+	}
+	parent := &c.root
+	if len(c.stack) > 0 {
+		parent = c.stack[len(c.stack)-1]
+	}
+	section := e.Type == StartSection
+	use, ok := parent.keys[e.Name]
+	if !ok {
+		if parent.keys == nil {
+			parent.keys = make(map[string]*keyUse)
+		}
+		use = &keyUse{section: section, line: line}
+		parent.keys[e.Name] = use
+	}
+	var err error
+	if use.section != section {
+		conflict := &ConflictError{
+			Path:         strings.Join(append(c.names[:len(c.names):len(c.names)], e.Name), "/"),
+			PropertyLine: line,
+			SectionLine:  use.line,
+		}
+		if section {
+			conflict.PropertyLine, conflict.SectionLine = use.line, line
+			// Keep checking the keys within this section on their own.
+			use = &keyUse{section: true, line: line}
+		}
+		err = conflict
+	}
+	if section {
+		c.stack = append(c.stack, use)
+		c.names = append(c.names, e.Name)
+	}
+	return err
+}
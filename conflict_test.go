@@ -0,0 +1,63 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecoder_Decode_Conflict(t *testing.T) {
+	tests := []struct {
+		src    string
+		expect ConflictError
+	}{
+		{"foo = 1\nbar = 2\nfoo\n    x = 1\n", ConflictError{"foo", 1, 3}},
+		{"main\n    foo\n        x = 1\nmain\n    foo = 1\n", ConflictError{"main/foo", 5, 2}},
+	}
+	for _, test := range tests {
+		for _, v := range []interface{}{new(map[string]interface{}), new(Section)} {
+			err := Unmarshal([]byte(test.src), v)
+			if e, ok := err.(*ConflictError); !ok {
+				t.Errorf("%T: expected *ConflictError, got %T: %v", v, err, err)
+			} else if *e != test.expect {
+				t.Errorf("%T: unexpected error: %s", v, e)
+			}
+		}
+	}
+	var m map[string]interface{}
+	if err := Unmarshal([]byte("a\n    foo = 1\nb\n    foo\n        x = 1\n"), &m); err != nil {
+		t.Errorf("unexpected error for keys in different sections: %s", err)
+	}
+}
+
+func TestDecoder_DecodeTo_Conflict(t *testing.T) {
+	var events eventRecorder
+	err := NewDecoder(strings.NewReader("foo = 1\nfoo\n    x = 1\n")).DecodeTo(&events)
+	if e, ok := err.(*ConflictError); !ok || *e != (ConflictError{"foo", 1, 2}) {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+	events = nil
+	src := "main\n    foo\n        x = 1\nmain\n    foo = 1\n"
+	if err := NewDecoder(strings.NewReader(src)).DecodeTo(&events); err != nil {
+		t.Errorf("unexpected error across repeated sections: %s", err)
+	}
+	keys := keyChecker{streaming: true}
+	for _, e := range []Event{{Type: StartSection, Name: "main"}, {Type: AddValue, Name: "foo"}, {Type: EndSection}} {
+		if err := keys.check(&e, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if use := keys.root.keys["main"]; use == nil || use.keys != nil {
+		t.Errorf("expected the keys of an ended section to be forgotten, got %+v", use)
+	}
+}
+
+func TestConflictError_Error(t *testing.T) {
+	err := &ConflictError{Path: "main/foo", PropertyLine: 5, SectionLine: 2}
+	if s := err.Error(); s != "zpl: main/foo is a property on line 5 and a section on line 2" {
+		t.Errorf("unexpected message: %s", s)
+	}
+}
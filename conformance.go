@@ -0,0 +1,189 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+//go:embed conformance
+var conformance embed.FS
+
+// ConformanceSuite returns the conformance corpus shipped with this package,
+// for use with RunConformance.  Its layout, which other corpora must follow,
+// is described in its README file:
+//
+// Each valid/NAME.zpl is a document that must parse into the events listed in
+// valid/NAME.events, one per line, as "StartSection NAME", "EndSection" or
+// "AddValue KEY VALUE" with the value in Go's double-quoted syntax.  Each
+// invalid/NAME.zpl is a document that must be rejected with a syntax error on
+// the line whose number is in invalid/NAME.err.
+//
+// Implementations of ZPL in other languages can use the same files to check
+// that they parse documents as this package does.
+//
+func ConformanceSuite() fs.FS {
+	sub, err := fs.Sub(conformance, "conformance")
+	if err != nil {
+		panic("zpl: program error: " + err.Error())
+	}
+	return sub
+}
+
+// A ConformanceResult is the outcome of one document of a conformance corpus.
+//
+type ConformanceResult struct {
+	Name string // the path of the document within the corpus
+	Err  error  // why the document did not conform, or nil if it did
+}
+
+// A Report lists the outcome of every document of a conformance corpus, valid
+// documents first, each group in lexical order.
+//
+type Report struct {
+	Results []ConformanceResult
+}
+
+// Failed returns the results of the documents that did not conform.
+func (r Report) Failed() []ConformanceResult {
+	var failed []ConformanceResult
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// OK reports whether the corpus had at least one document and every document
+// conformed.
+func (r Report) OK() bool {
+	return len(r.Results) > 0 && len(r.Failed()) == 0
+}
+
+// String returns a summary of the report followed by a line for each failure.
+func (r Report) String() string {
+	failed := r.Failed()
+	s := strconv.Itoa(len(r.Results)-len(failed)) + " passed, " + strconv.Itoa(len(failed)) + " failed"
+	for _, result := range failed {
+		s += "\n" + result.Name + ": " + result.Err.Error()
+	}
+	return s
+}
+
+// RunConformance parses each document of the conformance corpus fsys with a
+// Decoder in its default configuration and reports whether it produced the
+// expected events or syntax error.  The corpus shipped with this package is
+// returned by ConformanceSuite; a fork of this package can run it to confirm
+// that it still parses documents as the original does:
+//
+//     if r := zpl.RunConformance(zpl.ConformanceSuite()); !r.OK() {
+//         t.Error(r)
+//     }
+//
+func RunConformance(fsys fs.FS) Report {
+	var r Report
+	for _, dir := range []string{"valid", "invalid"} {
+		names, err := fs.Glob(fsys, dir+"/*.zpl")
+		if err != nil {
+			panic("zpl: program error: " + err.Error())
+		}
+		for _, name := range names {
+			base := strings.TrimSuffix(name, ".zpl")
+			if dir == "valid" {
+				err = checkValid(fsys, name, base+".events")
+			} else {
+				err = checkInvalid(fsys, name, base+".err")
+			}
+			r.Results = append(r.Results, ConformanceResult{Name: name, Err: err})
+		}
+	}
+	return r
+}
+
+// Check that the document name parses into the events listed in the file
+// expected.
+func checkValid(fsys fs.FS, name, expected string) error {
+	want, err := fs.ReadFile(fsys, expected)
+	if err != nil {
+		return err
+	}
+	got, err := conformanceEvents(fsys, name)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(string(want), "\r\n", "\n"), "\n"), "\n")
+	if len(want) == 0 {
+		lines = nil
+	}
+	for i := 0; i < len(lines) || i < len(got); i++ {
+		switch {
+		case i == len(got):
+			return errors.New("zpl: event " + strconv.Itoa(i+1) + ": expected " + lines[i] + ", got the end of the document")
+		case i == len(lines):
+			return errors.New("zpl: event " + strconv.Itoa(i+1) + ": expected the end of the document, got " + got[i])
+		case lines[i] != got[i]:
+			return errors.New("zpl: event " + strconv.Itoa(i+1) + ": expected " + lines[i] + ", got " + got[i])
+		}
+	}
+	return nil
+}
+
+// Check that parsing the document name fails with a syntax error on the line
+// whose number is in the file expected.
+func checkInvalid(fsys fs.FS, name, expected string) error {
+	want, err := fs.ReadFile(fsys, expected)
+	if err != nil {
+		return err
+	}
+	line, err := strconv.ParseUint(string(bytes.TrimSpace(want)), 10, 64)
+	if err != nil {
+		return errors.New("zpl: " + expected + " does not hold a line number")
+	}
+	_, err = conformanceEvents(fsys, name)
+	syntaxErr, ok := err.(*SyntaxError)
+	switch {
+	case err == nil:
+		return errors.New("zpl: expected a syntax error on line " + strconv.FormatUint(line, 10) + ", got none")
+	case !ok:
+		return err
+	case syntaxErr.Line != line:
+		return errors.New("zpl: expected a syntax error on line " + strconv.FormatUint(line, 10) + ", got " + err.Error())
+	}
+	return nil
+}
+
+// Return the events of the document name in the format of a corpus.
+func conformanceEvents(fsys fs.FS, name string) ([]string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var events []string
+	d := NewDecoder(f)
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			return events, nil
+		} else if err != nil {
+			return events, err
+		}
+		switch e.Type {
+		case AddValue:
+			events = append(events, e.Type.String()+" "+e.Name+" "+strconv.Quote(e.Value))
+		case StartSection:
+			events = append(events, e.Type.String()+" "+e.Name)
+		default:
+			events = append(events, e.Type.String())
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRunConformance(t *testing.T) {
+	r := RunConformance(ConformanceSuite())
+	if !r.OK() {
+		t.Fatal(r)
+	}
+	if len(r.Results) < 10 || r.Results[0].Name != "valid/comments.zpl" {
+		t.Errorf("unexpected results: %v", r.Results)
+	}
+}
+
+func TestRunConformance_Failures(t *testing.T) {
+	fsys := fstest.MapFS{
+		"valid/a.zpl":       {Data: []byte("a\n    b = 1\n")},
+		"valid/a.events":    {Data: []byte("StartSection a\nAddValue b \"2\"\n")},
+		"valid/b.zpl":       {Data: []byte("b = 1\n")},
+		"valid/c.zpl":       {Data: []byte("c = 1\n")},
+		"valid/c.events":    {Data: []byte("AddValue c \"1\"\n")},
+		"invalid/d.zpl":     {Data: []byte("d = 1\n")},
+		"invalid/d.err":     {Data: []byte("1\n")},
+		"invalid/e.zpl":     {Data: []byte("e = 1\n\te = 2\n")},
+		"invalid/e.err":     {Data: []byte("1\n")},
+		"invalid/f.zpl":     {Data: []byte("f\n")},
+		"invalid/f.err":     {Data: []byte("one\n")},
+		"other/ignored.zpl": {Data: []byte("=\n")},
+	}
+	r := RunConformance(fsys)
+	if r.OK() {
+		t.Fatal("expected failures")
+	}
+	expected := []string{
+		"valid/a.zpl: zpl: event 2: expected AddValue b \"2\", got AddValue b \"1\"",
+		"valid/b.zpl: open valid/b.events: file does not exist",
+		"invalid/d.zpl: zpl: expected a syntax error on line 1, got none",
+		"invalid/e.zpl: zpl: expected a syntax error on line 1, got 2:is neither a comment, a section header, nor a key = value setting.",
+		"invalid/f.zpl: zpl: invalid/f.err does not hold a line number",
+	}
+	if s := r.String(); s != "1 passed, 5 failed\n"+strings.Join(expected, "\n") {
+		t.Errorf("unexpected report:\n%s", s)
+	}
+	if (Report{}).OK() {
+		t.Errorf("an empty corpus should not be OK")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A ConstraintError describes a ZPL value that violates a "min", "max" or
+// "oneof" option in the tag of the struct field it was decoded into.  A
+// Decoder reports every violation in a document together, in an ErrorList,
+// once the rest of the document has been decoded.
+//
+type ConstraintError struct {
+	Path  string // "/"-separated path of the property
+	Line  uint64 // line on which the value appeared
+	Value string // the offending value
+	Msg   string // description of the violated constraint
+}
+
+func (e *ConstraintError) Error() string {
+	return "zpl: " + location(e.Path, e.Line) + e.Value + " " + e.Msg
+}
+
+// Check value against the constraints in a field's tag, recording a
+// *ConstraintError for each one it violates.  The value is as written, e.g.
+// "2G", and expanded, e.g. "2147483648", if the field has "format=size".
+func (b *builder) checkConstraints(name string, tag tagInfo, written string, value string) {
+	violate := func(msg string) {
+		b.violations = append(b.violations, &ConstraintError{
+			Path:  strings.Join(append(b.path[:len(b.path):len(b.path)], name), "/"),
+			Line:  b.dec.lineno,
+			Value: written,
+			Msg:   msg,
+		})
+	}
+	if oneof, ok := tag.option("oneof"); ok {
+		if !hasWord(oneof, written) {
+			violate("is not one of " + strings.Join(strings.Fields(oneof), ", "))
+		}
+	}
+	min, hasMin := tag.option("min")
+	max, hasMax := tag.option("max")
+	if !hasMin && !hasMax {
+		return
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		// The value will be reported as unsuitable for the field's type.
+		return
+	}
+	if limit, ok := constraintLimit(tag, min); hasMin && ok && n < limit {
+		violate("is less than the minimum " + min)
+	}
+	if limit, ok := constraintLimit(tag, max); hasMax && ok && n > limit {
+		violate("is greater than the maximum " + max)
+	}
+}
+
+// Parse a "min" or "max" limit, which is a size like "1G" if the field has
+// the "format=size" option.
+func constraintLimit(tag tagInfo, limit string) (float64, bool) {
+	if tag.Options["format"] == "size" {
+		limit, _ = expandSize(limit)
+	}
+	n, err := strconv.ParseFloat(limit, 64)
+	return n, err == nil
+}
+
+// Report whether s is one of the space-separated words in list.
+func hasWord(list string, s string) bool {
+	for _, word := range strings.Fields(list) {
+		if word == s {
+			return true
+		}
+	}
+	return false
+}
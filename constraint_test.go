@@ -0,0 +1,55 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"testing"
+)
+
+type constraintMock struct {
+	Type    string `zpl:"type,oneof=zmq_queue zmq_forwarder zmq_streamer"`
+	Sockets map[string]*struct {
+		Port int    `zpl:"port,min=1,max=65535"`
+		Swap uint64 `zpl:"swap,format=size,max=1G"`
+	} `zpl:"*"`
+}
+
+func TestDecoder_Decode_Constraints(t *testing.T) {
+	var v constraintMock
+	err := Unmarshal([]byte(`type = zmq_queue
+frontend
+    port = 5555
+    swap = 512M
+`), &v)
+	if err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	err = Unmarshal([]byte(`type = zmq_proxy
+frontend
+    port = 0
+backend
+    port = 70000
+    port = 5556
+    swap = 2G
+`), &v)
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("expected ErrorList, got %T: %v", err, err)
+	}
+	expect := []string{
+		"zpl: type (line 1): zmq_proxy is not one of zmq_queue, zmq_forwarder, zmq_streamer",
+		"zpl: frontend/port (line 3): 0 is less than the minimum 1",
+		"zpl: backend/port (line 5): 70000 is greater than the maximum 65535",
+		"zpl: backend/swap (line 7): 2G is greater than the maximum 1G",
+	}
+	if len(errs) != len(expect) {
+		t.Fatalf("expected %d errors, got %v", len(expect), errs)
+	}
+	for i, e := range errs {
+		if _, ok := e.(*ConstraintError); !ok || e.Error() != expect[i] {
+			t.Errorf("expected %s, got %v", expect[i], e)
+		}
+	}
+}
@@ -9,8 +9,8 @@ import (
 	"errors"
 	"io"
 	"reflect"
-	"regexp"
 	"strconv"
+	"strings"
 )
 
 // An InvalidUnmarshalError describes an invalid argument passed to Unmarshal.
@@ -30,12 +30,19 @@ func (e *InvalidUnmarshalError) Error() string {
 // An UnmarshalFieldError describes describes a ZPL key that could not be matched to a map key or struct field.
 //
 type UnmarshalFieldError struct {
-	Key  string
-	Type reflect.Type
+	Key         string
+	Type        reflect.Type
+	Path        string   // slash-separated path of the key, e.g. "main/frontend/bind"
+	Line        uint64   // the key occurred on this line
+	Suggestions []string // keys of Type similar to Key, most similar first
 }
 
 func (e *UnmarshalFieldError) Error() string {
-	return "zpl: no field tagged \"" + e.Key + "\" could be found on " + e.Type.String()
+	msg := "zpl: " + location(e.Path, e.Line) + "no field tagged \"" + e.Key + "\" could be found on " + e.Type.String()
+	if len(e.Suggestions) > 0 {
+		msg += "; did you mean \"" + strings.Join(e.Suggestions, "\" or \"") + "\"?"
+	}
+	return msg
 }
 
 // An UnmarshalTypeError describes a ZPL value that was not appropriate for a value of a specific Go type.
@@ -43,10 +50,110 @@ func (e *UnmarshalFieldError) Error() string {
 type UnmarshalTypeError struct {
 	Value string       // description of ZPL value - "bool", "array", "number -5"
 	Type  reflect.Type // type of Go value it could not be assigned to
+	Path  string       // slash-separated path of the key, e.g. "main/frontend/bind"
+	Line  uint64       // the value occurred on this line
+	Err   error        // the underlying parse error, e.g. a *strconv.NumError, if any
 }
 
 func (e *UnmarshalTypeError) Error() string {
-	return "zpl: cannot unmarshal " + e.Value + " into " + e.Type.String()
+	msg := "zpl: " + location(e.Path, e.Line) + "cannot unmarshal " + e.Value + " into " + e.Type.String()
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying parse error, so that errors.As can tell, for
+// example, a value out of range (strconv.ErrRange) from one that is not a
+// number at all (strconv.ErrSyntax).
+func (e *UnmarshalTypeError) Unwrap() error {
+	return e.Err
+}
+
+// Describe where an error occurred, e.g. "main/frontend/bind (line 12): ".
+func location(path string, line uint64) string {
+	if path == "" && line == 0 {
+		return ""
+	} else if line == 0 {
+		return path + ": "
+	} else if path == "" {
+		return "line " + strconv.FormatUint(line, 10) + ": "
+	}
+	return path + " (line " + strconv.FormatUint(line, 10) + "): "
+}
+
+// An UnmarshalOverflowError describes a ZPL property repeated more times than
+// the Go array it is being unmarshalled into can hold.
+//
+type UnmarshalOverflowError struct {
+	Key  string       // the repeated ZPL key
+	Type reflect.Type // type of Go array that overflowed
+	Path string       // slash-separated path of the key, e.g. "main/frontend/bind"
+	Line uint64       // the value that did not fit occurred on this line
+}
+
+func (e *UnmarshalOverflowError) Error() string {
+	return "zpl: " + location(e.Path, e.Line) + "too many values for \"" + e.Key + "\" to fit in " + e.Type.String()
+}
+
+// A LimitError is returned when a document has more elements than a Decoder
+// allows (see SetMaxElements).  Decoding stops at the first element beyond
+// the limit, even in best-effort mode.
+//
+type LimitError struct {
+	Limit int    // the maximum number of properties and sections
+	Line  uint64 // line of the first element beyond the limit
+}
+
+func (e *LimitError) Error() string {
+	return "zpl: line " + strconv.FormatUint(e.Line, 10) + ": document has more than " +
+		strconv.Itoa(e.Limit) + " properties and sections"
+}
+
+// A Warning describes something questionable in a ZPL document that did not
+// prevent it from being decoded, such as the use of a deprecated key.
+//
+type Warning struct {
+	Kind WarningKind // what sort of oddity was found
+	Line uint64      // the warning concerns this line
+	Key  string      // the ZPL key involved
+	Msg  string      // description of the warning
+}
+
+// A WarningKind classifies a Warning so that applications can choose which
+// ones to log or act upon.
+//
+type WarningKind int
+
+const (
+	// WarnDeprecated is recorded when a key is given by a deprecated
+	// alias (see the "alias" tag option).
+	WarnDeprecated WarningKind = iota + 1
+
+	// WarnUnknownKey is recorded when a key or section that matches no
+	// struct field is ignored (see Decoder.SetIgnoreUnknownKeys).
+	WarnUnknownKey
+
+	// WarnOverwritten is recorded when a key that holds a single value
+	// is repeated within a section, so that the later value replaces the
+	// earlier one.
+	WarnOverwritten
+)
+
+func (k WarningKind) String() string {
+	switch k {
+	case WarnDeprecated:
+		return "deprecated"
+	case WarnUnknownKey:
+		return "unknown key"
+	case WarnOverwritten:
+		return "overwritten"
+	}
+	return "WarningKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+func (w Warning) String() string {
+	return strconv.FormatUint(w.Line, 10) + ":" + w.Msg
 }
 
 // A SyntaxError is a description of a ZPL syntax error.
@@ -61,7 +168,8 @@ func (e *SyntaxError) Error() string {
 }
 
 // Unmarshal parses the ZPL-encoded data and stores the result in the value
-// pointed to by v.
+// pointed to by v.  If v implements Unmarshaler, Unmarshal calls its
+// UnmarshalZPL method instead.
 //
 // Unmarshal allocates maps, slices, and pointers as necessary while following
 // these rules:
@@ -75,14 +183,47 @@ func (e *SyntaxError) Error() string {
 // is nil, that is, has no concrete value stored in it, Unmarshal stores a
 // map[string]interface{} in the interface value.
 //
+// To unmarshal ZPL into a map, Unmarshal uses each property or subsection
+// name as a key.  The map's key type must be a string type or implement
+// encoding.TextUnmarshaler, whose UnmarshalText method is given the name.
+//
+// To unmarshal a repeated ZPL property into a slice, Unmarshal appends each
+// value to the slice.  To unmarshal it into an array, Unmarshal stores each
+// value in the next element of the array and returns an
+// UnmarshalOverflowError if there are more values than elements.
+//
+// To unmarshal ZPL into a *Section, Unmarshal records every property and
+// subsection in the order and at the position where it appears.
+//
+// If the same key is used for both a property and a section within the same
+// section, Unmarshal returns a *ConflictError naming the lines of both.
+//
 // If a ZPL value is not appropriate for a given target type, or if a ZPL number
 // overflows the target type, Unmarshal returns the error after processing the
 // remaining data.
 //
 func Unmarshal(src []byte, dst interface{}) error {
-	r := bytes.NewReader(src)
-	d := NewDecoder(r)
-	return d.Decode(dst)
+	if u, ok := dst.(Unmarshaler); ok {
+		return u.UnmarshalZPL(src)
+	}
+	return unmarshalBytes(src, dst)
+}
+
+// Unmarshaler is implemented by types that can decode a ZPL document into
+// themselves, such as those for which zplgen generates methods.  Unmarshal
+// calls UnmarshalZPL instead of using reflection when dst implements it.
+// Decoders do not, since the method cannot apply their settings.
+//
+type Unmarshaler interface {
+	UnmarshalZPL(src []byte) error
+}
+
+// Decode src without an intermediate io.Reader, scanning its lines in place.
+func unmarshalBytes(src []byte, dst interface{}) error {
+	d := getDecoder(src)
+	err := d.Decode(dst)
+	putDecoder(d)
+	return err
 }
 
 // A Decoder represents a ZPL parser reading a particular input stream.  The
@@ -93,9 +234,80 @@ type Decoder struct {
 	prevDepth int
 	buffer    []byte
 	lineno    uint64
-	queue     []*parseEvent
+	queue     []Event // events parsed but not yet returned by next
+	qhead     int     // index of the first unreturned event in queue
+	event     Event   // the event most recently returned by next
+	chunk     []byte  // scratch space for reading from r
+	eof       bool    // whether r has been read to the end
+	line      []byte  // raw text of the line most recently scanned
+
+	interfaceMode InterfaceMode
+	useNumber     bool
+	lenientBools  bool
+	lineBreaks    LineBreakMode
+	transform     func(path, key, value string) (string, error)
+	jsonTags      bool
+	template      *templateOptions
+	secrets       map[string]func(string) (string, error)
+	warnings      []Warning
+	basePrefixes  bool
+	filename      string
+	bestEffort    bool
+	progress      func(bytesRead int64, line uint64)
+	bytesRead     int64
+	reported      int64    // value of bytesRead when progress was last reported
+	at            []string // path of the only section to decode, if any
+	stats         Stats
+	ignoreUnknown bool
+	trace         func(ev Event, line uint64)
+	profile       *profileState
+	lowerKeys     bool
+	maxElements   int
+}
+
+// Stats describes the input consumed by a Decoder so far.
+//
+type Stats struct {
+	Lines    uint64 // lines scanned, including comments and blank lines
+	Comments uint64 // comment lines
+	Sections uint64 // section headers
+	Values   uint64 // key = value settings
+	MaxDepth int    // greatest nesting of sections, 0 if there were none
+	Bytes    int64  // bytes of the lines scanned, including line breaks
 }
 
+// Stats returns counts describing the input the Decoder has consumed so far,
+// for monitoring services that ingest configurations supplied by users.
+// Input that the Decoder has buffered but not yet scanned is not counted.
+//
+func (d *Decoder) Stats() Stats {
+	return d.stats
+}
+
+// Bytes read between calls to a Decoder's progress function.
+const progressInterval = 1 << 16
+
+// An InterfaceMode selects the type of Go value that a Decoder stores when it
+// unmarshals a ZPL property into an interface value.
+//
+type InterfaceMode int
+
+const (
+	// InterfaceSlices stores every property as a []string, even when it
+	// occurs only once.  This is the default.
+	InterfaceSlices InterfaceMode = iota
+
+	// InterfaceStrings stores a property that occurs once as a string and
+	// upgrades it to a []string when the property is repeated.
+	InterfaceStrings
+
+	// InterfaceTyped stores a property that occurs once as an int64,
+	// float64 or bool if its value parses as one (e.g. "1", "0.5" or
+	// "true"), or as a string otherwise.  Repeated properties are
+	// upgraded to a []interface{} of such values.
+	InterfaceTyped
+)
+
 // NewDecoder creates a new ZPL parser that reads from r.
 //
 // The decoder introduces its own buffering and may read data from r beyond
@@ -107,6 +319,142 @@ func NewDecoder(r io.Reader) *Decoder {
 	}
 }
 
+// SetInterfaceMode selects the type of Go value stored for ZPL properties
+// unmarshalled into interface values, such as the elements of a
+// map[string]interface{}.
+//
+func (d *Decoder) SetInterfaceMode(mode InterfaceMode) {
+	d.interfaceMode = mode
+}
+
+// UseNumber causes the Decoder to store numbers in interface values as a
+// Number instead of as an int64 or float64 when InterfaceTyped is in effect.
+//
+func (d *Decoder) UseNumber() {
+	d.useNumber = true
+}
+
+// SetLenientBools causes the Decoder to accept "yes", "no", "on" and "off", in
+// any combination of upper and lower case, as well as the literals understood
+// by strconv.ParseBool when unmarshalling into bool values.
+//
+func (d *Decoder) SetLenientBools(enabled bool) {
+	d.lenientBools = enabled
+}
+
+// SetValueTransformer registers a function that is called with each value
+// before it is converted and stored, along with its key and the slash-separated
+// path of the section that contains it (e.g. "main/frontend").  The string it
+// returns is used in place of the original value; an error it returns stops
+// decoding and is returned from Decode.
+//
+// The transformer is a general extension point for trimming values, mapping
+// aliases, expanding templates or resolving secrets.
+//
+func (d *Decoder) SetValueTransformer(fn func(path, key, value string) (string, error)) {
+	d.transform = fn
+}
+
+// SetJSONTagFallback causes the Decoder to match ZPL keys against the name in
+// a struct field's "json" tag when the field has no "zpl" tag, or against the
+// field's own name if the "json" tag has only options, as in ",omitempty".
+//
+func (d *Decoder) SetJSONTagFallback(enabled bool) {
+	d.jsonTags = enabled
+}
+
+// SetBasePrefixes causes the Decoder to accept hexadecimal, octal and binary
+// integers written with a "0x", "0o" or "0b" prefix (e.g. "0x1F4", "0o755" or
+// "0b1010"), and underscores between digits, when unmarshalling into integer
+// values.  A leading zero alone does not denote octal.
+//
+func (d *Decoder) SetBasePrefixes(enabled bool) {
+	d.basePrefixes = enabled
+}
+
+// SetFilename sets the name of the document being decoded, as recorded in the
+// positions of a Section.
+//
+func (d *Decoder) SetFilename(name string) {
+	d.filename = name
+}
+
+// SetBestEffort causes Decode to store every value it can, skipping properties
+// and whole sections that cannot be stored and lines with syntax errors, then
+// return an ErrorList describing everything that was skipped.  This allows a
+// service to start with most of its configuration while reporting precisely
+// which settings were ignored.
+//
+func (d *Decoder) SetBestEffort(enabled bool) {
+	d.bestEffort = enabled
+}
+
+// SetProgress registers a function that is called periodically while decoding
+// with the number of bytes read so far and the current line number, and once
+// more when the end of the input is reached.  This allows tools that decode
+// very large documents to display progress.
+//
+func (d *Decoder) SetProgress(fn func(bytesRead int64, line uint64)) {
+	d.progress = fn
+}
+
+// SetTrace registers a function that is called with each event as the
+// Decoder parses it, along with the line on which it was found, which helps
+// to explain why a document decodes into an unexpected shape.  Events are
+// traced whether or not they are decoded successfully, and the end of a
+// section is reported on the line that ends it.
+//
+func (d *Decoder) SetTrace(fn func(ev Event, line uint64)) {
+	d.trace = fn
+}
+
+func (d *Decoder) reportProgress(n int64, done bool) {
+	d.bytesRead += n
+	if d.progress == nil {
+		return
+	}
+	if done || d.bytesRead-d.reported >= progressInterval {
+		d.reported = d.bytesRead
+		d.progress(d.bytesRead, d.lineno)
+	}
+}
+
+// Warnings returns the warnings collected so far while decoding.
+//
+func (d *Decoder) Warnings() []Warning {
+	return d.warnings
+}
+
+func (d *Decoder) warn(kind WarningKind, key string, msg string) {
+	d.warnings = append(d.warnings, Warning{Kind: kind, Line: d.lineno, Key: key, Msg: msg})
+}
+
+// SetIgnoreUnknownKeys causes the Decoder to skip keys and sections that
+// match no field of the struct being decoded into, recording a Warning of kind
+// WarnUnknownKey for each, instead of failing with an UnmarshalFieldError.
+//
+func (d *Decoder) SetIgnoreUnknownKeys(enabled bool) {
+	d.ignoreUnknown = enabled
+}
+
+// SetMaxElements limits the number of key = value settings and section
+// headers, together, that the Decoder accepts, so that a service parsing
+// untrusted input can reject a huge document early with a *LimitError.  A
+// section header that is repeated counts each time.  A limit of 0 or less,
+// the default, means no limit.
+//
+func (d *Decoder) SetMaxElements(n int) {
+	d.maxElements = n
+}
+
+// SetLowerCaseKeys causes the Decoder to convert every property and section
+// name to lower case before it is matched against struct fields or stored,
+// so that documents written with inconsistent case fit lower-case schemas.
+//
+func (d *Decoder) SetLowerCaseKeys(enabled bool) {
+	d.lowerKeys = enabled
+}
+
 // Decode reads the next ZPL-encoded value from its input and stores it in the
 // value pointed to by v.
 //
@@ -115,126 +463,340 @@ func NewDecoder(r io.Reader) *Decoder {
 //
 func (d *Decoder) Decode(v interface{}) error {
 	var (
-		builder sink
-		fault   error
+		sink   Sink
+		values *builder
+		fault  error
 	)
-	if builder, fault = newBuilder(v); fault != nil {
+	if s, ok := v.(*Section); ok && s != nil {
+		sink = newTreeBuilder(d, s)
+	} else if values, fault = newBuilder(d, v); fault != nil {
 		return fault
+	} else {
+		sink = values
+	}
+	if len(d.at) > 0 {
+		sink = &pathFilter{Sink: sink, path: d.at}
+	}
+	err := d.run(sink, false)
+	if values != nil {
+		violations := values.violations
+		putBuilder(values)
+		if len(violations) > 0 {
+			if errs, ok := err.(ErrorList); ok {
+				return append(errs, violations...)
+			} else if err == nil {
+				return violations
+			}
+		}
 	}
+	return err
+}
+
+// DecodeTo reads the next ZPL-encoded document from its input and passes each
+// parse event to sink instead of storing the document in a Go value, so that
+// the document can be processed without reflection.
+//
+// Options that concern how values are stored, such as value transformers and
+// secret resolvers, do not apply; the events carry values as parsed.  Syntax
+// errors and conflicts between properties and sections are reported as they
+// are by Decode, except that, so that memory use does not grow with the
+// length of the document, keys in a section that has ended are forgotten: a
+// conflict between two appearances of a repeated section is not detected.
+//
+func (d *Decoder) DecodeTo(sink Sink) error {
+	if len(d.at) > 0 {
+		sink = &pathFilter{Sink: sink, path: d.at}
+	}
+	return d.run(sink, true)
+}
+
+// Next returns the next parse event from the decoder's input, or io.EOF when
+// there are no more, making the Decoder a Source.  The event is only valid
+// until the following call to Next.
+//
+// Unlike Decode and DecodeTo, Next does not check for conflicts between
+// properties and sections.
+//
+func (d *Decoder) Next() (*Event, error) {
+	if err := d.expandTemplate(); err != nil {
+		return nil, err
+	}
+	return d.next()
+}
+
+// Parse the decoder's input, passing each event to builder.  If streaming,
+// the keys of sections that have ended are not kept to check for conflicts.
+func (d *Decoder) run(builder Sink, streaming bool) error {
+	if err := d.expandTemplate(); err != nil {
+		return err
+	}
+	var (
+		fault error
+		errs  ErrorList
+		skip  int // depth within a section that could not be decoded
+		keys  = keyChecker{streaming: streaming}
+	)
 	for {
 		e, err := d.next()
+		var err2 error
 		if e != nil {
-			if err2 := builder.consume(e); err2 != nil && fault == nil {
-				fault = err2
-				break
+			err2 = keys.check(e, d.lineno)
+		}
+		if e != nil && skip > 0 {
+			switch e.Type {
+			case StartSection:
+				skip++
+			case EndSection:
+				skip--
+			}
+		} else if e != nil {
+			if err2 == nil {
+				err2 = builder.Consume(e)
+			}
+			if err2 != nil {
+				if !d.bestEffort {
+					fault = err2
+					break
+				}
+				errs = append(errs, err2)
+				if e.Type == StartSection {
+					skip = 1
+				}
 			}
 		}
 		if err == io.EOF {
 			break
+		} else if _, ok := err.(*SyntaxError); ok && d.bestEffort {
+			errs = append(errs, err)
 		} else if err != nil {
+			if len(errs) > 0 {
+				return append(errs, err)
+			}
 			return err
 		}
 	}
-	if fault != nil {
+	if len(errs) > 0 {
+		return errs
 	}
 	return fault
 }
 
-var (
-	rekeyvalue = regexp.MustCompile(
-		`^(?P<indent>(    )*)(?P<key>[a-zA-Z0-9][a-zA-Z0-9/]*)(\s*(?P<hasvalue>=)\s*(?P<value>[^ ].*))?$`)
-	rekeyquoted = regexp.MustCompile(
-		`^(?P<indent>(    )*)(?P<key>[a-zA-Z0-9][a-zA-Z0-9/]*)(\s*(?P<hasvalue>=)\s*"(?P<value>[^ ].*)")?$`)
-)
+// An ErrorList is returned by a Decoder in best-effort mode (see
+// SetBestEffort) to report every error that was encountered.  An error that
+// stops decoding early, such as a LimitError, comes last.
+//
+type ErrorList []error
+
+// Unwrap returns the errors in the list, so that errors.Is and errors.As
+// examine each of them.
+func (l ErrorList) Unwrap() []error {
+	return l
+}
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "zpl: no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return l[0].Error() + " (and " + strconv.Itoa(len(l)-1) + " more errors)"
+}
 
-func (d *Decoder) next() (e *parseEvent, err error) {
-	if len(d.queue) > 0 {
-		e = d.queue[0]
-		d.queue = d.queue[1:]
+// Return the next parse event.  The event is owned by the decoder and is only
+// valid until the following call to next.
+func (d *Decoder) next() (e *Event, err error) {
+	if e = d.dequeue(); e != nil {
 		return
 	}
 	var line []byte
 	for {
-		d.lineno += 1
-		for {
-			n := bytes.IndexAny(d.buffer, "\n\r")
-			if n >= 0 {
-				line = d.buffer[:n]
-				if n+1 < len(d.buffer) {
-					switch d.buffer[n] {
-					case '\r':
-						if d.buffer[n+1] == '\n' {
-							n += 1
-						}
-					case '\n':
-						if d.buffer[n+1] == '\r' {
-							n += 1
-						}
-					}
-				}
-				d.buffer = d.buffer[n+1:]
-				break
-			}
-			b := make([]byte, 64)
-			n, err = d.r.Read(b)
-			if err == io.EOF {
-				d.buffer = append(d.buffer, b[:n]...)
-				break
-			} else if err != nil {
-				return // error from Read()
-			} else {
-				d.buffer = append(d.buffer, b[:n]...)
-			}
+		if line, err = d.nextLine(); err != nil {
+			return // io.EOF or an error from Read()
 		}
-		if err == io.EOF {
-			break
-		} else if len(line) == 0 || bytes.Trim(line, " \t")[0] == '#' {
-			continue
-		} else {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) > 0 && trimmed[0] != '#' {
 			break
+		} else if len(trimmed) > 0 {
+			d.stats.Comments++
 		}
 	}
-	if err == io.EOF && len(line) == 0 {
-		if len(d.buffer) > 0 {
-			line = d.buffer
-		} else {
-			return // nothing left to read
+	d.line = line
+	depth, key, value, hasValue, ok := scanLine(line)
+	if !ok {
+		err = &SyntaxError{
+			Line: uint64(d.lineno),
+			msg:  "is neither a comment, a section header, nor a key = value setting.",
 		}
+		return
 	}
-	match := rekeyquoted.FindSubmatch(line)
-	if match == nil {
-		match = rekeyvalue.FindSubmatch(line)
+	if d.maxElements > 0 && d.stats.Sections+d.stats.Values >= uint64(d.maxElements) {
+		err = &LimitError{Limit: d.maxElements, Line: d.lineno}
+		return
 	}
-	if match != nil {
-		depth := len(match[1]) / 4
-		for depth < d.prevDepth {
-			d.queue = append(d.queue, &parseEvent{Type: endSection})
-			d.prevDepth--
-		}
-		key := string(match[3])
-		if len(match[5]) > 0 {
-			value := string(match[6])
-			d.queue = append(d.queue, &parseEvent{Type: addValue, Name: key, Value: value})
-		} else {
-			d.queue = append(d.queue, &parseEvent{Type: startSection, Name: key})
-			d.prevDepth++
+	for depth < d.prevDepth {
+		d.queue = append(d.queue, Event{Type: EndSection})
+		d.prevDepth--
+	}
+	if d.lowerKeys {
+		key = bytes.ToLower(key)
+	}
+	if hasValue {
+		text := string(value)
+		if d.lineBreaks == LineBreakEscape && len(value)+2 == len(rawValueText(line)) {
+			text = lineBreakUnescaper.Replace(text)
 		}
-		e = d.queue[0]
-		d.queue = d.queue[1:]
+		d.queue = append(d.queue, Event{Type: AddValue, Name: string(key), Value: text})
+		d.stats.Values++
 	} else {
-		err = &SyntaxError{
-			Line: uint64(d.lineno),
-			msg:  "is neither a comment, a section header, nor a key = value setting.",
+		d.queue = append(d.queue, Event{Type: StartSection, Name: string(key)})
+		d.prevDepth++
+		d.stats.Sections++
+		if d.prevDepth > d.stats.MaxDepth {
+			d.stats.MaxDepth = d.prevDepth
 		}
 	}
+	e = d.dequeue()
+	return
+}
+
+// Return the next line of input, without its line ending.  The line refers to
+// the decoder's buffer and is only valid until the following call to readLine.
+// The error is io.EOF only when there are no more lines.
+func (d *Decoder) readLine() (line []byte, err error) {
+	for {
+		if n := bytes.IndexAny(d.buffer, "\n\r"); n >= 0 {
+			line = d.buffer[:n]
+			if n+1 < len(d.buffer) {
+				switch d.buffer[n] {
+				case '\r':
+					if d.buffer[n+1] == '\n' {
+						n += 1
+					}
+				case '\n':
+					if d.buffer[n+1] == '\r' {
+						n += 1
+					}
+				}
+			}
+			d.buffer = d.buffer[n+1:]
+			d.stats.Bytes += int64(n + 1)
+			return line, nil
+		}
+		if d.eof {
+			if len(d.buffer) == 0 {
+				return nil, io.EOF
+			}
+			d.stats.Bytes += int64(len(d.buffer))
+			line, d.buffer = d.buffer, nil
+			return line, nil
+		}
+		if d.chunk == nil {
+			d.chunk = make([]byte, 64)
+		}
+		var n int
+		n, err = d.r.Read(d.chunk)
+		d.reportProgress(int64(n), err == io.EOF)
+		d.buffer = append(d.buffer, d.chunk[:n]...)
+		if err == io.EOF {
+			d.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Split a line into its indentation depth, key, and value if it has one.  The
+// line must consist of a whole number of four-space indents, then a key of
+// alphanumeric characters and slashes, then optionally an equals sign and a
+// value, which may be surrounded by whitespace.  A value in double quotes is
+// returned without them.
+func scanLine(line []byte) (depth int, key, value []byte, hasValue bool, ok bool) {
+	i := 0
+	for i < len(line) && line[i] == ' ' {
+		i++
+	}
+	if i%4 != 0 || i == len(line) || !isAlphanumeric(line[i]) {
+		return
+	}
+	depth = i / 4
+	start := i
+	for i < len(line) && (isAlphanumeric(line[i]) || line[i] == '/') {
+		i++
+	}
+	key = line[start:i]
+	if i == len(line) {
+		ok = true
+		return
+	}
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+	if i == len(line) || line[i] != '=' {
+		return
+	}
+	i++
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+	if i == len(line) {
+		return
+	}
+	value = line[i:]
+	if n := len(value); n >= 2 && value[0] == '"' && value[n-1] == '"' {
+		value = value[1 : n-1]
+	}
+	hasValue, ok = true, true
 	return
 }
 
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\f', '\r':
+		return true
+	}
+	return false
+}
+
+// Remove the first queued event, returning it or nil if the queue is empty.
+// The queue's storage is reused once it has been drained.
+func (d *Decoder) dequeue() *Event {
+	if d.qhead == len(d.queue) {
+		d.queue, d.qhead = d.queue[:0], 0
+		return nil
+	}
+	d.event = d.queue[d.qhead]
+	d.qhead++
+	if d.trace != nil {
+		d.trace(d.event, d.lineno)
+	}
+	return &d.event
+}
+
 type builder struct {
-	refs []reflect.Value
+	dec    *Decoder
+	refs   []reflect.Value
+	path   []string
+	filled map[arrayID]int
+	raw    *rawCapture // non-nil while copying a subsection into a RawSection
+	skip   int         // depth within an unknown section being ignored
+	seen   map[arrayID]bool
+
+	violations ErrorList // values that violate constraints in field tags
 }
 
-func newBuilder(v interface{}) (*builder, error) {
+// An arrayID identifies an array being filled by repeated values, either by
+// the address of the array itself or by the map and key that hold it.
+type arrayID struct {
+	ptr uintptr
+	key string
+}
+
+func newBuilder(d *Decoder, v interface{}) (*builder, error) {
 	if v == nil {
 		return nil, &InvalidUnmarshalError{nil}
 	}
@@ -245,17 +807,34 @@ func newBuilder(v interface{}) (*builder, error) {
 	switch value.Kind() {
 	case reflect.Ptr:
 		value = value.Elem()
+		// Allocate pointers, maps and interfaces as necessary, as when
+		// unmarshalling into a pointer to a nil map.
+		for value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Interface && value.NumMethod() == 0 {
+			if value.IsNil() {
+				value.Set(reflect.ValueOf(make(map[string]interface{})))
+			}
+			value = value.Elem()
+		}
 		switch value.Kind() {
-		case reflect.Map, reflect.Struct:
+		case reflect.Map:
+			if !isDecodableMap(value.Type()) {
+				err = &InvalidUnmarshalError{reflect.TypeOf(v)}
+			} else if value.IsNil() {
+				value.Set(reflect.MakeMap(value.Type()))
+			}
+		case reflect.Struct:
 			// Ok.
 		default:
 			err = &InvalidUnmarshalError{reflect.TypeOf(v)}
 		}
 	case reflect.Map:
-		switch value.Type().Key().Kind() {
-		case reflect.String:
-			// Ok.
-		default:
+		if !isDecodableMap(value.Type()) {
 			err = &InvalidUnmarshalError{reflect.TypeOf(v)}
 		}
 	default:
@@ -264,30 +843,58 @@ func newBuilder(v interface{}) (*builder, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &builder{refs: []reflect.Value{value}}, nil
+	return getBuilder(d, value), nil
 }
 
-func (b *builder) consume(e *parseEvent) error {
+func (b *builder) Consume(e *Event) error {
 	if b == nil {
 		panic("zpl: nil builder cannot consume events.")
 	}
 	if len(b.refs) == 0 {
 		panic("zpl: uninitialized builder cannot consume events.")
 	}
+	if b.raw != nil {
+		b.captureRaw(e)
+		return nil
+	}
+	if b.skip > 0 {
+		switch e.Type {
+		case StartSection:
+			b.skip++
+		case EndSection:
+			b.skip--
+		}
+		return nil
+	}
 	switch e.Type {
-	case addValue:
+	case AddValue:
 		ref := b.refs[len(b.refs)-1]
-		if err := addValueToSection(ref, e.Name, e.Value); err != nil {
+		value := e.Value
+		if b.dec.transform != nil {
+			var err error
+			if value, err = b.dec.transform(strings.Join(b.path, "/"), e.Name, value); err != nil {
+				return err
+			}
+		}
+		if value, err := b.dec.resolveSecret(value); err != nil {
 			return err
+		} else if err := b.addValueToSection(ref, e.Name, value); err != nil && !b.ignore(err, e.Name) {
+			return b.locate(err, e.Name)
 		}
-	case endSection:
+	case EndSection:
 		b.refs = b.refs[:len(b.refs)-1]
-	case startSection:
+		b.path = b.path[:len(b.path)-1]
+	case StartSection:
 		ref := b.refs[len(b.refs)-1]
-		if next, err := getSubSection(ref, e.Name); err != nil {
-			return err
-		} else {
+		if next, err := b.getSubSection(ref, e.Name); err != nil {
+			if b.ignore(err, e.Name) {
+				b.skip = 1
+				return nil
+			}
+			return b.locate(err, e.Name)
+		} else if b.raw == nil {
 			b.refs = append(b.refs, next)
+			b.path = append(b.path, e.Name)
 		}
 	default:
 		panic("zpl: program error: unsupported event type??")
@@ -295,37 +902,49 @@ func (b *builder) consume(e *parseEvent) error {
 	return nil
 }
 
-func getSubSection(section reflect.Value, name string) (sub reflect.Value, err error) {
+func (b *builder) getSubSection(section reflect.Value, name string) (sub reflect.Value, err error) {
 	if section.Type().Kind() == reflect.Map {
-		sub = section.MapIndex(reflect.ValueOf(name))
+		var key reflect.Value
+		if key, err = mapKey(section.Type(), name); err != nil {
+			return
+		}
+		sub = section.MapIndex(key)
 		if section.Type().Elem().Kind() == reflect.Interface {
 			if !sub.IsValid() || sub.IsNil() {
 				sub = reflect.ValueOf(make(map[string]interface{}))
-				section.SetMapIndex(reflect.ValueOf(name), sub)
+				section.SetMapIndex(key, sub)
 			} else {
 				sub = reflect.ValueOf(sub.Interface())
 			}
 			return
 		}
+		if section.Type().Elem() == rawSectionType {
+			sub = reflect.New(rawSectionType).Elem()
+			if old := section.MapIndex(key); old.IsValid() {
+				sub.Set(old)
+			}
+			b.startRaw(sub, section, key)
+			return
+		}
 		switch section.Type().Elem().Kind() {
 		case reflect.Ptr:
 			if !sub.IsValid() {
 				sub = reflect.New(section.Type().Elem().Elem())
-				section.SetMapIndex(reflect.ValueOf(name), sub)
+				section.SetMapIndex(key, sub)
 			} else if sub.IsNil() {
 				sub.Set(reflect.New(section.Type().Elem()))
 			}
 			sub = sub.Elem()
 			return
 		case reflect.Map:
-			if section.Type().Elem().Key().Kind() != reflect.String {
+			if !isDecodableMap(section.Type().Elem()) {
 				err = &UnmarshalTypeError{
 					Value: "subsection \"" + name + "\"",
 					Type:  section.Type().Elem(),
 				}
 			} else if !sub.IsValid() || sub.IsNil() {
 				sub = reflect.MakeMap(section.Type().Elem())
-				section.SetMapIndex(reflect.ValueOf(name), sub)
+				section.SetMapIndex(key, sub)
 			}
 			return
 		default:
@@ -336,15 +955,20 @@ func getSubSection(section reflect.Value, name string) (sub reflect.Value, err e
 			return
 		}
 	} else if section.Type().Kind() == reflect.Struct {
-		var fi = -1
 		var squash = false
-		for i := 0; i < section.NumField(); i++ {
-			tag := section.Type().Field(i).Tag
-			if string(tag) == name || tag.Get("zpl") == name {
-				fi = i
-			} else if (string(tag) == "*" || tag.Get("zpl") == "*") && fi < 0 {
-				fi = i
-				squash = true
+		fi, tag := b.findField(section.Type(), name)
+		if fi < 0 {
+			if inner := b.flattenedStruct(section, name); inner.IsValid() {
+				return b.getSubSection(inner, name)
+			}
+		}
+		if fi < 0 {
+			for i, fieldTag := range fieldTags(section.Type(), b.dec.jsonTags) {
+				if fieldTag.Name == "*" {
+					fi = i
+					squash = true
+					break
+				}
 			}
 		}
 		if fi == -1 {
@@ -356,7 +980,7 @@ func getSubSection(section reflect.Value, name string) (sub reflect.Value, err e
 		}
 		field := section.Field(fi)
 		if field.Type().Kind() == reflect.Map {
-			if field.Type().Key().Kind() != reflect.String {
+			if !isDecodableMap(field.Type()) {
 				err = &UnmarshalTypeError{
 					Value: "subsection \"" + name + "\"",
 					Type:  field.Type(),
@@ -370,7 +994,7 @@ func getSubSection(section reflect.Value, name string) (sub reflect.Value, err e
 				sub = field
 			} else {
 				helper := field
-				sub, err = getSubSection(helper, name)
+				sub, err = b.getSubSection(helper, name)
 				if err != nil {
 					return
 				}
@@ -380,9 +1004,18 @@ func getSubSection(section reflect.Value, name string) (sub reflect.Value, err e
 				field.Set(reflect.New(field.Type().Elem()))
 			}
 			sub = field.Elem()
+		} else if field.Type() == rawSectionType {
+			sub = field
+			b.startRaw(sub, reflect.Value{}, reflect.Value{})
+		} else if _, ok := tag.option("indexed"); ok && isSectionSlice(field.Type()) {
+			sub = field
+		} else if isSectionSlice(field.Type()) {
+			sub = appendSection(field)
 		} else {
 			err = errors.New("zpl: cannot unmarshal into " + field.Type().String())
 		}
+	} else if isSectionSlice(section.Type()) {
+		sub, err = indexSection(section, name)
 	} else {
 		err = &InvalidUnmarshalError{Type: section.Type()}
 		return
@@ -393,18 +1026,229 @@ func getSubSection(section reflect.Value, name string) (sub reflect.Value, err e
 	return
 }
 
-func addValueToSection(section reflect.Value, name string, value string) error {
+// Report whether typ is a slice of structs, pointers to structs or maps with
+// string keys, each element of which holds one of a repeated section.
+func isSectionSlice(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Slice {
+		return false
+	}
+	elem := typ.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	return elem.Kind() == reflect.Struct ||
+		elem.Kind() == reflect.Map && elem.Key().Kind() == reflect.String
+}
+
+// Append a new element to a slice for which isSectionSlice is true, returning
+// the struct or map that the section should be decoded into.
+func appendSection(slice reflect.Value) reflect.Value {
+	elem := reflect.New(slice.Type().Elem()).Elem()
+	switch elem.Kind() {
+	case reflect.Ptr:
+		elem.Set(reflect.New(elem.Type().Elem()))
+	case reflect.Map:
+		elem.Set(reflect.MakeMap(elem.Type()))
+	}
+	slice.Set(reflect.Append(slice, elem))
+	sub := slice.Index(slice.Len() - 1)
+	if sub.Kind() == reflect.Ptr {
+		sub = sub.Elem()
+	}
+	return sub
+}
+
+// Return the element of a slice for which isSectionSlice is true at the
+// index given by name, growing the slice and allocating the element as
+// needed.  The result is the struct or map that the section should be
+// decoded into.
+func indexSection(slice reflect.Value, name string) (sub reflect.Value, err error) {
+	index, err := strconv.Atoi(name)
+	if err != nil || index < 0 || strconv.Itoa(index) != name {
+		return sub, errors.New("zpl: section name \"" + name + "\" is not an index")
+	}
+	if index >= slice.Len() {
+		grown := reflect.MakeSlice(slice.Type(), index+1, index+1)
+		reflect.Copy(grown, slice)
+		slice.Set(grown)
+	}
+	sub = slice.Index(index)
+	switch sub.Kind() {
+	case reflect.Ptr:
+		if sub.IsNil() {
+			sub.Set(reflect.New(sub.Type().Elem()))
+		}
+		sub = sub.Elem()
+	case reflect.Map:
+		if sub.IsNil() {
+			sub.Set(reflect.MakeMap(sub.Type()))
+		}
+	}
+	return sub, nil
+}
+
+// Report whether err is an unknown key that should be ignored, recording a
+// warning if so.
+func (b *builder) ignore(err error, key string) bool {
+	if _, ok := err.(*UnmarshalFieldError); !ok || !b.dec.ignoreUnknown {
+		return false
+	}
+	path := strings.Join(append(b.path[:len(b.path):len(b.path)], key), "/")
+	b.dec.warn(WarnUnknownKey, key, "unknown key \""+path+"\" was ignored")
+	return true
+}
+
+// Record a warning if a key that holds a single value of type typ, identified
+// by id, has already been given a value in the same section.
+func (b *builder) checkOverwrite(id arrayID, typ reflect.Type, key string) {
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Interface:
+		return
+	case reflect.String:
+		if b.dec.lineBreaks == LineBreakSplit {
+			return
+		}
+	}
+	if b.seen[id] {
+		path := strings.Join(append(b.path[:len(b.path):len(b.path)], key), "/")
+		b.dec.warn(WarnOverwritten, key, "key \""+path+"\" is repeated, so its earlier value was replaced")
+	}
+	b.seen[id] = true
+}
+
+// Record the path and line of key in err if it is an error that has them.
+func (b *builder) locate(err error, key string) error {
+	path := strings.Join(append(b.path[:len(b.path):len(b.path)], key), "/")
+	switch e := err.(type) {
+	case *UnmarshalFieldError:
+		if e.Path == "" {
+			e.Path, e.Line = path, b.dec.lineno
+			e.Suggestions = suggestKeys(e.Type, e.Key, b.dec.jsonTags)
+		}
+	case *UnmarshalTypeError:
+		if e.Path == "" {
+			e.Path, e.Line = path, b.dec.lineno
+		}
+	case *UnmarshalOverflowError:
+		if e.Path == "" {
+			e.Path, e.Line = path, b.dec.lineno
+		}
+	}
+	return err
+}
+
+// Find the struct field that key name should be stored in, returning its index
+// and parsed tag or -1 if there is none.  A field whose tag lists name after
+// its first name, as in "addr|address", matches only if no field's first name
+// is name.  A field that lists name as an alias matches only if no field
+// accepts name otherwise, and using an alias adds a warning to the decoder.
+func (b *builder) findField(typ reflect.Type, name string) (index int, tag tagInfo) {
+	index, tag, deprecated := b.lookupField(typ, name)
+	if deprecated {
+		b.dec.warn(WarnDeprecated, name, "key \""+name+"\" is deprecated, use \""+tag.Name+"\" instead")
+	}
+	return
+}
+
+// Find the struct field for key name as findField does, without warning about
+// aliases but reporting whether name is one.
+func (b *builder) lookupField(typ reflect.Type, name string) (index int, tag tagInfo, deprecated bool) {
+	index = -1
+	other, alias := -1, -1
+	var otherTag, aliasTag tagInfo
+	for i, fieldTag := range fieldTags(typ, b.dec.jsonTags) {
+		if fieldTag.Name == name {
+			index, tag = i, fieldTag
+		} else if other < 0 && fieldTag.hasName(name) {
+			other, otherTag = i, fieldTag
+		} else if alias < 0 && fieldTag.hasOptionValue("alias", name) {
+			alias, aliasTag = i, fieldTag
+		}
+	}
+	if index < 0 && other >= 0 {
+		index, tag = other, otherTag
+	} else if index < 0 && alias >= 0 {
+		index, tag, deprecated = alias, aliasTag, true
+	}
+	return
+}
+
+// Report whether a struct of type typ has a field for key name, either itself
+// or within a field tagged ",flatten".
+func (b *builder) hasField(typ reflect.Type, name string) bool {
+	if fi, _, _ := b.lookupField(typ, name); fi >= 0 {
+		return true
+	}
+	for i, tag := range fieldTags(typ, b.dec.jsonTags) {
+		if inner, ok := flattenedType(typ.Field(i), tag); ok && b.hasField(inner, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the struct type of field if its tag has the "flatten" option so that
+// its fields belong to the enclosing section.
+func flattenedType(field reflect.StructField, tag tagInfo) (reflect.Type, bool) {
+	if _, ok := tag.option("flatten"); !ok {
+		return nil, false
+	}
+	typ := field.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ, typ.Kind() == reflect.Struct
+}
+
+// Return the struct, among those in fields of section tagged ",flatten", that
+// has a field for key name, allocating pointers as necessary, or an invalid
+// value if there is none.
+func (b *builder) flattenedStruct(section reflect.Value, name string) reflect.Value {
+	for i, tag := range fieldTags(section.Type(), b.dec.jsonTags) {
+		inner, ok := flattenedType(section.Type().Field(i), tag)
+		if !ok || !b.hasField(inner, name) {
+			continue
+		}
+		field := section.Field(i)
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				field.Set(reflect.New(inner))
+			}
+			field = field.Elem()
+		}
+		if fi, _, _ := b.lookupField(inner, name); fi >= 0 {
+			return field
+		}
+		return b.flattenedStruct(field, name)
+	}
+	return reflect.Value{}
+}
+
+func (b *builder) addValueToSection(section reflect.Value, name string, value string) error {
 	switch section.Type().Kind() {
 	case reflect.Map:
-		if section.Type().Key().Kind() != reflect.String {
+		if !isDecodableMap(section.Type()) {
 			return &UnmarshalTypeError{
-				"value for key \"" + name + "\"",
-				section.Type(),
+				Value: "value for key \"" + name + "\"",
+				Type:  section.Type(),
 			}
 		}
-		key := reflect.ValueOf(name)
+		key, err := mapKey(section.Type(), name)
+		if err != nil {
+			return err
+		}
 		existing := section.MapIndex(key)
-		adjusted, err := appendValue(section.Type().Elem(), existing, value)
+		b.checkOverwrite(arrayID{ptr: section.Pointer(), key: name}, section.Type().Elem(), name)
+		var adjusted reflect.Value
+		if b.dec.lineBreaks == LineBreakSplit {
+			value = b.joinLines(arrayID{ptr: section.Pointer(), key: name}, section.Type().Elem(), existing, value)
+		}
+		if section.Type().Elem().Kind() == reflect.Array {
+			id := arrayID{ptr: section.Pointer(), key: name}
+			adjusted, err = b.appendArrayValue(id, name, section.Type().Elem(), existing, value)
+		} else {
+			adjusted, err = b.appendValue(section.Type().Elem(), existing, value)
+		}
 		if err != nil {
 			return err
 		}
@@ -412,21 +1256,42 @@ func addValueToSection(section reflect.Value, name string, value string) error {
 			section.SetMapIndex(key, adjusted)
 		}
 	case reflect.Ptr, reflect.Struct:
-		var fi = -1
-		for i := 0; i < section.NumField(); i++ {
-			tag := section.Type().Field(i).Tag
-			if string(tag) == name || tag.Get("zpl") == name {
-				fi = i
-			}
-		}
+		fi, tag := b.findField(section.Type(), name)
 		if fi == -1 {
+			if inner := b.flattenedStruct(section, name); inner.IsValid() {
+				return b.addValueToSection(inner, name, value)
+			}
 			return &UnmarshalFieldError{
 				Key:  name,
 				Type: section.Type(),
 			}
 		}
 		existing := section.Field(fi)
-		adjusted, err := appendValue(existing.Type(), existing, value)
+		if format := tag.Options["format"]; isBinaryFormat(existing.Type(), format) {
+			return b.addBinaryValue(existing, format, value)
+		}
+		b.checkOverwrite(arrayID{ptr: existing.UnsafeAddr(), key: name}, existing.Type(), name)
+		written := value
+		if tag.Options["format"] == "size" {
+			var ok bool
+			if value, ok = expandSize(value); !ok {
+				return &UnmarshalTypeError{Value: "size " + value, Type: existing.Type()}
+			}
+		}
+		b.checkConstraints(name, tag, written, value)
+		var (
+			adjusted reflect.Value
+			err      error
+		)
+		if b.dec.lineBreaks == LineBreakSplit {
+			value = b.joinLines(arrayID{ptr: existing.UnsafeAddr()}, existing.Type(), existing, value)
+		}
+		if existing.Kind() == reflect.Array {
+			id := arrayID{ptr: existing.UnsafeAddr()}
+			adjusted, err = b.appendArrayValue(id, name, existing.Type(), existing, value)
+		} else {
+			adjusted, err = b.appendValue(existing.Type(), existing, value)
+		}
 		if err != nil {
 			return err
 		}
@@ -444,81 +1309,99 @@ func addValueToSection(section reflect.Value, name string, value string) error {
 	return nil
 }
 
+// Store value in the next unfilled element of an array, returning a copy of
+// the array with the value in place.
+func (b *builder) appendArrayValue(id arrayID, name string, typ reflect.Type, target reflect.Value, value string) (result reflect.Value, err error) {
+	n := b.filled[id]
+	if n >= typ.Len() {
+		err = &UnmarshalOverflowError{Key: name, Type: typ}
+		return
+	}
+	var elem reflect.Value
+	if elem, err = b.appendValue(typ.Elem(), elem, value); err != nil {
+		return
+	}
+	result = reflect.New(typ).Elem()
+	if target.IsValid() {
+		result.Set(target)
+	}
+	result.Index(n).Set(elem)
+	b.filled[id] = n + 1
+	return
+}
+
 // Append value to target or return a new value of type typ.
-func appendValue(typ reflect.Type, target reflect.Value, value string) (result reflect.Value, err error) {
+func (b *builder) appendValue(typ reflect.Type, target reflect.Value, value string) (result reflect.Value, err error) {
 	if target.IsValid() {
 		typ = target.Type()
 	}
+	if typ == rawValueType {
+		value = rawValueText(b.dec.line)
+	}
+	if isNullType(typ) {
+		return b.appendNullValue(typ, value)
+	}
+	if isBigType(typ) {
+		return parseBig(typ, value, b.intBase(value))
+	}
 	if typ.Kind() == reflect.Interface {
+		if b.dec.interfaceMode != InterfaceSlices {
+			return b.appendInterfaceValue(target, value)
+		}
 		typ = reflect.TypeOf([]string{})
 	}
 	switch typ.Kind() {
 	case reflect.Bool:
-		if parsed, err2 := strconv.ParseBool(value); err2 != nil {
-			err = &UnmarshalTypeError{Value: value, Type: typ}
+		if parsed, err2 := b.parseBool(value); err2 != nil {
+			err = &UnmarshalTypeError{Value: value, Type: typ, Err: err2}
 		} else if target.IsValid() && target.CanSet() {
 			target.SetBool(parsed)
 		} else {
-			result = reflect.ValueOf(parsed)
+			result = reflect.New(typ).Elem()
+			result.SetBool(parsed)
 		}
 	case reflect.Float32, reflect.Float64:
 		if parsed, err2 := strconv.ParseFloat(value, typ.Bits()); err2 != nil {
-			err = &UnmarshalTypeError{Value: value, Type: typ}
+			err = &UnmarshalTypeError{Value: value, Type: typ, Err: err2}
 		} else if target.IsValid() && target.CanSet() {
 			target.SetFloat(parsed)
 		} else {
-			switch typ.Kind() {
-			case reflect.Float32:
-				result = reflect.ValueOf(float32(parsed))
-			default:
-				result = reflect.ValueOf(parsed)
-			}
+			result = reflect.New(typ).Elem()
+			result.SetFloat(parsed)
 		}
-	case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64:
-		if parsed, err2 := strconv.ParseInt(value, 10, typ.Bits()); err2 != nil {
-			err = &UnmarshalTypeError{Value: value, Type: typ}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if parsed, err2 := strconv.ParseInt(value, b.intBase(value), typ.Bits()); err2 != nil {
+			err = &UnmarshalTypeError{Value: value, Type: typ, Err: err2}
 		} else if target.IsValid() && target.CanSet() {
 			target.SetInt(parsed)
 		} else {
-			switch typ.Kind() {
-			case reflect.Int:
-				result = reflect.ValueOf(int(parsed))
-			case reflect.Int16:
-				result = reflect.ValueOf(int16(parsed))
-			case reflect.Int32:
-				result = reflect.ValueOf(int32(parsed))
-			default:
-				result = reflect.ValueOf(parsed)
-			}
-		}
-	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		if parsed, err2 := strconv.ParseUint(value, 10, typ.Bits()); err2 != nil {
-			err = &UnmarshalTypeError{Value: value, Type: typ}
+			result = reflect.New(typ).Elem()
+			result.SetInt(parsed)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if parsed, err2 := strconv.ParseUint(value, b.intBase(value), typ.Bits()); err2 != nil {
+			err = &UnmarshalTypeError{Value: value, Type: typ, Err: err2}
 		} else if target.IsValid() && target.CanSet() {
 			target.SetUint(parsed)
 		} else {
-			switch typ.Kind() {
-			case reflect.Uint:
-				result = reflect.ValueOf(uint(parsed))
-			case reflect.Uint16:
-				result = reflect.ValueOf(uint16(parsed))
-			case reflect.Uint32:
-				result = reflect.ValueOf(uint32(parsed))
-			default:
-				result = reflect.ValueOf(parsed)
-			}
+			result = reflect.New(typ).Elem()
+			result.SetUint(parsed)
 		}
 	case reflect.Ptr:
 		result = reflect.New(typ.Elem())
 		var elem reflect.Value
-		if elem, err = appendValue(typ.Elem(), elem, value); err == nil {
+		if elem, err = b.appendValue(typ.Elem(), elem, value); err == nil {
 			result.Elem().Set(elem)
 		}
 	case reflect.String:
-		result = reflect.ValueOf(value)
+		if typ == numberType && !isNumber(value) {
+			err = &UnmarshalTypeError{Value: value, Type: typ}
+		} else {
+			result = reflect.ValueOf(value).Convert(typ)
+		}
 	case reflect.Slice:
 		var next reflect.Value
-		next, err = appendValue(typ.Elem(), next, value)
+		next, err = b.appendValue(typ.Elem(), next, value)
 		if err == nil && next.IsValid() {
 			result = target
 			if result.IsValid() && result.Type().Kind() == reflect.Interface {
@@ -538,20 +1421,138 @@ func appendValue(typ reflect.Type, target reflect.Value, value string) (result r
 	return
 }
 
-type (
-	eventType  int
-	parseEvent struct {
-		Type  eventType
-		Name  string
-		Value string
+// Return the base in which to parse value as an integer: 10, or 0 to let
+// strconv choose based on a "0x", "0o" or "0b" prefix if the decoder allows
+// them.  A leading zero without such a prefix never selects octal, even when
+// followed by an underscore as in "0_17".
+func (b *builder) intBase(value string) int {
+	if !b.dec.basePrefixes {
+		return 10
 	}
-	sink interface {
-		consume(*parseEvent) error
+	digits := strings.TrimLeft(value, "+-")
+	if len(digits) > 1 && digits[0] == '0' && (digits[1] >= '0' && digits[1] <= '9' || digits[1] == '_') {
+		return 10
 	}
-)
+	return 0
+}
 
-const (
-	addValue eventType = iota
-	endSection
-	startSection
-)
+// Parse value as a bool according to the decoder's settings.
+func (b *builder) parseBool(value string) (bool, error) {
+	if b.dec.lenientBools {
+		switch strings.ToLower(value) {
+		case "true", "yes", "on":
+			return true, nil
+		case "false", "no", "off":
+			return false, nil
+		}
+	}
+	return strconv.ParseBool(value)
+}
+
+// Store value in an interface according to the decoder's InterfaceMode,
+// upgrading a single value already stored there to a slice of values.
+func (b *builder) appendInterfaceValue(target reflect.Value, value string) (result reflect.Value, err error) {
+	var prev interface{}
+	if target.IsValid() && !(target.Kind() == reflect.Interface && target.IsNil()) {
+		prev = target.Interface()
+	}
+	if b.dec.interfaceMode == InterfaceTyped {
+		next := inferScalar(value)
+		if b.dec.useNumber {
+			switch next.(type) {
+			case int64, float64:
+				next = Number(value)
+			}
+		}
+		switch p := prev.(type) {
+		case nil:
+			result = reflect.ValueOf(next)
+		case []interface{}:
+			result = reflect.ValueOf(append(p, next))
+		default:
+			result = reflect.ValueOf([]interface{}{p, next})
+		}
+		return
+	}
+	switch p := prev.(type) {
+	case nil:
+		result = reflect.ValueOf(value)
+	case string:
+		result = reflect.ValueOf([]string{p, value})
+	case []string:
+		result = reflect.ValueOf(append(p, value))
+	default:
+		err = &UnmarshalTypeError{Value: value, Type: reflect.TypeOf(prev)}
+	}
+	return
+}
+
+// A Number represents a ZPL number exactly as it was written, so that large
+// integers and high-precision decimals survive decoding without rounding.
+//
+type Number string
+
+var numberType = reflect.TypeOf(Number(""))
+
+// String returns the literal text of the number.
+func (n Number) String() string { return string(n) }
+
+// Float64 returns the number as a float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// Int64 returns the number as an int64.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// Report whether value is a decimal integer or floating point number.
+func isNumber(value string) bool {
+	if !looksNumeric(value) {
+		return false
+	}
+	_, err := strconv.ParseFloat(value, 64)
+	if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+		return true
+	}
+	return err == nil
+}
+
+// Interpret value as an int64, float64 or bool if it looks like one,
+// otherwise return it unchanged as a string.
+func inferScalar(value string) interface{} {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if !looksNumeric(value) {
+		return value
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	return value
+}
+
+// Report whether value consists only of characters that may appear in a
+// decimal number, so that words like "inf" and "nan" remain strings.
+func looksNumeric(value string) bool {
+	if len(value) == 0 {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c >= '0' && c <= '9':
+		case c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E':
+		default:
+			return false
+		}
+	}
+	return true
+}
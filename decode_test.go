@@ -6,6 +6,11 @@ package zpl
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"testing/iotest"
@@ -166,7 +171,7 @@ func TestUnmarshal_Reflect(t *testing.T) {
 			*conf.Devices["main"].Sockets["frontend"].Options.Hwm)
 	}
 	if len(conf.Devices["main"].Sockets["frontend"].Options.Swap) != 1 {
-		t.Errorf("len(main/frontend/hwm) = %s", len(conf.Devices["main"].Sockets["frontend"].Options.Swap))
+		t.Errorf("len(main/frontend/hwm) = %d", len(conf.Devices["main"].Sockets["frontend"].Options.Swap))
 	} else if conf.Devices["main"].Sockets["frontend"].Options.Swap[0] != 25000000 {
 		t.Errorf("main/frontend/hwm[0] = %v",
 			conf.Devices["main"].Sockets["frontend"].Options.Swap[0])
@@ -360,4 +365,699 @@ func TestDecoder_Decode_Slice(t *testing.T) {
 	test(make(map[string]uint16), func(m interface{}) int { return int(m.(map[string]uint16)["key"]) })
 	test(make(map[string]uint32), func(m interface{}) int { return int(m.(map[string]uint32)["key"]) })
 	test(make(map[string]uint64), func(m interface{}) int { return int(m.(map[string]uint64)["key"]) })
+	test(make(map[string]int8), func(m interface{}) int { return int(m.(map[string]int8)["key"]) })
+	test(make(map[string]uint8), func(m interface{}) int { return int(m.(map[string]uint8)["key"]) })
+	test(make(map[string]uintptr), func(m interface{}) int { return int(m.(map[string]uintptr)["key"]) })
+	test(make(map[string][]*int8), func(m interface{}) int { return int(*m.(map[string][]*int8)["key"][0]) })
+	test(make(map[string][]byte), func(m interface{}) int { return int(m.(map[string][]byte)["key"][0]) })
+	test(make(map[string]port), func(m interface{}) int { return int(m.(map[string]port)["key"]) })
+}
+
+type port uint16
+
+type kindsMock struct {
+	Int8  int8    `zpl:"int8"`
+	Uint8 uint8   `zpl:"uint8"`
+	Byte  byte    `zpl:"byte"`
+	Port  port    `zpl:"port"`
+	Ptr   *uint8  `zpl:"ptr"`
+	Uptr  uintptr `zpl:"uptr"`
+}
+
+func TestDecoder_Decode_IntegerKinds(t *testing.T) {
+	var s kindsMock
+	raw := []byte("int8 = -128\nuint8 = 255\nbyte = 7\nport = 5555\nptr = 1\nuptr = 4096")
+	if err := Unmarshal(raw, &s); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	if s.Int8 != -128 || s.Uint8 != 255 || s.Byte != 7 || s.Port != 5555 || *s.Ptr != 1 || s.Uptr != 4096 {
+		t.Errorf("unexpected result: %+v", s)
+	}
+	for _, bad := range []string{"int8 = 128", "uint8 = 256", "uint8 = -1"} {
+		if err := Unmarshal([]byte(bad), &s); err == nil {
+			t.Errorf("expected error unmarshalling %q, got success.", bad)
+		} else if _, ok := err.(*UnmarshalTypeError); !ok {
+			t.Errorf("expected UnmarshalTypeError, got %T: %s", err, err.Error())
+		}
+	}
+}
+
+type arrayMock struct {
+	Bind [2]string `zpl:"bind"`
+}
+
+func TestDecoder_Decode_Array(t *testing.T) {
+	var s arrayMock
+	if err := Unmarshal([]byte("bind = a\nbind = b"), &s); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	} else if s.Bind != [2]string{"a", "b"} {
+		t.Errorf("bind = %v", s.Bind)
+	}
+	m := make(map[string][3]int)
+	if err := Unmarshal([]byte("key = 1\nkey = 2"), m); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	} else if m["key"] != [3]int{1, 2, 0} {
+		t.Errorf("key = %v", m["key"])
+	}
+	err := Unmarshal([]byte("bind = a\nbind = b\nbind = c"), &arrayMock{})
+	if err == nil {
+		t.Errorf("expected error, got success.")
+	} else if e, ok := err.(*UnmarshalOverflowError); !ok {
+		t.Errorf("expected UnmarshalOverflowError, got %T: %s", err, err.Error())
+	} else if e.Path != "bind" || e.Line != 3 || !strings.HasPrefix(e.Error(), "zpl: bind (line 3): ") {
+		t.Errorf("unexpected location: %s", e)
+	}
+}
+
+func TestDecoder_SetInterfaceMode(t *testing.T) {
+	m := make(map[string]interface{})
+	d := NewDecoder(bytes.NewReader([]byte("one = 1\nmany = a\nmany = b\nmany = c\nsection\n    key = v")))
+	d.SetInterfaceMode(InterfaceStrings)
+	if err := d.Decode(m); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if one, ok := m["one"].(string); !ok || one != "1" {
+		t.Errorf("one = %#v", m["one"])
+	}
+	if many, ok := m["many"].([]string); !ok || len(many) != 3 || many[2] != "c" {
+		t.Errorf("many = %#v", m["many"])
+	}
+	section := m["section"].(map[string]interface{})
+	if key, ok := section["key"].(string); !ok || key != "v" {
+		t.Errorf("section/key = %#v", section["key"])
+	}
+}
+
+func TestDecoder_SetInterfaceMode_Typed(t *testing.T) {
+	m := make(map[string]interface{})
+	d := NewDecoder(bytes.NewReader([]byte("i = 1\nf = 0.5\nb = true\ns = tcp://eth0:5555\nn = nan\nr = 1\nr = no")))
+	d.SetInterfaceMode(InterfaceTyped)
+	if err := d.Decode(m); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	expect := map[string]interface{}{
+		"i": int64(1),
+		"f": float64(0.5),
+		"b": true,
+		"s": "tcp://eth0:5555",
+		"n": "nan",
+	}
+	for k, v := range expect {
+		if m[k] != v {
+			t.Errorf("%s = %#v, expected %#v", k, m[k], v)
+		}
+	}
+	if r, ok := m["r"].([]interface{}); !ok || len(r) != 2 || r[0] != int64(1) || r[1] != "no" {
+		t.Errorf("r = %#v", m["r"])
+	}
+}
+
+type numberMock struct {
+	Big Number `zpl:"big"`
+}
+
+func TestDecoder_UseNumber(t *testing.T) {
+	m := make(map[string]interface{})
+	d := NewDecoder(bytes.NewReader([]byte("big = 123456789012345678901234567890\nf = 0.10000000000000000001")))
+	d.SetInterfaceMode(InterfaceTyped)
+	d.UseNumber()
+	if err := d.Decode(m); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if m["big"] != Number("123456789012345678901234567890") {
+		t.Errorf("big = %#v", m["big"])
+	}
+	if f, err := m["f"].(Number).Float64(); err != nil || f != 0.1 {
+		t.Errorf("f = %v (%v)", f, err)
+	}
+	var s numberMock
+	if err := Unmarshal([]byte("big = 18446744073709551616"), &s); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	} else if s.Big != "18446744073709551616" {
+		t.Errorf("big = %v", s.Big)
+	} else if _, err := s.Big.Int64(); err == nil {
+		t.Errorf("expected range error from Int64")
+	}
+	if err := Unmarshal([]byte("big = many"), &s); err == nil {
+		t.Errorf("expected error, got success.")
+	}
+}
+
+func TestDecoder_SetLenientBools(t *testing.T) {
+	raw := []byte("a = yes\nb = OFF\nc = On\nd = True\ne = 0")
+	m := make(map[string]bool)
+	if err := Unmarshal(raw, m); err == nil {
+		t.Errorf("expected error without lenient bools, got success.")
+	}
+	m = make(map[string]bool)
+	d := NewDecoder(bytes.NewReader(raw))
+	d.SetLenientBools(true)
+	if err := d.Decode(m); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	expect := map[string]bool{"a": true, "b": false, "c": true, "d": true, "e": false}
+	for k, v := range expect {
+		if m[k] != v {
+			t.Errorf("%s = %v, expected %v", k, m[k], v)
+		}
+	}
+}
+
+type sizeMock struct {
+	Swap  int64    `zpl:"swap,format=size"`
+	Hwm   *uint32  `zpl:"hwm,format=size"`
+	Sizes []uint64 `zpl:"size,format=size"`
+}
+
+func TestDecoder_Decode_FormatSize(t *testing.T) {
+	var s sizeMock
+	raw := []byte("swap = 25M\nhwm = 512k\nsize = 1G\nsize = 100")
+	if err := Unmarshal(raw, &s); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	if s.Swap != 25<<20 {
+		t.Errorf("swap = %d", s.Swap)
+	}
+	if s.Hwm == nil || *s.Hwm != 512<<10 {
+		t.Errorf("hwm = %v", s.Hwm)
+	}
+	if len(s.Sizes) != 2 || s.Sizes[0] != 1<<30 || s.Sizes[1] != 100 {
+		t.Errorf("size = %v", s.Sizes)
+	}
+	for _, bad := range []string{"swap = 25X", "swap = M", "hwm = 8G", "swap = 99999999T"} {
+		if err := Unmarshal([]byte(bad), &s); err == nil {
+			t.Errorf("expected error unmarshalling %q, got success.", bad)
+		} else if _, ok := err.(*UnmarshalTypeError); !ok {
+			t.Errorf("expected UnmarshalTypeError, got %T: %s", err, err.Error())
+		}
+	}
+}
+
+func TestDecoder_SetValueTransformer(t *testing.T) {
+	var conf ZdcfRoot
+	var paths []string
+	d := NewDecoder(bytes.NewReader(raw0))
+	d.SetValueTransformer(func(path, key, value string) (string, error) {
+		paths = append(paths, path+":"+key)
+		if key == "bind" {
+			return strings.Replace(value, "eth0", "lo", 1), nil
+		}
+		return value, nil
+	})
+	if err := d.Decode(&conf); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if bind := conf.Devices["main"].Sockets["backend"].Bind[0]; bind != "tcp://lo:5556" {
+		t.Errorf("main/backend/bind[0] = %v", bind)
+	}
+	if paths[0] != ":version" || paths[len(paths)-1] != "main/backend:bind" {
+		t.Errorf("unexpected paths: %v", paths)
+	}
+	d = NewDecoder(bytes.NewReader(raw0))
+	d.SetValueTransformer(func(path, key, value string) (string, error) {
+		return "", errors.New("refused")
+	})
+	if err := d.Decode(&conf); err == nil || err.Error() != "refused" {
+		t.Errorf("expected transformer error, got %v", err)
+	}
+}
+
+type jsonMock struct {
+	Address string `json:"addr"`
+	Port    int    `json:"port,omitempty" zpl:"p"`
+	Skipped string `json:"-"`
+	Region  string `json:",omitempty"`
+}
+
+func TestDecoder_SetJSONTagFallback(t *testing.T) {
+	var s jsonMock
+	raw := []byte("addr = localhost\np = 80\nRegion = eu")
+	if err := Unmarshal(raw, &s); err == nil {
+		t.Errorf("expected error without json tag fallback, got success.")
+	}
+	d := NewDecoder(bytes.NewReader(raw))
+	d.SetJSONTagFallback(true)
+	if err := d.Decode(&s); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if s.Address != "localhost" || s.Port != 80 || s.Region != "eu" {
+		t.Errorf("unexpected result: %+v", s)
+	}
+}
+
+type aliasMock struct {
+	Address string       `zpl:"address,alias=addr,alias=host"`
+	Options *ZdcfOptions `zpl:"options,alias=option"`
+}
+
+func TestDecoder_Decode_Alias(t *testing.T) {
+	var s aliasMock
+	d := NewDecoder(bytes.NewReader([]byte("address = a\nhost = b\noption\n    hwm = 1")))
+	if err := d.Decode(&s); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if s.Address != "b" {
+		t.Errorf("address = %v", s.Address)
+	}
+	if s.Options == nil || *s.Options.Hwm != 1 {
+		t.Errorf("options = %+v", s.Options)
+	}
+	warnings := d.Warnings()
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", warnings)
+	}
+	if warnings[0].Key != "host" || warnings[0].Line != 2 {
+		t.Errorf("unexpected warning: %+v", warnings[0])
+	}
+	if !strings.Contains(warnings[1].String(), "\"options\"") {
+		t.Errorf("unexpected warning: %s", warnings[1])
+	}
+}
+
+func TestDecoder_Warnings_Kinds(t *testing.T) {
+	var s struct {
+		Type  string            `zpl:"type"`
+		Bind  []string          `zpl:"bind"`
+		Extra map[string]string `zpl:"extra"`
+	}
+	d := NewDecoder(strings.NewReader("type = a\nbind = x\nbind = y\nhwm = 1\nplugin\n    x = 1\n    sub\n        y = 2\ntype = b\nextra\n    k = 1\n    k = 2\n"))
+	d.SetIgnoreUnknownKeys(true)
+	if err := d.Decode(&s); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if s.Type != "b" || len(s.Bind) != 2 || s.Extra["k"] != "2" {
+		t.Errorf("decoded %+v", s)
+	}
+	var kinds []string
+	for _, w := range d.Warnings() {
+		kinds = append(kinds, fmt.Sprintf("%d %s %s", w.Line, w.Kind, w.Key))
+	}
+	expected := []string{"4 unknown key hwm", "5 unknown key plugin", "9 overwritten type", "12 overwritten k"}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("warnings = %q", kinds)
+	}
+	d = NewDecoder(strings.NewReader("hwm = 1\n"))
+	if err := d.Decode(&s); err == nil {
+		t.Errorf("expected an error for an unknown key by default")
+	}
+}
+
+func TestUnmarshalTypeError_Unwrap(t *testing.T) {
+	var v struct {
+		Port int8 `zpl:"port"`
+	}
+	cases := []struct {
+		Src string
+		Err error
+	}{
+		{"port = 300\n", strconv.ErrRange},
+		{"port = eighty\n", strconv.ErrSyntax},
+	}
+	for _, c := range cases {
+		err := Unmarshal([]byte(c.Src), &v)
+		var numErr *strconv.NumError
+		if !errors.As(err, &numErr) || numErr.Err != c.Err {
+			t.Errorf("%q: expected a NumError wrapping %v, got %v", c.Src, c.Err, err)
+		}
+		if !errors.Is(err, c.Err) || !strings.HasSuffix(err.Error(), c.Err.Error()) {
+			t.Errorf("%q: unexpected error: %v", c.Src, err)
+		}
+	}
+}
+
+type namesMock struct {
+	Addr    string `zpl:"addr|address|host"`
+	Address string `zpl:"address"`
+	Port    int    `port|listen`
+}
+
+func TestDecoder_Decode_Names(t *testing.T) {
+	var s namesMock
+	d := NewDecoder(bytes.NewReader([]byte("host = a\naddress = b\nlisten = 80")))
+	if err := d.Decode(&s); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if s.Addr != "a" || s.Address != "b" || s.Port != 80 {
+		t.Errorf("decoded %+v", s)
+	}
+	if warnings := d.Warnings(); len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	out, err := Marshal(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "addr = a\naddress = b\nport = 80\n"; string(out) != expect {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+}
+
+func TestDecoder_SetBasePrefixes(t *testing.T) {
+	raw := []byte("hex = 0x1F4\noct = 0o755\nbin = 0b1010\ndec = 0755\nneg = -0x10")
+	m := make(map[string]int)
+	if err := Unmarshal(raw, m); err == nil {
+		t.Errorf("expected error without base prefixes, got success.")
+	}
+	m = make(map[string]int)
+	d := NewDecoder(bytes.NewReader(raw))
+	d.SetBasePrefixes(true)
+	if err := d.Decode(m); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	expect := map[string]int{"hex": 500, "oct": 493, "bin": 10, "dec": 755, "neg": -16}
+	for k, v := range expect {
+		if m[k] != v {
+			t.Errorf("%s = %d, expected %d", k, m[k], v)
+		}
+	}
+	u := make(map[string]uint16)
+	d = NewDecoder(bytes.NewReader([]byte("mask = 0xFFFF\nmask = 0x10000")))
+	d.SetBasePrefixes(true)
+	if err := d.Decode(u); err == nil {
+		t.Errorf("expected overflow error, got success.")
+	} else if u["mask"] != 0xFFFF {
+		t.Errorf("mask = %d", u["mask"])
+	}
+	for _, src := range []string{"n = 0_17", "n = -0_17"} {
+		d = NewDecoder(bytes.NewReader([]byte(src)))
+		d.SetBasePrefixes(true)
+		m = make(map[string]int)
+		if err := d.Decode(m); err == nil {
+			t.Errorf("%q: expected error, got %d", src, m["n"])
+		}
+	}
+	d = NewDecoder(bytes.NewReader([]byte("n = 1_000")))
+	d.SetBasePrefixes(true)
+	if err := d.Decode(m); err != nil || m["n"] != 1000 {
+		t.Errorf("n = %d, err = %v", m["n"], err)
+	}
+}
+
+func TestDecoder_Decode_Float(t *testing.T) {
+	raw := []byte("sci = 1e6\nneg = -2.5E-3\ninf = inf\nninf = -Inf\nnan = nan\nquoted = \"NaN\"")
+	m := make(map[string]float64)
+	if err := Unmarshal(raw, m); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	if m["sci"] != 1e6 || m["neg"] != -2.5e-3 {
+		t.Errorf("sci = %v, neg = %v", m["sci"], m["neg"])
+	}
+	if !math.IsInf(m["inf"], 1) || !math.IsInf(m["ninf"], -1) {
+		t.Errorf("inf = %v, ninf = %v", m["inf"], m["ninf"])
+	}
+	if !math.IsNaN(m["nan"]) || !math.IsNaN(m["quoted"]) {
+		t.Errorf("nan = %v, quoted = %v", m["nan"], m["quoted"])
+	}
+}
+
+func TestDecoder_Decode_ErrorLocation(t *testing.T) {
+	raw := []byte("version = 1\nmain\n    frontend\n        option\n            hwm = lots")
+	err := Unmarshal(raw, &ZdcfRoot{})
+	if e, ok := err.(*UnmarshalTypeError); !ok {
+		t.Fatalf("expected UnmarshalTypeError, got %T: %v", err, err)
+	} else if e.Path != "main/frontend/option/hwm" || e.Line != 5 {
+		t.Errorf("path = %q, line = %d", e.Path, e.Line)
+	} else if !strings.HasPrefix(e.Error(), "zpl: main/frontend/option/hwm (line 5): cannot unmarshal") {
+		t.Errorf("unexpected message: %s", e.Error())
+	}
+	err = Unmarshal([]byte("context\n    iothread = 1"), &ZdcfRoot{})
+	if e, ok := err.(*UnmarshalFieldError); !ok {
+		t.Fatalf("expected UnmarshalFieldError, got %T: %v", err, err)
+	} else if e.Path != "context/iothread" || e.Line != 2 {
+		t.Errorf("path = %q, line = %d", e.Path, e.Line)
+	}
+}
+
+func TestDecoder_SetBestEffort(t *testing.T) {
+	raw := []byte(`version = 1
+context
+    iothreads = many
+    verbose = 1
+    verbose
+        deep
+            key = 1
+        key = 2
+bad line here
+main
+    type = zmq_queue
+`)
+	var conf ZdcfRoot
+	d := NewDecoder(bytes.NewReader(raw))
+	d.SetBestEffort(true)
+	err := d.Decode(&conf)
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("expected ErrorList, got %T: %v", err, err)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %d: %v", len(errs), errs)
+	}
+	if _, ok := errs[0].(*UnmarshalTypeError); !ok {
+		t.Errorf("expected UnmarshalTypeError, got %T", errs[0])
+	}
+	if _, ok := errs[2].(*SyntaxError); !ok {
+		t.Errorf("expected SyntaxError, got %T", errs[2])
+	}
+	if conf.Version != 1 || !conf.Context.Verbose || conf.Devices["main"].Type != "zmq_queue" {
+		t.Errorf("unexpected result: %+v", conf)
+	}
+}
+
+func TestDecoder_SetProgress(t *testing.T) {
+	var raw []byte
+	for i := 0; i < 10000; i++ {
+		raw = append(raw, "key = value\n"...)
+	}
+	var (
+		calls     int
+		lastBytes int64
+		lastLine  uint64
+	)
+	d := NewDecoder(bytes.NewReader(raw))
+	d.SetProgress(func(bytesRead int64, line uint64) {
+		if bytesRead < lastBytes || line < lastLine {
+			t.Errorf("progress went backwards: %d, %d", bytesRead, line)
+		}
+		calls, lastBytes, lastLine = calls+1, bytesRead, line
+	})
+	if err := d.Decode(make(map[string][]string)); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if calls < 2 {
+		t.Errorf("expected several progress reports, got %d", calls)
+	}
+	if lastBytes != int64(len(raw)) {
+		t.Errorf("last report was at %d bytes, expected %d", lastBytes, len(raw))
+	}
+}
+
+func TestDecoder_Stats(t *testing.T) {
+	src := "# devices\nversion = 1\n\nmain\n    # sockets\n    frontend\n        bind = a\n    type = q\n"
+	d := NewDecoder(strings.NewReader(src))
+	if err := d.Decode(make(map[string]interface{})); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	expected := Stats{Lines: 8, Comments: 2, Sections: 2, Values: 3, MaxDepth: 2, Bytes: int64(len(src))}
+	if stats := d.Stats(); stats != expected {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestDecoder_SetTrace(t *testing.T) {
+	var trace []string
+	d := NewDecoder(strings.NewReader("a = 1\nmain\n    b = 2\nc = 3\n"))
+	d.SetTrace(func(ev Event, line uint64) {
+		trace = append(trace, fmt.Sprintf("%d %s %s %s", line, ev.Type, ev.Name, ev.Value))
+	})
+	if err := d.Decode(make(map[string]interface{})); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	expected := []string{
+		"1 AddValue a 1",
+		"2 StartSection main ",
+		"3 AddValue b 2",
+		"4 EndSection  ",
+		"4 AddValue c 3",
+	}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("trace = %q", trace)
+	}
+}
+
+func BenchmarkDecoder_Decode_Map(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := make(map[string]interface{})
+		if err := NewDecoder(bytes.NewReader(raw0)).Decode(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoder_Decode_Section(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var s Section
+		if err := NewDecoder(bytes.NewReader(raw0)).Decode(&s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecoder_Decode_Comments(t *testing.T) {
+	docs := []string{
+		"key = 1\n# comment\n",
+		"key = 1\n# comment",
+		"key = 1\n    \n\t\nkey = 2\n",
+		"section\n    key = 1\nkey = 2",
+	}
+	for _, doc := range docs {
+		var s Section
+		if err := Unmarshal([]byte(doc), &s); err != nil {
+			t.Errorf("failed to unmarshal %q: %s", doc, err)
+		}
+		if err := NewDecoder(iotest.OneByteReader(strings.NewReader(doc))).Decode(&s); err != nil {
+			t.Errorf("failed to decode %q: %s", doc, err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal_Map(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := make(map[string]interface{})
+		if err := Unmarshal(raw0, m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestUnmarshal_NilMap(t *testing.T) {
+	var m map[string]interface{}
+	if err := Unmarshal(raw0, &m); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	} else if _, ok := m["main"].(map[string]interface{}); !ok {
+		t.Errorf("main = %#v", m["main"])
+	}
+	var pm *map[string][]string
+	if err := Unmarshal([]byte("key = 1"), &pm); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	} else if (*pm)["key"][0] != "1" {
+		t.Errorf("key = %v", (*pm)["key"])
+	}
+	var conf *ZdcfRoot
+	if err := Unmarshal(raw0, &conf); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	} else if conf.Context.IoThreads != 1 {
+		t.Errorf("context/iothreads = %v", conf.Context.IoThreads)
+	}
+	var i interface{}
+	if err := Unmarshal([]byte("key = 1"), &i); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	} else if _, ok := i.(map[string]interface{})["key"]; !ok {
+		t.Errorf("i = %#v", i)
+	}
+	var bad map[int]string
+	if err := Unmarshal(raw0, &bad); err == nil {
+		t.Errorf("expected error for map[int]string, got success.")
+	}
+}
+
+func TestDecoder_SetLowerCaseKeys(t *testing.T) {
+	var v struct {
+		Bind    string            `zpl:"bind"`
+		Options map[string]string `zpl:"options"`
+	}
+	d := NewDecoder(strings.NewReader("Bind = tcp://*:5555\nOPTIONS\n    HWM = 1000\n"))
+	d.SetLowerCaseKeys(true)
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Bind != "tcp://*:5555" || v.Options["hwm"] != "1000" {
+		t.Errorf("unexpected result: %+v", v)
+	}
+	if err := Unmarshal([]byte("Bind = x\n"), &v); err == nil {
+		t.Errorf("expected keys to be case-sensitive by default")
+	}
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetLowerCaseKeys(true)
+	if err := e.Encode(map[string]map[string]int{"Main": {"HWM": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "main\n    hwm = 1\n"; buf.String() != expected {
+		t.Errorf("unexpected encoding:\n%s", buf.String())
+	}
+}
+
+func TestDecoder_SetMaxElements(t *testing.T) {
+	src := "a = 1\nb\n    c = 2\n    d = 3\n"
+	for _, tt := range []struct {
+		max  int
+		line uint64
+	}{
+		{0, 0},
+		{4, 0},
+		{3, 4},
+		{1, 2},
+	} {
+		d := NewDecoder(strings.NewReader(src))
+		d.SetMaxElements(tt.max)
+		d.SetBestEffort(true)
+		err := d.Decode(new(Section))
+		var limit *LimitError
+		if tt.line == 0 {
+			if err != nil {
+				t.Errorf("max %d: %s", tt.max, err)
+			}
+		} else if !errors.As(err, &limit) || limit.Line != tt.line || limit.Limit != tt.max {
+			t.Errorf("max %d: expected a LimitError on line %d, got %v", tt.max, tt.line, err)
+		}
+	}
+	d := NewDecoder(strings.NewReader("a = x\nb = 2\nc = 3\n"))
+	d.SetMaxElements(2)
+	d.SetBestEffort(true)
+	m := make(map[string]int)
+	err := d.Decode(m)
+	var list ErrorList
+	if !errors.As(err, &list) || len(list) != 2 {
+		t.Fatalf("expected both errors, got %v", err)
+	}
+	var limit *LimitError
+	if _, ok := list[0].(*UnmarshalTypeError); !ok || !errors.As(list[1], &limit) || m["b"] != 2 {
+		t.Errorf("unexpected errors %v, result %v", list, m)
+	}
+}
+
+type snippetMock struct {
+	Bind string   `zpl:"bind"`
+	Hwm  int      `zpl:"hwm"`
+	Tags []string `zpl:"tag"`
+	Log  *struct {
+		Level string `zpl:"level"`
+	} `zpl:"log"`
+}
+
+var snippet = []byte("bind = tcp://*:5555\nhwm = 1000\ntag = a\ntag = b\nlog\n    level = info\n")
+
+func BenchmarkUnmarshal_Snippet(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var v snippetMock
+		if err := Unmarshal(snippet, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal_Snippet_Parallel(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			var v snippetMock
+			if err := Unmarshal(snippet, &v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
 }
@@ -0,0 +1,69 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+// ApplyDefaults copies the contents of every subsection of doc named name,
+// at any depth, into each of its sibling sections, and then removes it.  A
+// sibling keeps its own value for any property it sets, and subsections of
+// the defaults are merged into the sibling's subsections of the same name in
+// the same way.  This lets sections that differ in only a few settings, such
+// as one per worker, share the rest:
+//
+//     workers
+//         defaults
+//             threads = 4
+//             log = info
+//         fast
+//             threads = 16
+//         slow
+//
+// Here "fast" has threads = 16 and log = info, and "slow" has threads = 4
+// and log = info.  Properties beside the defaults section are not affected.
+//
+func ApplyDefaults(doc *Section, name string) {
+	if defaults := doc.sections[name]; defaults != nil {
+		doc.remove(name)
+		for _, key := range doc.keys {
+			if sib := doc.sections[key]; sib != nil {
+				inherit(sib, defaults)
+			}
+		}
+	}
+	for _, key := range doc.keys {
+		if sub := doc.sections[key]; sub != nil {
+			ApplyDefaults(sub, name)
+		}
+	}
+}
+
+// Copy into s whatever it does not set itself from defaults.
+func inherit(s, defaults *Section) {
+	for _, key := range defaults.keys {
+		pos := defaults.pos[key]
+		if values, ok := defaults.values[key]; ok {
+			if _, set := s.values[key]; set || s.sections[key] != nil {
+				continue
+			}
+			for _, value := range values {
+				s.add(key, value, pos)
+			}
+		} else if _, set := s.values[key]; !set {
+			inherit(s.addSection(key, pos), defaults.sections[key])
+		}
+	}
+}
+
+// Remove the named property or subsection from s.
+func (s *Section) remove(name string) {
+	for i, key := range s.keys {
+		if key == name {
+			s.keys = append(s.keys[:i:i], s.keys[i+1:]...)
+			break
+		}
+	}
+	delete(s.values, name)
+	delete(s.sections, name)
+	delete(s.pos, name)
+}
@@ -0,0 +1,81 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"testing"
+)
+
+func TestApplyDefaults(t *testing.T) {
+	doc, err := Parse([]byte(`name = pool
+workers
+    defaults
+        threads = 4
+        log = info
+        limits
+            memory = 1G
+            files = 64
+    fast
+        threads = 16
+        limits
+            files = 1024
+    slow
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ApplyDefaults(doc, "defaults")
+	expected := `name = pool
+workers
+    fast
+        threads = 16
+        limits
+            files = 1024
+            memory = 1G
+        log = info
+    slow
+        threads = 4
+        log = info
+        limits
+            memory = 1G
+            files = 64
+`
+	if got := doc.String(); got != expected {
+		t.Errorf("unexpected result:\n%s", got)
+	}
+	doc.Section("workers").Section("slow").Add("threads", "2")
+	if fast := doc.Section("workers").Section("fast"); fast.Value("threads") != "16" {
+		t.Errorf("siblings share inherited values")
+	}
+}
+
+func TestApplyDefaults_Nested(t *testing.T) {
+	doc, err := Parse([]byte(`defaults
+    port = 80
+    routes
+        defaults
+            timeout = 5
+        home
+web
+    routes
+        api
+            timeout = 30
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ApplyDefaults(doc, "defaults")
+	web := doc.Section("web")
+	if web.Value("port") != "80" {
+		t.Errorf("expected port to be inherited, got %q", web.Value("port"))
+	}
+	routes := web.Section("routes")
+	if routes.Section("defaults") != nil {
+		t.Errorf("nested defaults section was not removed")
+	}
+	if routes.Section("api").Value("timeout") != "30" || routes.Section("home").Value("timeout") != "5" {
+		t.Errorf("unexpected routes:\n%s", routes)
+	}
+}
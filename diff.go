@@ -0,0 +1,174 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"sort"
+	"strings"
+)
+
+// A Change describes a property whose values differ between two documents.
+// Old is empty if the property was added and New is empty if it was removed.
+//
+type Change struct {
+	Path string   // "/"-separated path of the property
+	Old  []string // values in the old document
+	New  []string // values in the new document
+}
+
+// Diff returns the properties whose values differ between the documents a
+// and b, sorted by path.  The values of a property are compared in order,
+// since the order of a repeated property's values is significant.  Comments,
+// layout and the order of different keys are not.
+//
+func Diff(a, b *Section) []Change {
+	before, after := propertyValues(a), propertyValues(b)
+	var changes []Change
+	for path, values := range before {
+		if !equalValues(values, after[path]) {
+			changes = append(changes, Change{Path: path, Old: values, New: after[path]})
+		}
+	}
+	for path, values := range after {
+		if _, ok := before[path]; !ok {
+			changes = append(changes, Change{Path: path, New: values})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// DiffText returns a report of the differences between the ZPL documents a
+// and b in the style of a unified diff, but keyed by path rather than by line
+// so that it is unaffected by layout.  Each value of a changed property is
+// listed with "-" if it is only in a, "+" if it is only in b, or " " if the
+// property has it in both, and sections that exist in only one document are
+// listed with a trailing "/":
+//
+//   -main/bind = tcp://eth0:5555
+//   +main/bind = tcp://eth0:5556
+//    main/bind = inproc://device
+//   +main/hwm = 1000
+//   -old/
+//
+// The report is empty if the documents are equivalent, and is the error
+// message if either cannot be parsed.
+//
+func DiffText(a, b []byte) string {
+	sa, err := Parse(a)
+	if err != nil {
+		return err.Error() + "\n"
+	}
+	sb, err := Parse(b)
+	if err != nil {
+		return err.Error() + "\n"
+	}
+	type entry struct {
+		path  string
+		lines []string
+	}
+	var entries []entry
+	for _, c := range Diff(sa, sb) {
+		var lines []string
+		for _, line := range diffValues(c.Old, c.New) {
+			lines = append(lines, line[:1]+c.Path+" = "+line[1:])
+		}
+		entries = append(entries, entry{c.Path, lines})
+	}
+	before, after := sectionPaths(sa), sectionPaths(sb)
+	for path := range before {
+		if !after[path] {
+			entries = append(entries, entry{path, []string{"-" + path + "/"}})
+		}
+	}
+	for path := range after {
+		if !before[path] {
+			entries = append(entries, entry{path, []string{"+" + path + "/"}})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+	var buf strings.Builder
+	for _, e := range entries {
+		for _, line := range e.lines {
+			buf.WriteString(line + "\n")
+		}
+	}
+	return buf.String()
+}
+
+// Return the lines of a minimal edit from old to new, each prefixed by "-",
+// "+" or " ", using the longest common subsequence of the two.
+func diffValues(old, new []string) []string {
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var lines []string
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case i < len(old) && j < len(new) && old[i] == new[j]:
+			lines = append(lines, " "+old[i])
+			i, j = i+1, j+1
+		case j == len(new) || i < len(old) && lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "-"+old[i])
+			i++
+		default:
+			lines = append(lines, "+"+new[j])
+			j++
+		}
+	}
+	return lines
+}
+
+// Return the "/"-separated paths of every section within s.
+func sectionPaths(s *Section) map[string]bool {
+	paths := make(map[string]bool)
+	var visit func(s *Section, prefix string)
+	visit = func(s *Section, prefix string) {
+		for name, sub := range s.sections {
+			paths[prefix+name] = true
+			visit(sub, prefix+name+"/")
+		}
+	}
+	visit(s, "")
+	return paths
+}
+
+// Return the values of every property in s by path.
+func propertyValues(s *Section) map[string][]string {
+	m := make(map[string][]string)
+	if s != nil {
+		Walk(s, func(path []string, key, value string) error {
+			k := strings.Join(append(path[:len(path):len(path)], key), "/")
+			m[k] = append(m[k], value)
+			return nil
+		})
+	}
+	return m
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a, err := Parse([]byte("# old\nversion = 1\nmain\n    bind = a\n    bind = b\n    type = queue\nold = 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Parse([]byte("main\n    type = queue\n    bind = b\n    bind = a\nversion = 1\nnew = 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []Change{
+		{Path: "main/bind", Old: []string{"a", "b"}, New: []string{"b", "a"}},
+		{Path: "new", New: []string{"2"}},
+		{Path: "old", Old: []string{"1"}},
+	}
+	if changes := Diff(a, b); !reflect.DeepEqual(changes, expect) {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+	if changes := Diff(a, a); len(changes) != 0 {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+}
+
+func TestDiffText(t *testing.T) {
+	a := []byte("main\n    bind = tcp://eth0:5555\n    bind = inproc://device\nold\n    x = 1\nempty\n")
+	b := []byte("# reviewed\nmain\n    bind = tcp://eth0:5556\n    bind = inproc://device\n    hwm = 1000\n")
+	expected := `-empty/
+-main/bind = tcp://eth0:5555
++main/bind = tcp://eth0:5556
+ main/bind = inproc://device
++main/hwm = 1000
+-old/
+-old/x = 1
+`
+	if text := DiffText(a, b); text != expected {
+		t.Errorf("unexpected report:\n%s", text)
+	}
+	if text := DiffText(a, a); text != "" {
+		t.Errorf("expected no differences, got:\n%s", text)
+	}
+}
@@ -5,26 +5,75 @@
 package zpl
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// An UnsupportedValueError is returned by Marshal when attempting to encode an
+// unsupported value, such as an infinite floating point number.
+//
+type UnsupportedValueError struct {
+	Value reflect.Value
+	Str   string
+}
+
+func (e *UnsupportedValueError) Error() string {
+	return "zpl: unsupported value: " + e.Str
+}
+
+// An UnsupportedTypeError is returned by an Encoder in strict mode when
+// attempting to encode a value of a type that ZPL cannot represent.
+//
+type UnsupportedTypeError struct {
+	Type reflect.Type
+	Path string // slash-separated path of the property, e.g. "main/handler"
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	if e.Path == "" {
+		return "zpl: unsupported type: " + e.Type.String()
+	}
+	return "zpl: unsupported type for " + e.Path + ": " + e.Type.String()
+}
+
 // Marshal returns the ZPL encoding of v.
 //
-// Marshal traverses the value v recursively, using the following type-dependent
-// default encodings:
+// If v implements Marshaler, Marshal returns the result of its MarshalZPL
+// method.  Otherwise, Marshal traverses the value v recursively, using the
+// following type-dependent default encodings:
+//
+// Boolean values encode as ints (0 for false or 1 for true) unless other
+// literals are chosen with Encoder.SetBoolLiterals.
+//
+// Floating point and integer values encode as base-10 numbers.  The format and
+// precision of floating point values may be chosen for the whole document with
+// Encoder.SetFloatFormat or for a single field with "format" and "prec" tag
+// options, as in strconv.FormatFloat:
 //
-// Boolean values encode as ints (0 for false or 1 for true).
+//   // Field appears in ZPL as e.g. "ratio = 1.250e-01".
+//   Field float64 `zpl:"ratio,format=e,prec=3"`
 //
-// Floating point and integer values encode as base-10 numbers.
+// Infinite and NaN floating point values cause Marshal to return an
+// UnsupportedValueError unless Encoder.SetNonFiniteFloats is in effect.
 //
 // String values encode as strings.  Invalid character sequences will cause
-// Marshal to return an UnsupportedValueError.  Line breaks are invalid.
+// Marshal to return an UnsupportedValueError.  Line breaks are invalid unless
+// Encoder.SetLineBreaks chooses a way to represent them.  Strings that
+// Unmarshal would otherwise change, such as "" or one with leading or
+// trailing spaces, are written in double quotes.
 //
-// Array and slice values encode as repetitions of the same property.
+// Array and slice values encode as repetitions of the same property.  Slices
+// of structs or maps encode as repetitions of the same section, and Unmarshal
+// decodes each repetition into a new element of such a slice rather than
+// merging them.
 //
 // Struct values encode as ZPL sections.  Each exported struct field becomes a
 // property in the section unless the field's tag is "-".  The "zpl" key in the
@@ -39,22 +88,114 @@ import (
 // The key name will be used if it's a non-empty string consisting of only
 // alphanumeric ([A-Za-z0-9]) characters.
 //
+// Several key names separated by "|" are all accepted by Unmarshal, which is
+// useful when configurations written by different tools spell a key
+// differently.  Marshal always uses the first:
+//
+//   // Field appears in ZPL as "addr", and may be read from "address" or "host".
+//   Field string `zpl:"addr|address|host"`
+//
+// The key name may be followed by comma-separated options.  Integer fields
+// tagged with "format=size" accept values like "512K", "25M" or "1G" when
+// unmarshalling, where each suffix multiplies by a successive power of 1024:
+//
+//   // Field appears in ZPL as property "swap", e.g. "swap = 25M".
+//   Field int64 `zpl:"swap,format=size"`
+//
+// Byte slice fields tagged with "format=base64" or "format=hex" are written
+// as a single value in that encoding.  Unmarshal decodes such values,
+// concatenating repeated ones so that long data can span several lines:
+//
+//   // Field appears in ZPL as e.g. "cert = MIIBszCCAV2gAwIBAgIJ...".
+//   Field []byte `zpl:"cert,format=base64"`
+//
+// The "min" and "max" options limit the numeric values that Unmarshal accepts
+// for a field, and the "oneof" option lists the only values it accepts,
+// separated by spaces.  Unmarshal reports every violation together, as
+// ConstraintErrors in an ErrorList:
+//
+//   // Field appears in ZPL as "port", with a value from 1 to 65535.
+//   Field int `zpl:"port,min=1,max=65535"`
+//
+//   // Field appears in ZPL as "type", with one of three values.
+//   Field string `zpl:"type,oneof=zmq_queue zmq_forwarder zmq_streamer"`
+//
+// The "alias" option names a deprecated key that Unmarshal still accepts for
+// the field, recording a Warning (see Decoder.Warnings) each time it is used:
+//
+//   // Field appears in ZPL as "address", or formerly as "addr".
+//   Field string `zpl:"address,alias=addr"`
+//
+// The "indexed" option on a slice of structs or maps encodes its elements as
+// subsections named "0", "1", "2" and so on within a single section, for
+// lists whose order matters.  Unmarshal stores each such subsection at its
+// index in the slice, growing the slice as needed:
+//
+//   // Field appears in ZPL as section "steps" with subsections "0", "1", ...
+//   Field []Step `zpl:"steps,indexed"`
+//
+// The "mask" option causes Marshal to write "******" in place of the field's
+// value, or whatever Encoder.SetMask chooses, so that a configuration holding
+// secrets can be written to logs or debug endpoints:
+//
+//   // Field appears in ZPL as "password = ******".
+//   Field string `zpl:"password,mask"`
+//
+// Struct fields are encoded in the order they are declared unless they have
+// a "zplorder" tag, in which case they are sorted by its integer weight.
+// Fields without one have weight 0:
+//
+//   // Field appears after fields with lower or no weights.
+//   Field int `zpl:"name" zplorder:"10"`
+//
+// Struct values encode as ZPL sections unless their tag has the "flatten"
+// option, as in `zpl:",flatten"`, in which case their fields are encoded in
+// the parent section at the position of the struct field.  The field may also
+// be a pointer or interface holding a struct.  Unmarshal looks for keys that
+// the parent struct does not have in such fields.
+//
 // Map values encode as ZPL sections unless their tag is "*", in which case they
 // will be collapsed into their parent.  There can be only one "*"-tagged map in
-// any marshalled struct.  The map's key type must be string; the map keys are
-// used directly as property and sub-section names.
+// any marshalled struct.  The map's key type must be a string type, implement
+// encoding.TextMarshaler, or be an interface type whose keys hold one of
+// those; string keys are used directly as property and sub-section names,
+// other keys are marshalled as text, and entries are
+// encoded in lexical order unless another order is chosen with
+// Encoder.SetKeyOrder.
+//
+// Section values encode as the properties and subsections they contain, in
+// their original order.
+//
+// The big.Int, big.Float and big.Rat types of package math/big encode as
+// numbers with as many digits as they need, and Unmarshal accepts values
+// beyond the range of int64 and float64 for them.
+//
+// The nullable types of package database/sql, such as sql.NullString, encode
+// as their value if they are valid and otherwise as a nil pointer.  Unmarshal
+// sets them valid when it decodes a value into them, so an absent key leaves
+// them invalid.
 //
-// Pointer values encode as the value pointed to.
+// Pointer values encode as the value pointed to.  Nil pointers are skipped
+// unless another policy is chosen with Encoder.SetNilPolicy.
 //
-// Interface values encode as the value contained in the interface.
+// Interface values encode as the value contained in the interface wherever
+// they appear: as the value passed to Marshal, as struct fields, as map
+// values and keys, and as slice elements, so that the maps, slices and
+// strings that Unmarshal stores in an interface{} can be marshalled again.
+// Nil interfaces are skipped.
 //
-// Channel, complex, and function values cannot be encoded in ZPL.  Attempting
-// to encode such a value causes Marshal to return an UnsupportedTypeError.
+// Channel, complex, and function values cannot be encoded in ZPL, nor can maps
+// with other key types.  Such values are silently skipped unless
+// Encoder.SetStrict is in effect, in which case attempting to encode one
+// returns an UnsupportedTypeError.
 //
 // ZPL cannot represent cyclic data structures and Marshal does not handle them.
 // Passing cyclic structures to Marshal will result in an infinite recursion.
 //
 func Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(Marshaler); ok {
+		return m.MarshalZPL()
+	}
 	var (
 		buf = &bytes.Buffer{}
 		e   = NewEncoder(buf)
@@ -63,80 +204,705 @@ func Marshal(v interface{}) ([]byte, error) {
 	return buf.Bytes(), err
 }
 
-// An Encoder write ZPL to an output stream.
+// MarshalAt returns the ZPL encoding of v nested within the sections named by
+// path, a "/"-separated list of section names such as "devices/main".  Since
+// ZPL merges repeated sections, fragments produced by MarshalAt for different
+// components can be concatenated into a single document.
+//
+func MarshalAt(path string, v interface{}) ([]byte, error) {
+	var (
+		buf = &bytes.Buffer{}
+		e   = NewEncoder(buf)
+		err = e.EncodeAt(path, v)
+	)
+	return buf.Bytes(), err
+}
+
+// Marshaler is implemented by types that can encode themselves as a ZPL
+// document, such as those for which zplgen generates methods.  Marshal calls
+// MarshalZPL instead of using reflection when v implements it.  Encoders do
+// not, since the method cannot apply their settings.
+//
+type Marshaler interface {
+	MarshalZPL() ([]byte, error)
+}
+
+// An Encoder write ZPL to an output stream.  Output is buffered so that the
+// many small lines of a document reach the underlying io.Writer in a few
+// large writes; Encode, EncodeAt and EncodeSource flush the buffer before
+// they return.
 //
 type Encoder struct {
-	w      io.Writer
+	w      *bufio.Writer
+	err    error // the first error returned by w
 	indent string
 	br     string
+	sep    string
+
+	trueText  string
+	falseText string
+	jsonTags  bool
+
+	align bool
+	lines []encodedLine // held until the end of Encode when aligning
+	tree  []*Section    // sections being built instead of written, innermost last
+
+	keyLess      func(a, b string) bool
+	sectionsLast bool
+
+	floatFmt     byte
+	floatPrec    int
+	nonFinite    bool
+	strict       bool
+	omitZero     bool
+	nilPolicy    NilPolicy
+	quoteStrings bool
+	lineBreaks   LineBreakMode
+	stringers    bool
+	mask         string
+	lowerKeys    bool
+	header       []string // comment lines not yet written
+	path         []string // names of the sections being written
+}
+
+// An encodedLine is a property or section header waiting to be written.
+type encodedLine struct {
+	indent  string
+	name    string
+	value   string
+	section bool
+	empty   bool // a property without a value, as in "key ="
 }
 
 // NewEncoder returns a new encoder that writes to w.
 //
 func NewEncoder(w io.Writer) *Encoder {
 	return &Encoder{
-		w:  w,
-		br: "\n",
+		w:         bufio.NewWriter(w),
+		br:        "\n",
+		sep:       " = ",
+		floatFmt:  'f',
+		floatPrec: -1,
+		trueText:  "1",
+		falseText: "0",
+		mask:      "******",
 	}
 }
 
+// SetBoolLiterals sets the text written for true and false boolean values,
+// which by default are "1" and "0".
+//
+func (e *Encoder) SetBoolLiterals(trueText, falseText string) {
+	e.trueText = trueText
+	e.falseText = falseText
+}
+
+// A BoolStyle selects the text that an Encoder writes for boolean values.
+//
+type BoolStyle int
+
+const (
+	// BoolDigits writes true and false as "1" and "0".  This is the
+	// default.
+	BoolDigits BoolStyle = iota
+
+	// BoolWords writes true and false as "true" and "false".
+	BoolWords
+)
+
+// SetBoolStyle selects the text written for boolean values, for consumers
+// that expect words rather than digits.  Unmarshal accepts either style.
+//
+func (e *Encoder) SetBoolStyle(style BoolStyle) {
+	switch style {
+	case BoolWords:
+		e.SetBoolLiterals("true", "false")
+	default:
+		e.SetBoolLiterals("1", "0")
+	}
+}
+
+// SetJSONTagFallback causes the Encoder to use the name in a struct field's
+// "json" tag as its key when the field has no "zpl" tag, or the field's own
+// name if the "json" tag has only options, as in ",omitempty".
+//
+func (e *Encoder) SetJSONTagFallback(enabled bool) {
+	e.jsonTags = enabled
+}
+
+// SetSeparator sets the text written between each key and its value, which by
+// default is " = ".  For example, "=" produces compact "key=value" lines.  The
+// separator should consist of an equals sign surrounded by optional spaces,
+// since that is all that ZPL allows.
+//
+func (e *Encoder) SetSeparator(sep string) {
+	e.sep = sep
+}
+
+// SetFloatFormat sets the format and precision used for floating point values,
+// with the same meanings as in strconv.FormatFloat.  The default, 'f' with
+// precision -1, writes the fewest digits necessary to represent each value
+// exactly without an exponent.  Individual struct fields may override these
+// with "format" and "prec" tag options.
+//
+func (e *Encoder) SetFloatFormat(fmt byte, prec int) {
+	e.floatFmt = fmt
+	e.floatPrec = prec
+}
+
+// SetNonFiniteFloats causes the Encoder to write infinite and NaN floating
+// point values as the quoted strings "+Inf", "-Inf" and "NaN", which Unmarshal
+// accepts for float values, instead of failing with an UnsupportedValueError.
+//
+func (e *Encoder) SetNonFiniteFloats(enabled bool) {
+	e.nonFinite = enabled
+}
+
+// SetStrict causes the Encoder to fail with an UnsupportedTypeError naming the
+// path of any value that cannot be represented in ZPL, instead of silently
+// skipping it.
+//
+func (e *Encoder) SetStrict(enabled bool) {
+	e.strict = enabled
+}
+
+// OmitZero causes the Encoder to skip every zero-valued property: false,
+// zero numbers, empty strings, nil pointers and interfaces, and empty maps
+// and slices.  Sections left empty once their zero properties are skipped
+// are omitted as well.
+//
+func (e *Encoder) OmitZero(enabled bool) {
+	e.omitZero = enabled
+}
+
+// SetQuoteStrings causes the Encoder to wrap every string value in double
+// quotes, which Unmarshal removes, so that values with leading or trailing
+// spaces survive and hand edits cannot accidentally change them.  RawValue
+// and Number values are still written as they are.
+//
+func (e *Encoder) SetQuoteStrings(enabled bool) {
+	e.quoteStrings = enabled
+}
+
+// SetLowerCaseKeys causes the Encoder to write every property and section
+// name in lower case, for consumers that expect canonical lower-case keys.
+//
+func (e *Encoder) SetLowerCaseKeys(enabled bool) {
+	e.lowerKeys = enabled
+}
+
+// SetStringerFallback causes the Encoder to write values that implement
+// fmt.Stringer as the result of their String method when they would otherwise
+// be skipped or written as sections: structs other than Section, and channel,
+// function, complex and other unsupported values.  Numbers, strings and other
+// values with a ZPL encoding of their own are unaffected.
+//
+func (e *Encoder) SetStringerFallback(enabled bool) {
+	e.stringers = enabled
+}
+
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+// Return the fmt.Stringer to use for value in place of its usual encoding, if
+// any.
+func (e *Encoder) stringer(value reflect.Value) (fmt.Stringer, bool) {
+	if !e.stringers {
+		return nil, false
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.Type() == sectionType {
+			return nil, false
+		}
+	case reflect.Map:
+		if isEncodableMap(value.Type()) {
+			return nil, false
+		}
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+	default:
+		return nil, false
+	}
+	if !value.Type().Implements(stringerType) && value.CanAddr() {
+		value = value.Addr()
+	}
+	if !value.Type().Implements(stringerType) || !value.CanInterface() {
+		return nil, false
+	}
+	return value.Interface().(fmt.Stringer), true
+}
+
+// SetMask sets the text written in place of the values of struct fields
+// tagged with the "mask" option, which by default is "******".
+//
+func (e *Encoder) SetMask(text string) {
+	e.mask = text
+}
+
+// Report whether value is written as a single value that a "mask" tag option
+// should hide.  Pointers, interfaces and slices are masked element by element,
+// and sections are not masked.
+func isMaskable(value reflect.Value, tag tagInfo) bool {
+	switch value.Kind() {
+	case reflect.Slice:
+		return isBinaryFormat(value.Type(), tag.Options["format"])
+	case reflect.Struct:
+		return isBigType(value.Type())
+	case reflect.Ptr, reflect.Interface, reflect.Array, reflect.Map,
+		reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return false
+	}
+	return true
+}
+
+// A NilPolicy selects what an Encoder writes for a nil pointer.
+//
+type NilPolicy int
+
+const (
+	// NilSkip writes nothing for a nil pointer.  This is the default.
+	NilSkip NilPolicy = iota
+
+	// NilEmpty writes a property with an empty value, as in "key =", or
+	// an empty section for a pointer to a struct or map.  Unmarshal
+	// rejects empty values, so this is meant for templates to be filled
+	// in by hand.
+	NilEmpty
+
+	// NilComment writes the same lines as NilEmpty but commented out, as
+	// in "# key =", so that a generated file shows every available option
+	// while remaining valid.
+	NilComment
+)
+
+// SetNilPolicy selects what is written for nil pointers.  Properties omitted
+// by OmitZero are not written regardless of this policy.
+//
+func (e *Encoder) SetNilPolicy(policy NilPolicy) {
+	e.nilPolicy = policy
+}
+
+// Write a nil pointer to a value of type typ at name according to the
+// encoder's NilPolicy.
+func (e *Encoder) encodeNil(name string, typ reflect.Type) error {
+	switch e.nilPolicy {
+	case NilEmpty:
+	case NilComment:
+		name = "# " + name
+	default:
+		return nil
+	}
+	if typ.Kind() == reflect.Struct || typ.Kind() == reflect.Map {
+		err := e.startSection(name)
+		if err2 := e.endSection(); err == nil {
+			err = err2
+		}
+		return err
+	}
+	return e.addEmpty(name)
+}
+
+// Report whether value would encode as nothing, or as an empty section, once
+// zero properties are omitted.
+func (e *Encoder) isZero(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil() || e.isZero(value.Elem())
+	case reflect.Map:
+		props, err := e.properties(value)
+		for _, p := range props {
+			if !e.isZero(p.value) {
+				return false
+			}
+		}
+		return err == nil
+	case reflect.Struct:
+		if isNullType(value.Type()) {
+			return !value.Field(1).Bool() || e.isZero(value.Field(0))
+		}
+		if isBigType(value.Type()) {
+			return formatBig(value) == "0"
+		}
+		if value.Type() == sectionType {
+			s := value.Interface().(Section)
+			return len(s.keys) == 0
+		}
+		props, _ := e.properties(value)
+		for _, p := range props {
+			if !e.isZero(p.value) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+// Return an error for a value of type typ at name in the current section if
+// the encoder is strict, or nil so that the value is skipped.
+func (e *Encoder) unsupported(name string, typ reflect.Type) error {
+	if !e.strict {
+		return nil
+	}
+	return &UnsupportedTypeError{
+		Type: typ,
+		Path: strings.Join(append(e.path[:len(e.path):len(e.path)], name), "/"),
+	}
+}
+
+// SetKeyOrder sets the function used to order the keys of maps, which by
+// default are encoded in lexical order.
+//
+func (e *Encoder) SetKeyOrder(less func(a, b string) bool) {
+	e.keyLess = less
+}
+
+// SetSectionsLast causes the Encoder to write all the plain properties of
+// each section before any of its subsections.
+//
+func (e *Encoder) SetSectionsLast(enabled bool) {
+	e.sectionsLast = enabled
+}
+
+// SetHeader sets comment lines to write before anything else the Encoder
+// writes, such as a note that a file was generated and should not be edited
+// by hand.  Each line is prefixed with "# " unless it already begins with
+// "#".  The header is written once, by the next call to Encode, EncodeAt or
+// EncodeSource.
+//
+func (e *Encoder) SetHeader(lines ...string) {
+	e.header = lines
+}
+
+// Write the header set by SetHeader, if it has not been written yet.
+func (e *Encoder) writeHeader() error {
+	var buf bytes.Buffer
+	for _, line := range e.header {
+		if !strings.HasPrefix(line, "#") {
+			line = strings.TrimRight("# "+line, " ")
+		}
+		buf.WriteString(line + e.br)
+	}
+	e.header = nil
+	if buf.Len() == 0 {
+		return e.err
+	}
+	return e.write(buf.Bytes())
+}
+
+// SetAlign causes the Encoder to align the "=" signs of the properties within
+// each section by padding their keys to the length of the longest key.  The
+// Encoder then holds each document in memory until Encode returns.
+//
+func (e *Encoder) SetAlign(enabled bool) {
+	e.align = enabled
+}
+
 // Encode writes the ZPL encoding of v to the connection.
 //
 // See the documentation for Marshal for details about the conversion of Go
 // values to ZPL.
 //
+// Once a write to the underlying io.Writer has failed, the Encoder writes
+// nothing more and every call to Encode returns the same error.
+//
 func (w *Encoder) Encode(v interface{}) error {
-	return w.encode(reflect.ValueOf(v))
+	return w.EncodeAt("", v)
+}
+
+// EncodeAt writes the ZPL encoding of v to the connection, nested within the
+// sections named by path, a "/"-separated list of section names such as
+// "devices/main".  An empty path encodes v at the top level, as Encode does.
+//
+func (w *Encoder) EncodeAt(path string, v interface{}) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	var names []string
+	if path != "" {
+		names = strings.Split(path, "/")
+	}
+	var err error
+	for _, name := range names {
+		if err2 := w.startSection(name); err == nil {
+			err = err2
+		}
+	}
+	if err == nil {
+		err = w.encode(reflect.ValueOf(v))
+	}
+	for range names {
+		w.endSection()
+	}
+	if w.align {
+		if err2 := w.writeAligned(); err == nil {
+			err = err2
+		}
+	}
+	if err2 := w.Flush(); err2 != nil {
+		return err2
+	}
+	return err
+}
+
+// EncodeSource writes the events produced by src until it returns io.EOF, so
+// that a document can be streamed from a Decoder or any other Source.
+// Sections left open by src are closed when it ends.
+//
+func (w *Encoder) EncodeSource(src Source) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	depth := len(w.path)
+	var err error
+	for err == nil {
+		var e *Event
+		if e, err = src.Next(); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			break
+		}
+		switch e.Type {
+		case AddValue:
+			err = w.addString(e.Name, e.Value, reflect.ValueOf(e.Value))
+		case StartSection:
+			err = w.startSection(e.Name)
+		case EndSection:
+			if len(w.path) == depth {
+				err = errors.New("zpl: end of section without a start")
+			} else {
+				err = w.endSection()
+			}
+		}
+	}
+	for len(w.path) > depth {
+		w.endSection()
+	}
+	if w.align {
+		if err2 := w.writeAligned(); err == nil {
+			err = err2
+		}
+	}
+	if err2 := w.Flush(); err2 != nil {
+		return err2
+	}
+	return err
 }
 
 func (w *Encoder) encode(value reflect.Value) error {
 	var fault error
+	if !value.IsValid() {
+		return nil
+	} else if value.Type() == sectionType {
+		s := value.Interface().(Section)
+		return w.encodeSection(&s)
+	}
 	switch value.Type().Kind() {
-	case reflect.Ptr:
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
 		return w.encode(value.Elem())
 	case reflect.Map:
-		if value.Type().Key().Kind() == reflect.String {
-			for _, key := range value.MapKeys() {
-				v := value.MapIndex(key)
-				if err := marshalProperty(w, key.String(), v); err != nil {
-					if fault == nil {
-						fault = err
-					}
+		if !isEncodableMap(value.Type()) {
+			return w.unsupported("", value.Type())
+		}
+		fallthrough
+	case reflect.Struct:
+		props, err := w.properties(value)
+		if err != nil {
+			return err
+		}
+		for _, p := range props {
+			if err := marshalProperty(w, p.name, p.tag, p.value); err != nil {
+				if fault == nil {
+					fault = err
+				}
+				if w.err != nil {
+					break
 				}
 			}
 		}
-	case reflect.Struct:
-		for i := 0; i < value.NumField(); i++ {
-			tag := value.Type().Field(i).Tag
-			var name string
-			if strings.Contains(string(tag), ":") {
-				name = tag.Get("zpl")
-			} else {
-				name = string(tag)
+	}
+	return fault
+}
+
+// A property is a named value found in a map or struct.
+type property struct {
+	name   string
+	tag    tagInfo // the parsed tag of a struct field
+	weight int
+	value  reflect.Value
+}
+
+// Return the properties of a map or struct in the order they should be
+// encoded: struct fields by their "zplorder" weight and then in declaration
+// order, map entries in the order chosen by SetKeyOrder, and subsections after
+// plain values if SetSectionsLast is in effect.
+func (e *Encoder) properties(value reflect.Value) ([]property, error) {
+	var props []property
+	switch value.Kind() {
+	case reflect.Map:
+		if !isEncodableMap(value.Type()) {
+			return nil, nil
+		}
+		for _, key := range value.MapKeys() {
+			name, err := mapKeyName(key)
+			if err != nil {
+				return nil, err
 			}
-			if len(tag) > 0 {
-				if err := marshalProperty(w, name, value.Field(i)); err != nil {
-					if fault == nil {
-						fault = err
-					}
+			props = append(props, property{name: name, value: value.MapIndex(key)})
+		}
+		less := e.keyLess
+		if less == nil {
+			less = func(a, b string) bool { return a < b }
+		}
+		sort.SliceStable(props, func(i, j int) bool {
+			return less(props[i].name, props[j].name)
+		})
+	case reflect.Struct:
+		tags := fieldTags(value.Type(), e.jsonTags)
+		for i, tag := range tags {
+			field := value.Type().Field(i)
+			weight, _ := strconv.Atoi(field.Tag.Get("zplorder"))
+			if _, ok := tag.option("flatten"); ok {
+				inner, err := e.flattened(value.Field(i))
+				if err != nil {
+					return nil, err
+				}
+				for _, p := range inner {
+					p.weight = weight
+					props = append(props, p)
 				}
+				continue
 			}
+			if tag.Name == "" || tag.Name == "-" {
+				continue
+			}
+			props = append(props, property{name: tag.Name, tag: tag, weight: weight, value: value.Field(i)})
 		}
+		sort.SliceStable(props, func(i, j int) bool {
+			return props[i].weight < props[j].weight
+		})
 	}
-	return fault
+	if e.omitZero {
+		kept := props[:0]
+		for _, p := range props {
+			if !e.isZero(p.value) {
+				kept = append(kept, p)
+			}
+		}
+		props = kept
+	}
+	if e.sectionsLast {
+		sort.SliceStable(props, func(i, j int) bool {
+			return !isSection(props[i].value) && isSection(props[j].value)
+		})
+	}
+	return props, nil
+}
+
+// Return the properties of a struct field tagged ",flatten", which belong to
+// the enclosing section, or none if it is a nil pointer.
+func (e *Encoder) flattened(value reflect.Value) ([]property, error) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, e.unsupported("", value.Type())
+	}
+	return e.properties(value)
+}
+
+// Report whether value will be encoded as a section.
+func isSection(value reflect.Value) bool {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return false
+		}
+		value = value.Elem()
+	}
+	return value.Kind() == reflect.Map || value.Kind() == reflect.Struct && !isNullType(value.Type()) && !isBigType(value.Type()) ||
+		value.Type() == rawSectionType || isSectionSlice(value.Type())
+}
+
+// Write b to the buffer unless an earlier write failed, recording the first
+// write error.
+func (e *Encoder) write(b []byte) error {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+	return e.err
+}
+
+// Flush writes any buffered output to the underlying io.Writer and returns
+// the first error encountered by any write.  Encode, EncodeAt and
+// EncodeSource call Flush before they return, even when they fail.
+//
+func (e *Encoder) Flush() error {
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.err
+}
+
+// Return name as it should be written.
+func (e *Encoder) keyName(name string) string {
+	if e.lowerKeys {
+		return strings.ToLower(name)
+	}
+	return name
 }
 
 func (e *Encoder) addValue(name string, value string) error {
-	_, err := e.w.Write([]byte(e.indent + name + " = " + value + e.br))
-	return err
+	name = e.keyName(name)
+	if e.tree != nil {
+		e.tree[len(e.tree)-1].Add(name, value)
+		return e.err
+	} else if e.align {
+		e.lines = append(e.lines, encodedLine{indent: e.indent, name: name, value: value})
+		return e.err
+	}
+	return e.write([]byte(e.indent + name + e.sep + value + e.br))
 }
 
+// Write a property without a value, as in "key =".
+func (e *Encoder) addEmpty(name string) error {
+	name = e.keyName(name)
+	if e.tree != nil {
+		e.tree[len(e.tree)-1].Add(name, "")
+		return e.err
+	} else if e.align {
+		e.lines = append(e.lines, encodedLine{indent: e.indent, name: name, empty: true})
+		return e.err
+	}
+	return e.write([]byte(e.indent + name + strings.TrimRight(e.sep, " ") + e.br))
+}
+
+// Start a section.  The section is entered even if writing its name fails, so
+// every call must be paired with a call to endSection.
 func (e *Encoder) startSection(name string) error {
-	if _, err := e.w.Write([]byte(e.indent + name + e.br)); err != nil {
-		return err
+	name = e.keyName(name)
+	if e.tree != nil {
+		e.tree = append(e.tree, e.tree[len(e.tree)-1].AddSection(name))
+		e.indent += "    "
+		e.path = append(e.path, name)
+		return e.err
+	} else if e.align {
+		e.lines = append(e.lines, encodedLine{indent: e.indent, name: name, section: true})
+		e.indent += "    "
+		e.path = append(e.path, name)
+		return e.err
 	}
+	err := e.write([]byte(e.indent + name + e.br))
 	e.indent += "    "
-	return nil
+	e.path = append(e.path, name)
+	return err
 }
 
 func (e *Encoder) endSection() error {
@@ -144,52 +910,203 @@ func (e *Encoder) endSection() error {
 		panic("zpl: unexpected end of section.")
 	}
 	e.indent = e.indent[:len(e.indent)-4]
+	e.path = e.path[:len(e.path)-1]
+	if e.tree != nil {
+		e.tree = e.tree[:len(e.tree)-1]
+	}
 	return nil
 }
 
-func marshalProperty(e *Encoder, name string, value reflect.Value) error {
+// Write the lines held for alignment, padding each property's key to the
+// length of the longest key among the properties of the same section.
+func (e *Encoder) writeAligned() error {
+	lines := e.lines
+	e.lines = nil
+	widths := make([]int, len(lines))
+	closeSection := func(members []int) {
+		width := 0
+		for _, i := range members {
+			if !lines[i].section && len(lines[i].name) > width {
+				width = len(lines[i].name)
+			}
+		}
+		for _, i := range members {
+			widths[i] = width
+		}
+	}
+	var members [][]int // indexes of lines in each open section, by depth
+	for i, line := range lines {
+		depth := len(line.indent) / 4
+		for len(members) > depth+1 {
+			closeSection(members[len(members)-1])
+			members = members[:len(members)-1]
+		}
+		for len(members) < depth+1 {
+			members = append(members, nil)
+		}
+		members[depth] = append(members[depth], i)
+		if line.section {
+			members = append(members[:depth+1], nil)
+		}
+	}
+	for len(members) > 0 {
+		closeSection(members[len(members)-1])
+		members = members[:len(members)-1]
+	}
+	var buf bytes.Buffer
+	for i, line := range lines {
+		buf.WriteString(line.indent)
+		buf.WriteString(line.name)
+		if !line.section {
+			buf.WriteString(strings.Repeat(" ", widths[i]-len(line.name)))
+			if line.empty {
+				buf.WriteString(strings.TrimRight(e.sep, " "))
+			} else {
+				buf.WriteString(e.sep)
+				buf.WriteString(line.value)
+			}
+		}
+		buf.WriteString(e.br)
+	}
+	return e.write(buf.Bytes())
+}
+
+// Format a float according to the field's "format" and "prec" options, or the
+// encoder's defaults where those are absent.
+func (e *Encoder) formatFloat(f float64, bits int, tag tagInfo) string {
+	fmt, prec := e.floatFmt, e.floatPrec
+	if format, ok := tag.option("format"); ok && len(format) == 1 {
+		fmt = format[0]
+	}
+	if p, ok := tag.option("prec"); ok {
+		if n, err := strconv.Atoi(p); err == nil {
+			prec = n
+		}
+	}
+	return strconv.FormatFloat(f, fmt, prec, bits)
+}
+
+func marshalProperty(e *Encoder, name string, tag tagInfo, value reflect.Value) error {
+	if value.Type() == rawSectionType {
+		s, err := Parse(value.Bytes())
+		if err != nil {
+			return err
+		}
+		fault := e.startSection(name)
+		if fault == nil {
+			fault = e.encodeSection(s)
+		}
+		if err := e.endSection(); err != nil && fault == nil {
+			fault = err
+		}
+		return fault
+	}
+	if _, ok := tag.option("mask"); ok && isMaskable(value, tag) {
+		return e.addValue(name, e.mask)
+	}
+	if isNullType(value.Type()) {
+		return marshalNull(e, name, tag, value)
+	}
+	if isBigType(value.Type()) {
+		return e.addValue(name, formatBig(value))
+	}
+	if s, ok := e.stringer(value); ok {
+		return e.addString(name, s.String(), value)
+	}
 	switch value.Type().Kind() {
 	case reflect.Map:
+		if !isEncodableMap(value.Type()) {
+			return e.unsupported(name, value.Type())
+		}
+		props, fault := e.properties(value)
+		if fault != nil {
+			return fault
+		}
 		if name != "*" {
-			e.startSection(name)
+			fault = e.startSection(name)
 		}
-		for _, key := range value.MapKeys() {
-			v := value.MapIndex(key)
-			if err := marshalProperty(e, key.Interface().(string), v); err != nil {
-				return err
+		for _, p := range props {
+			if fault != nil {
+				break
 			}
+			fault = marshalProperty(e, p.name, p.tag, p.value)
 		}
 		if name != "*" {
-			if err := e.endSection(); err != nil {
-				return err
+			if err := e.endSection(); err != nil && fault == nil {
+				fault = err
 			}
 		}
+		return fault
 	case reflect.Struct:
-		e.startSection(name)
-		e.encode(value)
-		if err := e.endSection(); err != nil {
-			return err
+		fault := e.startSection(name)
+		if fault == nil {
+			if value.Type() == sectionType {
+				s := value.Interface().(Section)
+				fault = e.encodeSection(&s)
+			} else {
+				fault = e.encode(value)
+			}
+		}
+		if err := e.endSection(); err != nil && fault == nil {
+			fault = err
 		}
-	case reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
-		e.addValue(name, strconv.FormatInt(value.Int(), 10))
-	case reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
-		e.addValue(name, strconv.FormatUint(value.Uint(), 10))
+		return fault
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return e.addValue(name, strconv.FormatInt(value.Int(), 10))
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint, reflect.Uintptr:
+		return e.addValue(name, strconv.FormatUint(value.Uint(), 10))
 	case reflect.Float32, reflect.Float64:
-		e.addValue(name, strconv.FormatFloat(value.Float(), 'f', -1, value.Type().Bits()))
+		f := value.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			if !e.nonFinite {
+				return &UnsupportedValueError{
+					Value: value,
+					Str:   strconv.FormatFloat(f, 'g', -1, value.Type().Bits()),
+				}
+			}
+			return e.addValue(name, strconv.Quote(strconv.FormatFloat(f, 'g', -1, value.Type().Bits())))
+		}
+		return e.addValue(name, e.formatFloat(f, value.Type().Bits(), tag))
 	case reflect.Bool:
 		if value.Bool() {
-			e.addValue(name, "1")
-		} else {
-			e.addValue(name, "0")
+			return e.addValue(name, e.trueText)
 		}
+		return e.addValue(name, e.falseText)
 	case reflect.String:
-		e.addValue(name, value.String())
+		if value.Type() == rawValueType || value.Type() == numberType {
+			return e.addValue(name, value.String())
+		}
+		return e.addString(name, value.String(), value)
 	case reflect.Ptr, reflect.Interface:
 		if !value.IsNil() {
-			marshalProperty(e, name, value.Elem())
+			return marshalProperty(e, name, tag, value.Elem())
+		} else if value.Kind() == reflect.Ptr {
+			return e.encodeNil(name, value.Type().Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		if format := tag.Options["format"]; isBinaryFormat(value.Type(), format) {
+			return e.addValue(name, formatBinary(value.Bytes(), format))
+		}
+		if _, ok := tag.option("indexed"); ok && isSectionSlice(value.Type()) {
+			fault := e.startSection(name)
+			for i := 0; i < value.Len() && fault == nil; i++ {
+				fault = marshalProperty(e, strconv.Itoa(i), tagInfo{}, value.Index(i))
+			}
+			if err := e.endSection(); err != nil && fault == nil {
+				fault = err
+			}
+			return fault
+		}
+		// Each element is a repetition of the same property or section.
+		for i := 0; i < value.Len(); i++ {
+			if err := marshalProperty(e, name, tag, value.Index(i)); err != nil {
+				return err
+			}
 		}
 	default:
-		// Silently fail to marshal what we don't know how to marshal.
+		// Silently fail to marshal what we don't know how to marshal, unless
+		// the encoder is strict.
+		return e.unsupported(name, value.Type())
 	}
 	return nil
 }
@@ -5,7 +5,14 @@
 package zpl
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 type marshalCase struct {
@@ -32,6 +39,9 @@ func TestMarshal_Map(t *testing.T) {
 		{"version = 1\n", map[string]interface{}{"version": uint16(1)}},
 		{"version = 1\n", map[string]interface{}{"version": uint32(1)}},
 		{"version = 1\n", map[string]interface{}{"version": uint64(1)}},
+		{"version = 1\n", map[string]interface{}{"version": int8(1)}},
+		{"version = 1\n", map[string]interface{}{"version": uint8(1)}},
+		{"version = 1\n", map[string]interface{}{"version": uintptr(1)}},
 		{"version = 1\n", map[string]interface{}{"version": float32(1)}},
 		{"version = 1\n", map[string]interface{}{"version": float64(1)}},
 		{"ok = 1\n", map[string]interface{}{"ok": true}},
@@ -46,10 +56,718 @@ func TestMarshal_Map(t *testing.T) {
 	for _, c := range tests {
 		bytes, err := Marshal(c.Value)
 		if err != nil {
-			t.Errorf(err.Error())
+			t.Error(err.Error())
 		}
 		if string(bytes) != c.Output {
 			t.Errorf("unexpected result: %s", string(bytes))
 		}
 	}
 }
+
+func TestEncoder_SetBoolLiterals(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetBoolLiterals("yes", "no")
+	if err := e.Encode(map[string]bool{"ok": true}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "ok = yes\n" {
+		t.Errorf("unexpected result: %s", buf.String())
+	}
+}
+
+func TestEncoder_SetBoolStyle(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetBoolStyle(BoolWords)
+	if err := e.Encode(map[string]bool{"no": false, "ok": true}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "no = false\nok = true\n" {
+		t.Errorf("unexpected result: %s", buf.String())
+	}
+	var back map[string]bool
+	if err := Unmarshal(buf.Bytes(), &back); err != nil || !back["ok"] || back["no"] {
+		t.Errorf("round trip gave %v, %v", back, err)
+	}
+}
+
+type omitMock struct {
+	Name    string            `zpl:"name"`
+	Port    int               `zpl:"port"`
+	Debug   bool              `zpl:"debug"`
+	Hosts   []string          `zpl:"host"`
+	Labels  map[string]string `zpl:"labels"`
+	Options *struct {
+		Linger int `zpl:"linger"`
+	} `zpl:"options"`
+	Limits struct {
+		Max int `zpl:"max"`
+	} `zpl:"limits"`
+}
+
+func TestEncoder_OmitZero(t *testing.T) {
+	var v omitMock
+	v.Name = "x"
+	v.Labels = map[string]string{"empty": ""}
+	v.Options = &struct {
+		Linger int `zpl:"linger"`
+	}{}
+	v.Limits.Max = 3
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.OmitZero(true)
+	if err := e.Encode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "name = x\nlimits\n    max = 3\n" {
+		t.Errorf("unexpected result:\n%s", buf.String())
+	}
+}
+
+type nilMock struct {
+	Name    *string `zpl:"name"`
+	Options *struct {
+		Linger int `zpl:"linger"`
+	} `zpl:"options"`
+	Port *int `zpl:"port"`
+}
+
+func TestEncoder_SetNilPolicy(t *testing.T) {
+	cases := []struct {
+		Policy NilPolicy
+		Output string
+	}{
+		{NilSkip, "port = 80\n"},
+		{NilEmpty, "name =\noptions\nport = 80\n"},
+		{NilComment, "# name =\n# options\nport = 80\n"},
+	}
+	port := 80
+	for _, c := range cases {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		e.SetNilPolicy(c.Policy)
+		if err := e.Encode(&nilMock{Port: &port}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.Output {
+			t.Errorf("policy %d: unexpected result:\n%s", c.Policy, buf.String())
+		}
+	}
+	var v nilMock
+	if err := Unmarshal([]byte(cases[2].Output), &v); err != nil || *v.Port != 80 {
+		t.Errorf("commented template failed to unmarshal: %v", err)
+	}
+}
+
+func TestEncoder_SetQuoteStrings(t *testing.T) {
+	in := map[string]string{"a": " padded ", "b": "", "c": `say "hi"`}
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetQuoteStrings(true)
+	if err := e.Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "a = \" padded \"\nb = \"\"\nc = \"say \"hi\"\"\n" {
+		t.Errorf("unexpected result:\n%s", buf.String())
+	}
+	var out map[string]string
+	if err := Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip gave %q", out)
+	}
+}
+
+type stringerPoint struct{ X, Y int }
+
+func (p *stringerPoint) String() string { return fmt.Sprintf("%d,%d", p.X, p.Y) }
+
+type stringerMock struct {
+	Origin stringerPoint `zpl:"origin"`
+	Scale  complex128    `zpl:"scale"`
+	Size   time.Duration `zpl:"size"`
+}
+
+func TestEncoder_SetStringerFallback(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetStringerFallback(true)
+	if err := e.Encode(&stringerMock{Origin: stringerPoint{1, 2}, Scale: 1i, Size: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "origin = 1,2\nsize = 1000000000\n" {
+		t.Errorf("unexpected result:\n%s", buf.String())
+	}
+}
+
+type maskMock struct {
+	User     string   `zpl:"user"`
+	Password string   `zpl:"password,mask"`
+	Keys     []string `zpl:"key,mask"`
+	Token    *string  `zpl:"token,mask"`
+}
+
+func TestEncoder_SetMask(t *testing.T) {
+	v := maskMock{User: "admin", Password: "hunter2", Keys: []string{"a", "b"}}
+	out, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "user = admin\npassword = ******\nkey = ******\nkey = ******\n" {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetMask("<redacted>")
+	if err := e.Encode(&maskMock{Password: "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "user = \"\"\npassword = <redacted>\n" {
+		t.Errorf("unexpected result:\n%s", buf.String())
+	}
+}
+
+func TestEncoder_SetHeader(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetHeader("generated by zplgen v1.2 - do not edit", "", "## keep")
+	for i := 0; i < 2; i++ {
+		if err := e.Encode(map[string]string{"a": "1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buf.String() != "# generated by zplgen v1.2 - do not edit\n#\n## keep\na = 1\na = 1\n" {
+		t.Errorf("unexpected result:\n%s", buf.String())
+	}
+	if err := Unmarshal(buf.Bytes(), &map[string]string{}); err != nil {
+		t.Errorf("failed to unmarshal: %s", err)
+	}
+}
+
+func TestEncoder_SetJSONTagFallback(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetJSONTagFallback(true)
+	if err := e.Encode(&jsonMock{Address: "localhost", Port: 80, Skipped: "x", Region: "eu"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "addr = localhost\np = 80\nRegion = eu\n" {
+		t.Errorf("unexpected result: %s", buf.String())
+	}
+}
+
+type alignMock struct {
+	Type    string        `type`
+	Options *alignOptions `option`
+	Bind    string        `bind`
+	Verbose bool          `verbose`
+}
+
+type alignOptions struct {
+	Hwm       int    `hwm`
+	Subscribe string `subscribe`
+}
+
+func TestEncoder_SetAlign(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetAlign(true)
+	v := map[string]interface{}{
+		"frontend": &alignMock{
+			Type:    "sub",
+			Options: &alignOptions{Hwm: 1000, Subscribe: "#2"},
+			Bind:    "tcp://eth0:5555",
+			Verbose: true,
+		},
+	}
+	if err := e.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	expect := "frontend\n" +
+		"    type    = sub\n" +
+		"    option\n" +
+		"        hwm       = 1000\n" +
+		"        subscribe = #2\n" +
+		"    bind    = tcp://eth0:5555\n" +
+		"    verbose = 1\n"
+	if buf.String() != expect {
+		t.Errorf("unexpected result:\n%s", buf.String())
+	}
+}
+
+func TestEncoder_SetSeparator(t *testing.T) {
+	for sep, expect := range map[string]string{
+		"=":     "type=sub\nbind=*\nverbose=0\n",
+		"  =  ": "type  =  sub\nbind  =  *\nverbose  =  0\n",
+	} {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		e.SetSeparator(sep)
+		if err := e.Encode(&alignMock{Type: "sub", Bind: "*"}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != expect {
+			t.Errorf("unexpected result with separator %q:\n%s", sep, buf.String())
+		}
+		m := make(map[string]string)
+		if err := Unmarshal(buf.Bytes(), m); err != nil {
+			t.Errorf("failed to unmarshal with separator %q: %s", sep, err)
+		} else if m["type"] != "sub" {
+			t.Errorf("type = %q with separator %q", m["type"], sep)
+		}
+	}
+}
+
+type orderMock struct {
+	Options *alignOptions `zpl:"option" zplorder:"-1"`
+	Bind    string        `zpl:"bind" zplorder:"10"`
+	Type    string        `zpl:"type"`
+}
+
+func TestEncoder_Order(t *testing.T) {
+	v := map[string]interface{}{
+		"b": &orderMock{Bind: "*", Type: "sub", Options: &alignOptions{}},
+		"a": 1,
+		"c": 2,
+	}
+	out, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "a = 1\nb\n    option\n        hwm = 0\n        subscribe = \"\"\n    type = sub\n    bind = *\nc = 2\n"
+	if string(out) != expect {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetKeyOrder(func(a, b string) bool { return a > b })
+	e.SetSectionsLast(true)
+	if err := e.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	expect = "c = 2\na = 1\nb\n    type = sub\n    bind = *\n    option\n        hwm = 0\n        subscribe = \"\"\n"
+	if buf.String() != expect {
+		t.Errorf("unexpected result:\n%s", buf.String())
+	}
+}
+
+type floatMock struct {
+	Plain float64 `zpl:"plain"`
+	Ratio float64 `zpl:"ratio,format=e,prec=3"`
+	Small float32 `zpl:"small,prec=2"`
+}
+
+func TestEncoder_SetFloatFormat(t *testing.T) {
+	v := &floatMock{Plain: 1e21, Ratio: 0.125, Small: 0.1}
+	out, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "plain = 1000000000000000000000\nratio = 1.250e-01\nsmall = 0.10\n"
+	if string(out) != expect {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetFloatFormat('g', -1)
+	if err := e.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	expect = "plain = 1e+21\nratio = 1.250e-01\nsmall = 0.1\n"
+	if buf.String() != expect {
+		t.Errorf("unexpected result:\n%s", buf.String())
+	}
+}
+
+func TestMarshal_NonFinite(t *testing.T) {
+	values := []interface{}{
+		map[string]float64{"f": math.Inf(1)},
+		map[string]interface{}{"s": &floatMock{Ratio: math.NaN()}},
+	}
+	for _, v := range values {
+		if _, err := Marshal(v); err == nil {
+			t.Errorf("expected error marshalling %v, got success.", v)
+		} else if _, ok := err.(*UnsupportedValueError); !ok {
+			t.Errorf("expected UnsupportedValueError, got %T: %s", err, err.Error())
+		}
+	}
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetNonFiniteFloats(true)
+	if err := e.Encode(map[string]float64{"f": math.Inf(-1)}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "f = \"-Inf\"\n" {
+		t.Errorf("unexpected result: %s", buf.String())
+	}
+	m := make(map[string]float64)
+	if err := Unmarshal(buf.Bytes(), m); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	} else if !math.IsInf(m["f"], -1) {
+		t.Errorf("f = %v", m["f"])
+	}
+}
+
+// A failingWriter accepts a limited number of bytes and then fails.
+type failingWriter struct {
+	limit  int
+	writes int
+}
+
+var errFailingWriter = errors.New("write failed")
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	w.writes++
+	if len(b) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errFailingWriter
+	}
+	w.limit -= len(b)
+	return len(b), nil
+}
+
+func TestEncoder_WriteError(t *testing.T) {
+	v := map[string]interface{}{
+		"a": 1,
+		"b": &alignMock{Type: "sub", Options: &alignOptions{Hwm: 1}},
+		"c": map[string]string{"x": "y"},
+	}
+	for _, limit := range []int{0, 3, 6, 20, 50} {
+		for _, align := range []bool{false, true} {
+			w := &failingWriter{limit: limit}
+			e := NewEncoder(w)
+			e.SetAlign(align)
+			if err := e.Encode(v); err != errFailingWriter {
+				t.Errorf("expected write error with limit %d, got %v", limit, err)
+			}
+			writes := w.writes
+			if err := e.Encode(v); err != errFailingWriter {
+				t.Errorf("expected repeated write error with limit %d, got %v", limit, err)
+			}
+			if w.writes != writes {
+				t.Errorf("encoder kept writing after an error with limit %d", limit)
+			}
+		}
+	}
+}
+
+type strictMock struct {
+	Name   string       `zpl:"name"`
+	Nested *strictInner `zpl:"nested"`
+	Ports  map[int]bool `zpl:"ports"`
+}
+
+type strictInner struct {
+	Name    string `zpl:"name"`
+	Handler func() `zpl:"handler"`
+}
+
+func TestEncoder_SetStrict(t *testing.T) {
+	v := &strictMock{Name: "a", Nested: &strictInner{Name: "b", Handler: func() {}}}
+	out, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("unexpected error without strict mode: %s", err)
+	} else if string(out) != "name = a\nnested\n    name = b\n" {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	cases := []struct {
+		Value interface{}
+		Path  string
+	}{
+		{v, "nested/handler"},
+		{&strictMock{Ports: map[int]bool{}}, "ports"},
+		{map[string]interface{}{"c": make(chan int)}, "c"},
+		{map[int]string{}, ""},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		e.SetStrict(true)
+		err := e.Encode(c.Value)
+		if ute, ok := err.(*UnsupportedTypeError); !ok {
+			t.Errorf("expected UnsupportedTypeError for %T, got %T: %v", c.Value, err, err)
+		} else if ute.Path != c.Path {
+			t.Errorf("expected path %q, got %q", c.Path, ute.Path)
+		}
+	}
+}
+
+type serversMock struct {
+	Name    string `zpl:"name"`
+	Servers []struct {
+		Addr string `zpl:"addr"`
+		Port int    `zpl:"port"`
+	} `zpl:"server"`
+	Backups []*struct {
+		Addr string `zpl:"addr"`
+	} `zpl:"backup"`
+	Extra []map[string]string `zpl:"extra"`
+}
+
+func TestMarshal_SectionSlices(t *testing.T) {
+	src := `name = pool
+server
+    addr = a
+    port = 1
+server
+    addr = b
+    port = 2
+backup
+    addr = c
+extra
+    x = 1
+extra
+    y = 2
+`
+	var v serversMock
+	if err := Unmarshal([]byte(src), &v); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	if len(v.Servers) != 2 || v.Servers[1].Addr != "b" || v.Servers[1].Port != 2 {
+		t.Errorf("servers = %+v", v.Servers)
+	}
+	if len(v.Backups) != 1 || v.Backups[0].Addr != "c" || len(v.Extra) != 2 || v.Extra[1]["y"] != "2" {
+		t.Errorf("backups = %+v, extra = %v", v.Backups, v.Extra)
+	}
+	out, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != src {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+}
+
+type listsMock struct {
+	Hosts []string            `zpl:"host"`
+	Ports [2]int              `zpl:"port"`
+	Tags  map[string][]string `zpl:"tags"`
+}
+
+func TestMarshal_Slices(t *testing.T) {
+	v := listsMock{
+		Hosts: []string{"a", "b"},
+		Ports: [2]int{80, 443},
+		Tags: map[string][]string{
+			"env":  {"prod", "eu"},
+			"none": {},
+		},
+	}
+	expected := `host = a
+host = b
+port = 80
+port = 443
+tags
+    env = prod
+    env = eu
+`
+	out, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != expected {
+		t.Fatalf("unexpected result:\n%s", out)
+	}
+	var back listsMock
+	if err := Unmarshal(out, &back); err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Hosts) != 2 || back.Ports != v.Ports || len(back.Tags["env"]) != 2 || back.Tags["env"][1] != "eu" {
+		t.Errorf("round trip gave %+v", back)
+	}
+}
+
+type stepsMock struct {
+	Steps []*struct {
+		Run string `zpl:"run"`
+	} `zpl:"steps,indexed"`
+}
+
+func TestMarshal_Indexed(t *testing.T) {
+	src := `steps
+    0
+        run = make
+    1
+        run = make install
+`
+	var v stepsMock
+	if err := Unmarshal([]byte(src), &v); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	if len(v.Steps) != 2 || v.Steps[0].Run != "make" || v.Steps[1].Run != "make install" {
+		t.Fatalf("steps = %+v", v.Steps)
+	}
+	out, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != src {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	var unordered stepsMock
+	if err := Unmarshal([]byte("steps\n    1\n        run = b\n    0\n        run = a\n"), &unordered); err != nil {
+		t.Fatal(err)
+	}
+	if len(unordered.Steps) != 2 || unordered.Steps[0].Run != "a" || unordered.Steps[1].Run != "b" {
+		t.Errorf("unordered steps = %+v", unordered.Steps)
+	}
+	if err := Unmarshal([]byte("steps\n    first\n        run = a\n"), &unordered); err == nil {
+		t.Errorf("expected an error for a section name that is not an index")
+	}
+}
+
+type flattenTimeouts struct {
+	Read  int `zpl:"read"`
+	Write int `zpl:"write"`
+}
+
+type flattenMock struct {
+	Name     string           `zpl:"name"`
+	Timeouts flattenTimeouts  `zpl:",flatten"`
+	Limits   *flattenTimeouts `zpl:"limits"`
+	Extra    *struct {
+		Retries int `zpl:"retries"`
+	} `zpl:",flatten"`
+	Port int `zpl:"port"`
+}
+
+func TestMarshal_Flatten(t *testing.T) {
+	v := flattenMock{Name: "api", Timeouts: flattenTimeouts{5, 10}, Limits: &flattenTimeouts{1, 2}, Port: 80}
+	expected := `name = api
+read = 5
+write = 10
+limits
+    read = 1
+    write = 2
+port = 80
+`
+	out, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != expected {
+		t.Fatalf("unexpected result:\n%s", out)
+	}
+	var back flattenMock
+	if err := Unmarshal(append(out, "retries = 3\n"...), &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Timeouts != v.Timeouts || *back.Limits != *v.Limits || back.Port != 80 {
+		t.Errorf("round trip gave %+v", back)
+	}
+	if back.Extra == nil || back.Extra.Retries != 3 {
+		t.Errorf("expected retries in a flattened pointer, got %+v", back.Extra)
+	}
+	if err := Unmarshal([]byte("other = 1\n"), &back); err == nil {
+		t.Errorf("expected an error for an unknown key")
+	}
+}
+
+type ifaceDevice struct {
+	Type    string                 `zpl:"type"`
+	Sockets map[string]interface{} `zpl:"*"`
+}
+
+type ifaceMock struct {
+	Name  string      `zpl:"name"`
+	Extra interface{} `zpl:",flatten"`
+}
+
+func TestMarshal_Interfaces(t *testing.T) {
+	var socket interface{} = map[string]interface{}{"bind": []interface{}{"tcp://a", "tcp://b"}}
+	for _, c := range []marshalCase{
+		{"devices\n    main\n        type = zmq_queue\n        frontend\n            bind = tcp://a\n            bind = tcp://b\n",
+			map[string]interface{}{"devices": map[string]*ifaceDevice{"main": {Type: "zmq_queue", Sockets: map[string]interface{}{"frontend": &socket}}}}},
+		{"a = 1\na\n    x = y\na = 2\na = 3\n",
+			map[string]interface{}{"a": []interface{}{int8(1), map[string]interface{}{"x": "y"}, []interface{}{"2", uint(3)}}}},
+		{"a = 1\ndev1/2 = x\n",
+			map[interface{}]interface{}{"a": 1, deviceID{1, 2}: "x"}},
+		{"name = n\ntype = q\n", &ifaceMock{Name: "n", Extra: &ifaceDevice{Type: "q"}}},
+		{"name = n\n", &ifaceMock{Name: "n"}},
+		{"", nil},
+		{"", (*ifaceDevice)(nil)},
+		{"", map[string]interface{}{"nil": nil, "nilptr": (*int)(nil)}},
+	} {
+		out, err := Marshal(c.Value)
+		if err != nil {
+			t.Errorf("%#v: %s", c.Value, err)
+		} else if string(out) != c.Output {
+			t.Errorf("%#v: expected:\n%s\ngot:\n%s", c.Value, c.Output, out)
+		}
+	}
+	for _, bad := range []interface{}{map[interface{}]string{1: "one"}, map[interface{}]string{nil: "nil"}} {
+		if _, err := Marshal(bad); err == nil {
+			t.Errorf("%#v: expected an error for the key", bad)
+		}
+	}
+}
+
+func TestMarshal_DecodedInterfaces(t *testing.T) {
+	doc := "main\n    bind = tcp://a\n    bind = tcp://b\n    frontend\n        option\n            hwm = 1000\nversion = 0.1\n"
+	var v interface{}
+	if err := Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := Unmarshal([]byte(doc), &m); err != nil {
+		t.Fatal(err)
+	}
+	nested := "doc\n" + strings.TrimSuffix(strings.Replace("    "+doc, "\n", "\n    ", -1), "    ")
+	for _, c := range []marshalCase{
+		{doc, &v},
+		{doc, v},
+		{doc, m},
+		{doc, &m},
+		{nested, map[string]interface{}{"doc": &v}},
+	} {
+		out, err := Marshal(c.Value)
+		if err != nil {
+			t.Errorf("%T: %s", c.Value, err)
+		} else if string(out) != c.Output {
+			t.Errorf("%T: expected:\n%s\ngot:\n%s", c.Value, c.Output, out)
+		}
+	}
+}
+
+type countingWriter struct {
+	writes int
+	buf    bytes.Buffer
+	err    error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.buf.Write(p)
+}
+
+func TestEncoder_Flush(t *testing.T) {
+	var w countingWriter
+	e := NewEncoder(&w)
+	v := map[string][]int{"a": {1, 2, 3, 4, 5, 6, 7, 8}}
+	if err := e.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if w.writes != 1 {
+		t.Errorf("expected 1 write, got %d", w.writes)
+	}
+	if w.buf.String() != "a = 1\na = 2\na = 3\na = 4\na = 5\na = 6\na = 7\na = 8\n" {
+		t.Errorf("unexpected result:\n%s", w.buf.String())
+	}
+	if err := e.Flush(); err != nil || w.writes != 1 {
+		t.Errorf("empty flush: %v after %d writes", err, w.writes)
+	}
+	failure := errors.New("disk full")
+	w.err = failure
+	if err := e.Encode(v); err != failure {
+		t.Errorf("expected %v, got %v", failure, err)
+	}
+	if err := e.Encode(v); err != failure || w.writes != 2 {
+		t.Errorf("expected %v without writing again, got %v after %d writes", failure, err, w.writes)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"sort"
+	"strings"
+)
+
+// ToEnv returns the properties of doc as environment variable assignments in
+// the form "KEY=value", sorted by key.  Each key is the upper-cased path of a
+// property joined by underscores and preceded by prefix and an underscore
+// unless prefix is empty, so with prefix "APP" the property "bind" in section
+// "frontend" of section "main" becomes APP_MAIN_FRONTEND_BIND.  The values of
+// a repeated property are joined by line feeds, as by Flatten.
+//
+func ToEnv(doc *Section, prefix string) []string {
+	if prefix != "" {
+		prefix += "_"
+	}
+	var env []string
+	for path, value := range Flatten(doc) {
+		key := strings.ToUpper(strings.Replace(path, ".", "_", -1))
+		env = append(env, prefix+key+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// FromEnv is the inverse of ToEnv: it returns a document built from the
+// entries of environ, in the form returned by os.Environ, whose keys begin
+// with prefix and an underscore.  Names are lower-cased, since ZPL keys are
+// case sensitive but environment variables are conventionally upper case.
+// It is an error for a matching variable to be both a property and a section
+// or to have an empty or otherwise invalid name, as in APP__BIND, except that
+// with an empty prefix, variables whose names are not valid ZPL keys, such as
+// "_", are skipped.
+//
+func FromEnv(environ []string, prefix string) (*Section, error) {
+	if prefix != "" {
+		prefix += "_"
+	}
+	m := make(map[string]string)
+	for _, kv := range environ {
+		i := strings.Index(kv, "=")
+		if i < 0 || !strings.HasPrefix(kv[:i], prefix) {
+			continue
+		}
+		path := strings.ToLower(strings.Replace(kv[len(prefix):i], "_", ".", -1))
+		if prefix == "" && !isPath(path) {
+			continue
+		}
+		m[path] = kv[i+1:]
+	}
+	return Unflatten(m)
+}
+
+// Report whether each dot-separated name in path may be used as a ZPL key.
+func isPath(path string) bool {
+	for _, name := range strings.Split(path, ".") {
+		if !isKey(name) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToEnv(t *testing.T) {
+	doc, err := Parse([]byte("version = 1\nmain\n    frontend\n        bind = tcp://eth0:5555\n        bind = inproc://device\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := ToEnv(doc, "APP")
+	expected := []string{
+		"APP_MAIN_FRONTEND_BIND=tcp://eth0:5555\ninproc://device",
+		"APP_VERSION=1",
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("unexpected result: %q", env)
+	}
+	back, err := FromEnv(append(env, "HOME=/root", "APPLE=1"), "APP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := Diff(doc, back); len(changes) != 0 {
+		t.Errorf("round trip changed %v", changes)
+	}
+}
+
+func TestFromEnv_NoPrefix(t *testing.T) {
+	environ := []string{
+		"HOME=/home/user",
+		"PATH=/usr/local/bin:/usr/bin:/bin",
+		"_=/usr/bin/env",
+		"__CF_USER_TEXT_ENCODING=0x1F5:0x0:0x0",
+		"=C:=C:\\",
+		"ProgramFiles(x86)=C:\\Program Files (x86)",
+		"SSH_AUTH_SOCK=/tmp/ssh-agent.sock",
+		"EMPTY=",
+	}
+	doc, err := FromEnv(environ, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc.Keys(), []string{"empty", "home", "path", "ssh"}) {
+		t.Errorf("unexpected keys %q", doc.Keys())
+	}
+	if doc.Value("home") != "/home/user" || doc.Section("ssh").Section("auth").Value("sock") != "/tmp/ssh-agent.sock" {
+		t.Errorf("unexpected result:\n%s", doc)
+	}
+}
+
+func TestFromEnv_Errors(t *testing.T) {
+	for _, env := range [][]string{
+		{"APP__BIND=x"},
+		{"APP_MAIN=1", "APP_MAIN_BIND=x"},
+	} {
+		if _, err := FromEnv(env, "APP"); err == nil {
+			t.Errorf("expected an error for %q", env)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+// Equal reports whether the ZPL documents a and b are structurally the same:
+// whether they have the same sections, including empty ones, and the same
+// values for each property.  Comments, blank lines, spacing around "=",
+// quotes around values and the order of different keys are ignored, while
+// the order of a repeated property's values is significant.  A document that
+// cannot be parsed causes an error.
+//
+func Equal(a, b []byte) (bool, error) {
+	sa, err := Parse(a)
+	if err != nil {
+		return false, err
+	}
+	sb, err := Parse(b)
+	if err != nil {
+		return false, err
+	}
+	return len(Diff(sa, sb)) == 0 && equalSections(sa, sb), nil
+}
+
+// Report whether a and b have subsections of the same names, recursively.
+func equalSections(a, b *Section) bool {
+	if len(a.sections) != len(b.sections) {
+		return false
+	}
+	for name, sub := range a.sections {
+		if other, ok := b.sections[name]; !ok || !equalSections(sub, other) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	base := "version = 1\nmain\n    bind = a\n    bind = b\n    type = zmq_queue\n"
+	cases := []struct {
+		Other string
+		Equal bool
+	}{
+		{"# comment\nmain\n    type=\"zmq_queue\"\n\n    bind = a\n    bind = b\nversion = 1\n", true},
+		{"version = 1\nmain\n    bind = b\n    bind = a\n    type = zmq_queue\n", false},
+		{"version = 1\nmain\n    bind = a\n    bind = b\n    type = zmq_queue\nextra\n", false},
+		{"version = 2\nmain\n    bind = a\n    bind = b\n    type = zmq_queue\n", false},
+	}
+	for i, c := range cases {
+		equal, err := Equal([]byte(base), []byte(c.Other))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if equal != c.Equal {
+			t.Errorf("case %d: Equal returned %v", i, equal)
+		}
+	}
+	if _, err := Equal([]byte(base), []byte("bad line\n")); err == nil {
+		t.Errorf("expected an error for an invalid document")
+	}
+}
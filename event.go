@@ -0,0 +1,66 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"strconv"
+)
+
+// An EventType identifies the kind of an Event.
+//
+type EventType int
+
+const (
+	AddValue     EventType = iota // a key = value property
+	EndSection                    // the end of the most recently started section
+	StartSection                  // a section header
+)
+
+func (t EventType) String() string {
+	switch t {
+	case AddValue:
+		return "AddValue"
+	case EndSection:
+		return "EndSection"
+	case StartSection:
+		return "StartSection"
+	}
+	return "EventType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// An Event is one step in the parsing of a ZPL document: a property, the
+// start of a section or the end of one.  Every StartSection event is matched
+// by a later EndSection event, except that sections still open at the end of
+// the document are not explicitly ended.
+//
+type Event struct {
+	Type  EventType
+	Name  string // the key of a property or the name of a section
+	Value string // the value of a property, with any quotes removed
+}
+
+// A Sink consumes the events produced while parsing a ZPL document.  The
+// builders that Decoder uses to fill in Go values and Sections are Sinks, and
+// other packages can implement Sink to convert, validate or print documents
+// using the same parser.
+//
+// The event passed to Consume is only valid until Consume returns.  If
+// Consume returns an error, decoding stops with that error.
+//
+type Sink interface {
+	Consume(e *Event) error
+}
+
+// A Source produces the events of a ZPL document one at a time.  Next returns
+// io.EOF when there are no more events.  The event it returns is only valid
+// until the following call to Next.
+//
+// A Decoder is a Source, and an Encoder can write the events of any Source
+// (see Encoder.EncodeSource), so that a document can be streamed from one to
+// the other without holding it in memory.
+//
+type Source interface {
+	Next() (*Event, error)
+}
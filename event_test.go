@@ -0,0 +1,91 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEventType_String(t *testing.T) {
+	tests := map[EventType]string{
+		AddValue:      "AddValue",
+		EndSection:    "EndSection",
+		StartSection:  "StartSection",
+		EventType(42): "EventType(42)",
+	}
+	for typ, expect := range tests {
+		if s := typ.String(); s != expect {
+			t.Errorf("%d: expected %s, got %s", int(typ), expect, s)
+		}
+	}
+}
+
+type eventRecorder []string
+
+func (r *eventRecorder) Consume(e *Event) error {
+	s := e.Type.String()
+	if e.Type != EndSection {
+		s += " " + e.Name
+	}
+	if e.Type == AddValue {
+		s += "=" + e.Value
+	}
+	*r = append(*r, s)
+	return nil
+}
+
+func TestDecoder_DecodeTo(t *testing.T) {
+	var events eventRecorder
+	d := NewDecoder(bytes.NewReader([]byte("a = 1\nb\n    c = \"2\"\n    d\n        e = 3\nf = 4\n")))
+	if err := d.DecodeTo(&events); err != nil {
+		t.Fatal(err)
+	}
+	expect := eventRecorder{
+		"AddValue a=1",
+		"StartSection b",
+		"AddValue c=2",
+		"StartSection d",
+		"AddValue e=3",
+		"EndSection",
+		"EndSection",
+		"AddValue f=4",
+	}
+	if !reflect.DeepEqual(events, expect) {
+		t.Errorf("unexpected events: %q", events)
+	}
+}
+
+type failingSink struct{ err error }
+
+func (s failingSink) Consume(e *Event) error { return s.err }
+
+func TestDecoder_DecodeTo_Error(t *testing.T) {
+	stop := errors.New("stop")
+	if err := NewDecoder(bytes.NewReader(raw0)).DecodeTo(failingSink{stop}); err != stop {
+		t.Errorf("expected %v, got %v", stop, err)
+	}
+}
+
+func TestEncoder_EncodeSource(t *testing.T) {
+	var buf bytes.Buffer
+	d := NewDecoder(bytes.NewReader([]byte("a=1\n# comment\nb\n    c   = 2\n    d\n        e = 3\n")))
+	e := NewEncoder(&buf)
+	if err := e.EncodeSource(d); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(map[string]string{"f": "4"}); err != nil {
+		t.Fatal(err)
+	}
+	expect := "a = 1\nb\n    c = 2\n    d\n        e = 3\nf = 4\n"
+	if buf.String() != expect {
+		t.Errorf("unexpected result:\n%s", buf.String())
+	}
+	if err := NewEncoder(&buf).EncodeSource(NewDecoder(bytes.NewReader(bad0))); err == nil {
+		t.Errorf("expected a syntax error")
+	}
+}
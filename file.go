@@ -0,0 +1,64 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// DecodeFile parses the ZPL file at path and stores the result in the value
+// pointed to by v.  Positions recorded in a Section refer to path.
+//
+// Where the operating system allows it, the file is memory-mapped rather than
+// read, so that very large machine-generated documents can be parsed without
+// being copied into memory first.  The file must not be changed while it is
+// being decoded: if it is truncated, reading past its new end may crash the
+// program with SIGBUS.  A file that may be rewritten in place, such as one
+// watched for changes, should be read with os.ReadFile and decoded with
+// Unmarshal instead.
+//
+// See the documentation for Unmarshal for details about the conversion of ZPL
+// into a Go value.
+//
+func DecodeFile(path string, v interface{}) error {
+	data, release, err := mapFile(path)
+	if err != nil {
+		return err
+	}
+	defer release()
+	d := &Decoder{buffer: data, eof: true, filename: path}
+	return d.Decode(v)
+}
+
+// UnmarshalFS decodes every file in fsys whose name matches pattern, as for
+// fs.Glob, into the value pointed to by v.  The files are decoded in lexical
+// order of their names, so that a value in a later file replaces one from an
+// earlier file, as with "00-base.zpl" and "10-site.zpl", and sections and
+// repeated properties are merged as if the files were one document.  This
+// works equally for embed.FS, zip archives and test fixtures.
+//
+// It is an error for no file to match.  Positions recorded in a Section refer
+// to the names of the files within fsys.
+//
+func UnmarshalFS(fsys fs.FS, pattern string, v interface{}) error {
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	} else if len(names) == 0 {
+		return errors.New("zpl: no files match " + pattern)
+	}
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		d := &Decoder{buffer: data, eof: true, filename: name}
+		if err := d.Decode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package zpl
+
+import (
+	"io/ioutil"
+)
+
+// Return the contents of the file at path, along with a function that
+// releases them.
+func mapFile(path string) (data []byte, release func(), err error) {
+	data, err = ioutil.ReadFile(path)
+	return data, func() {}, err
+}
@@ -0,0 +1,78 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDecodeFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "zdcf.zpl")
+	if err = ioutil.WriteFile(path, raw0, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var conf ZdcfRoot
+	if err = DecodeFile(path, &conf); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if conf.Devices["main"].Sockets["backend"].Bind[1] != "inproc://device" {
+		t.Errorf("main/backend/bind[1] = %v", conf.Devices["main"].Sockets["backend"].Bind[1])
+	}
+	var s Section
+	if err = DecodeFile(path, &s); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if pos := s.Position("main"); pos.Filename != path {
+		t.Errorf("main position = %v", pos)
+	}
+	empty := filepath.Join(dir, "empty.zpl")
+	if err = ioutil.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = DecodeFile(empty, &s); err != nil {
+		t.Errorf("failed to decode empty file: %s", err)
+	}
+	if err = DecodeFile(filepath.Join(dir, "missing.zpl"), &s); err == nil {
+		t.Errorf("expected error for missing file, got success.")
+	}
+}
+
+func TestUnmarshalFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf.d/10-site.zpl": {Data: []byte("bind = tcp://eth0:5555\nfrontend\n    hwm = 500\n")},
+		"conf.d/00-base.zpl": {Data: []byte("bind = tcp://*:5555\nlog = info\nfrontend\n    hwm = 1000\n    swap = 25\n")},
+		"conf.d/README":      {Data: []byte("not = zpl\n")},
+	}
+	var conf struct {
+		Bind     string            `zpl:"bind"`
+		Log      string            `zpl:"log"`
+		Frontend map[string]string `zpl:"frontend"`
+	}
+	if err := UnmarshalFS(fsys, "conf.d/*.zpl", &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Bind != "tcp://eth0:5555" || conf.Log != "info" || conf.Frontend["hwm"] != "500" || conf.Frontend["swap"] != "25" {
+		t.Errorf("unexpected result: %+v", conf)
+	}
+	var s Section
+	if err := UnmarshalFS(fsys, "conf.d/*.zpl", &s); err != nil {
+		t.Fatal(err)
+	}
+	if pos := s.Position("bind"); pos.Filename != "conf.d/00-base.zpl" {
+		t.Errorf("bind position = %v", pos)
+	}
+	if err := UnmarshalFS(fsys, "*.zpl", &conf); err == nil {
+		t.Errorf("expected an error when nothing matches")
+	}
+}
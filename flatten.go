@@ -0,0 +1,81 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Flatten returns the properties of doc and its subsections keyed by their
+// dotted paths, e.g. "main.frontend.bind", for storage in flat key-value
+// stores such as etcd, Consul or the environment.  The values of a repeated
+// property are joined by line feeds, which no ZPL value can contain.  Empty
+// sections have no properties and so do not appear.
+//
+func Flatten(doc *Section) map[string]string {
+	m := make(map[string]string)
+	Walk(doc, func(path []string, key, value string) error {
+		k := strings.Join(append(path[:len(path):len(path)], key), ".")
+		if prev, ok := m[k]; ok {
+			value = prev + "\n" + value
+		}
+		m[k] = value
+		return nil
+	})
+	return m
+}
+
+// Unflatten is the inverse of Flatten: it returns a document holding each
+// value of m at its dotted path, splitting values on line feeds into repeated
+// properties.  Properties and sections appear in the lexical order of their
+// paths.  It is an error for a path to be both a property and a section, or
+// to have a name that is not a valid ZPL key.
+//
+func Unflatten(m map[string]string) (*Section, error) {
+	paths := make([]string, 0, len(m))
+	for path := range m {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	doc := new(Section)
+	for _, path := range paths {
+		names := strings.Split(path, ".")
+		for _, name := range names {
+			if !isKey(name) {
+				return nil, errors.New("zpl: " + path + " has an invalid key name \"" + name + "\"")
+			}
+		}
+		s := doc
+		for i, name := range names[:len(names)-1] {
+			if s.HasValue(name) {
+				return nil, errors.New("zpl: " + strings.Join(names[:i+1], ".") + " is both a property and a section")
+			}
+			s = s.AddSection(name)
+		}
+		key := names[len(names)-1]
+		if s.Section(key) != nil {
+			return nil, errors.New("zpl: " + path + " is both a property and a section")
+		}
+		for _, value := range strings.Split(m[path], "\n") {
+			s.Add(key, value)
+		}
+	}
+	return doc, nil
+}
+
+// Report whether name may be used as a ZPL key.
+func isKey(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isAlphanumeric(name[i]) && name[i] != '/' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlatten(t *testing.T) {
+	doc, err := Parse([]byte(`version = 1
+main
+    frontend
+        bind = tcp://eth0:5555
+        bind = inproc://device
+    type = zmq_queue
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flat := Flatten(doc)
+	expected := map[string]string{
+		"version":            "1",
+		"main.frontend.bind": "tcp://eth0:5555\ninproc://device",
+		"main.type":          "zmq_queue",
+	}
+	if !reflect.DeepEqual(flat, expected) {
+		t.Errorf("unexpected result: %q", flat)
+	}
+	back, err := Unflatten(flat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := Diff(doc, back); len(changes) != 0 {
+		t.Errorf("round trip changed %v", changes)
+	}
+}
+
+func TestUnflatten_Errors(t *testing.T) {
+	cases := []map[string]string{
+		{"a": "1", "a.b": "2"},
+		{"a.b-c": "1"},
+		{"a..b": "1"},
+	}
+	for _, m := range cases {
+		if _, err := Unflatten(m); err == nil {
+			t.Errorf("expected an error for %q", m)
+		}
+	}
+}
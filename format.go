@@ -0,0 +1,134 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"io"
+)
+
+// FormatPreserve reformats the ZPL-encoded data while keeping its comments and
+// blank lines, so that hand-written files can be formatted automatically.
+// Unlike encoding a parsed Section, which produces a canonical document
+// without comments, FormatPreserve changes only layout:
+//
+// Each line is indented by 4 spaces per level of nesting, where a line that
+// is indented more than the section header before it is nested within that
+// section, whether it was indented with spaces or tabs.  A line indented more
+// than a preceding property stays at that property's level.  Comments are
+// indented like the line that follows them.
+//
+// Whitespace at the end of each line is removed, as is whitespace around the
+// "=" of each property, which is written as "key = value".  Since whitespace
+// at the end of a value is part of it, such a value is written in double
+// quotes instead, as in "key = \"value \"".  Line endings become "\n".
+//
+// A line that is neither a comment, a section header nor a property is
+// reported as a *SyntaxError.
+//
+func FormatPreserve(src []byte) ([]byte, error) {
+	var (
+		out      bytes.Buffer
+		columns  = []int{0} // indentation of each enclosing level in src
+		header   bool       // whether the previous setting was a section header
+		comments [][]byte   // comments waiting for the indentation of the next line
+		blank    int        // blank lines since the previous line
+		lineno   uint64
+	)
+	flush := func(depth int) {
+		for _, comment := range comments {
+			if comment == nil {
+				out.WriteByte('\n')
+				continue
+			}
+			out.Write(bytes.Repeat([]byte(" "), depth*4))
+			out.Write(comment)
+			out.WriteByte('\n')
+		}
+		comments = comments[:0]
+	}
+	src = bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+	src = bytes.ReplaceAll(src, []byte("\r"), []byte("\n"))
+	for _, raw := range bytes.Split(src, []byte("\n")) {
+		lineno++
+		line := bytes.TrimRight(raw, " \t")
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) == 0 {
+			blank++
+			continue
+		}
+		for ; blank > 0; blank-- {
+			comments = append(comments, nil)
+		}
+		if trimmed[0] == '#' {
+			comments = append(comments, trimmed)
+			continue
+		}
+		cols := 0
+		for _, c := range line[:len(line)-len(trimmed)] {
+			if c == '\t' {
+				cols += 4 - cols%4
+			} else {
+				cols++
+			}
+		}
+		if top := columns[len(columns)-1]; cols > top && header {
+			columns = append(columns, cols)
+		}
+		for len(columns) > 1 && cols < columns[len(columns)-1] {
+			columns = columns[:len(columns)-1]
+		}
+		depth := len(columns) - 1
+		var setting []byte
+		if i := bytes.IndexByte(trimmed, '='); i >= 0 {
+			key := bytes.TrimRight(trimmed[:i], " \t")
+			value := bytes.TrimLeft(trimmed[i+1:], " \t")
+			if trailing := raw[len(line):]; len(value) > 0 && len(trailing) > 0 {
+				value = append(append(append([]byte{'"'}, value...), trailing...), '"')
+			}
+			setting = append(append(append(setting, key...), " = "...), value...)
+		} else {
+			setting = trimmed
+		}
+		indented := append(bytes.Repeat([]byte(" "), depth*4), setting...)
+		if _, _, _, _, ok := scanLine(indented); !ok {
+			return nil, &SyntaxError{
+				Line: lineno,
+				msg:  "is neither a comment, a section header, nor a key = value setting.",
+			}
+		}
+		header = !bytes.Contains(setting, []byte("="))
+		flush(depth)
+		out.Write(indented)
+		out.WriteByte('\n')
+	}
+	// Comments at the end of the document belong to no particular line, and
+	// trailing blank lines are dropped.
+	flush(0)
+	return out.Bytes(), nil
+}
+
+// An Option configures the Encoder used by Reformat, typically by calling one
+// of its Set methods, e.g.
+//
+//     zpl.Reformat(os.Stdout, os.Stdin, func(e *zpl.Encoder) { e.SetSeparator("=") })
+//
+type Option func(e *Encoder)
+
+// Reformat reads a ZPL document from src and writes it to dst in the
+// Encoder's normalized form, one line at a time, so that documents too large
+// to hold in memory can be processed.  As when encoding a parsed Section,
+// comments are dropped and repeated sections are not merged.
+//
+// Options that require the whole document, such as Encoder.SetAlign, still
+// work but hold every line in memory until the end.
+//
+func Reformat(dst io.Writer, src io.Reader, opts ...Option) error {
+	e := NewEncoder(dst)
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e.EncodeSource(NewDecoder(src))
+}
@@ -0,0 +1,90 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormatPreserve(t *testing.T) {
+	src := []byte("# header comment\r\n" +
+		"version=1   \r\n" +
+		"\r\n" +
+		"main\n" +
+		"  # about type\n" +
+		"  type   =  zmq_queue\n" +
+		"  frontend\n" +
+		"\tbind = tcp://eth0:5555\n" +
+		"     hwm = 1\n" +
+		"  backend\n" +
+		"      bind = inproc://device\n" +
+		"\n" +
+		"other\n" +
+		"# trailing\n" +
+		"\n")
+	out, err := FormatPreserve(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `# header comment
+version = "1   "
+
+main
+    # about type
+    type = zmq_queue
+    frontend
+        bind = tcp://eth0:5555
+        hwm = 1
+    backend
+        bind = inproc://device
+
+other
+# trailing
+`
+	if string(out) != expect {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	if again, err := FormatPreserve(out); err != nil || string(again) != expect {
+		t.Errorf("formatting is not idempotent:\n%s", again)
+	}
+}
+
+func TestFormatPreserve_Values(t *testing.T) {
+	src := []byte("a = v \nb=\"q\"\t\nc =  \" x \"  \nd = plain\ns\n    e = w \t\n")
+	out, err := FormatPreserve(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same, err := Equal(src, out); err != nil || !same {
+		t.Errorf("formatting changed the document:\n%s", out)
+	}
+	if again, err := FormatPreserve(out); err != nil || !bytes.Equal(again, out) {
+		t.Errorf("formatting is not idempotent:\n%s", again)
+	}
+}
+
+func TestFormatPreserve_SyntaxError(t *testing.T) {
+	_, err := FormatPreserve(bad0)
+	if e, ok := err.(*SyntaxError); !ok || e.Line != 3 {
+		t.Errorf("expected a syntax error on line 3, got %v", err)
+	}
+}
+
+func TestReformat(t *testing.T) {
+	var buf bytes.Buffer
+	src := strings.NewReader("# comment\nkey=value\nsection\n    a  =  1\n    b = 2\n")
+	if err := Reformat(&buf, src, func(e *Encoder) { e.SetAlign(true) }); err != nil {
+		t.Fatal(err)
+	}
+	expect := "key = value\nsection\n    a = 1\n    b = 2\n"
+	if buf.String() != expect {
+		t.Errorf("unexpected result:\n%s", buf.String())
+	}
+	if err := Reformat(&buf, bytes.NewReader(bad0)); err == nil {
+		t.Errorf("expected a syntax error")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A LineBreakMode selects how string values containing line breaks, which a
+// ZPL value cannot contain, are represented.  An Encoder and a Decoder must
+// use the same mode for such strings to survive a round trip.
+//
+type LineBreakMode int
+
+const (
+	// LineBreakError makes the Encoder fail with an UnsupportedValueError
+	// on a string containing a line break.  This is the default.
+	LineBreakError LineBreakMode = iota
+
+	// LineBreakEscape writes such strings in double quotes with each line
+	// feed written as `\n`, carriage return as `\r` and backslash as `\\`.
+	// The Decoder reverses these escapes in every quoted value.
+	LineBreakEscape
+
+	// LineBreakSplit writes each line of such a string as a repetition of
+	// the same property.  The Decoder joins repeated values of a string
+	// field or map entry with line feeds instead of keeping only the last.
+	LineBreakSplit
+)
+
+// SetLineBreaks selects how string values containing line breaks are written.
+//
+func (e *Encoder) SetLineBreaks(mode LineBreakMode) {
+	e.lineBreaks = mode
+}
+
+// SetLineBreaks selects how string values containing line breaks were written
+// by the Encoder that produced the input.
+//
+func (d *Decoder) SetLineBreaks(mode LineBreakMode) {
+	d.lineBreaks = mode
+}
+
+var (
+	lineBreakEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+	lineBreakUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r")
+)
+
+// Write a string property, quoting, escaping or splitting it as the encoder's
+// options require.  Strings that decoding would change, such as "" or one
+// with leading spaces, are always quoted.
+func (e *Encoder) addString(name string, s string, value reflect.Value) error {
+	quote := e.quoteStrings
+	if strings.ContainsAny(s, "\r\n") {
+		switch e.lineBreaks {
+		case LineBreakEscape:
+			quote = true
+		case LineBreakSplit:
+			lines := strings.Split(s, "\n")
+			for _, line := range lines {
+				if strings.Contains(line, "\r") {
+					return &UnsupportedValueError{Value: value, Str: strconv.Quote(s)}
+				}
+			}
+			for _, line := range lines {
+				if quote || needsQuotes(line) {
+					line = `"` + line + `"`
+				}
+				if err := e.addValue(name, line); err != nil {
+					return err
+				}
+			}
+			return nil
+		default:
+			return &UnsupportedValueError{Value: value, Str: strconv.Quote(s)}
+		}
+	}
+	if quote || needsQuotes(s) {
+		if e.lineBreaks == LineBreakEscape {
+			s = lineBreakEscaper.Replace(s)
+		}
+		s = `"` + s + `"`
+	}
+	return e.addValue(name, s)
+}
+
+// Report whether s would be changed by decoding unless written in quotes.
+func needsQuotes(s string) bool {
+	n := len(s)
+	return n == 0 || isSpace(s[0]) || isSpace(s[n-1]) || n >= 2 && s[0] == '"' && s[n-1] == '"'
+}
+
+// Return value, or if it continues the lines of a string of type typ already
+// decoded into existing, those lines and value joined by a line feed.  This
+// is only for LineBreakSplit.
+func (b *builder) joinLines(id arrayID, typ reflect.Type, existing reflect.Value, value string) string {
+	if typ.Kind() != reflect.String || typ == rawValueType {
+		return value
+	}
+	n := b.filled[id]
+	b.filled[id] = n + 1
+	if n == 0 || !existing.IsValid() {
+		return value
+	}
+	return existing.String() + "\n" + value
+}
@@ -0,0 +1,54 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"testing"
+)
+
+type linesMock struct {
+	Motd  string            `zpl:"motd"`
+	Notes map[string]string `zpl:"notes"`
+}
+
+func TestLineBreakMode(t *testing.T) {
+	in := linesMock{
+		Motd:  "hello\n\n  world\\",
+		Notes: map[string]string{"a": "one\ntwo"},
+	}
+	cases := []struct {
+		Mode   LineBreakMode
+		Output string
+	}{
+		{LineBreakEscape, "motd = \"hello\\n\\n  world\\\\\"\nnotes\n    a = \"one\\ntwo\"\n"},
+		{LineBreakSplit, "motd = hello\nmotd = \"\"\nmotd = \"  world\\\"\nnotes\n    a = one\n    a = two\n"},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		e.SetLineBreaks(c.Mode)
+		if err := e.Encode(&in); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.Output {
+			t.Errorf("mode %d: unexpected result:\n%s", c.Mode, buf.String())
+		}
+		var out linesMock
+		d := NewDecoder(&buf)
+		d.SetLineBreaks(c.Mode)
+		if err := d.Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Motd != in.Motd || out.Notes["a"] != in.Notes["a"] {
+			t.Errorf("mode %d: round trip gave %q", c.Mode, out)
+		}
+	}
+	if _, err := Marshal(&in); err == nil {
+		t.Errorf("expected an error for a line break by default")
+	} else if _, ok := err.(*UnsupportedValueError); !ok {
+		t.Errorf("expected an UnsupportedValueError, got %T", err)
+	}
+}
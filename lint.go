@@ -0,0 +1,119 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+)
+
+// A Finding describes a style problem in a ZPL document, as reported by Lint.
+//
+type Finding struct {
+	Line uint64 // the finding concerns this line
+	Rule string // short name of the rule, e.g. "indent"
+	Msg  string // description of the problem
+}
+
+func (f Finding) String() string {
+	return strconv.FormatUint(f.Line, 10) + ":" + f.Msg + " (" + f.Rule + ")"
+}
+
+// Rules checked by Lint.
+const (
+	LintTab           = "tab"            // a tab character
+	LintTrailingSpace = "trailing-space" // whitespace at the end of a line
+	LintIndent        = "indent"         // indentation not a multiple of 4
+	LintDuplicate     = "duplicate"      // a repeated section header or key = value
+	LintEmptySection  = "empty-section"  // a section with nothing in it
+)
+
+// Lint checks the ZPL-encoded data for problems of style that do not prevent
+// it from being decoded, or that editors may want to point out before it is
+// decoded: tabs, trailing whitespace, indentation that is not a multiple of 4
+// spaces, a section header or an identical key = value repeated within the
+// same section, and empty sections.  The findings are sorted by line.
+//
+// Lint does not report syntax errors; use Unmarshal or Parse for that.
+//
+func Lint(src []byte) []Finding {
+	var (
+		findings []Finding
+		stack    = []*lintSection{{}}
+		lineno   uint64
+	)
+	pop := func() {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if s.children == 0 {
+			findings = append(findings, Finding{s.line, LintEmptySection, "section \"" + s.name + "\" is empty"})
+		}
+	}
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		lineno++
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if bytes.IndexByte(line, '\t') >= 0 {
+			findings = append(findings, Finding{lineno, LintTab, "line contains a tab"})
+		}
+		if n := len(line); n > 0 && (line[n-1] == ' ' || line[n-1] == '\t') {
+			findings = append(findings, Finding{lineno, LintTrailingSpace, "line ends with whitespace"})
+		}
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) == 0 || trimmed[0] == '#' {
+			continue
+		}
+		indent := len(line) - len(bytes.TrimLeft(line, " "))
+		if indent%4 != 0 {
+			findings = append(findings, Finding{lineno, LintIndent, "indentation of " + strconv.Itoa(indent) + " spaces is not a multiple of 4"})
+		}
+		// Count each tab as a level of indentation so that the rest of a
+		// tab-indented document is still checked sensibly.
+		depth := (indent + 4*bytes.Count(line[:len(line)-len(trimmed)], []byte("\t"))) / 4
+		for len(stack) > depth+1 {
+			pop()
+		}
+		parent := stack[len(stack)-1]
+		parent.children++
+		key, value := trimmed, []byte(nil)
+		if i := bytes.IndexByte(trimmed, '='); i >= 0 {
+			key, value = bytes.TrimRight(trimmed[:i], " \t"), bytes.TrimSpace(trimmed[i+1:])
+		}
+		if value == nil {
+			name := string(key)
+			if first, ok := parent.seen(name, lineno); ok {
+				findings = append(findings, Finding{lineno, LintDuplicate, "section \"" + name + "\" also appears on line " + strconv.FormatUint(first, 10)})
+			}
+			stack = append(stack, &lintSection{name: name, line: lineno})
+		} else if first, ok := parent.seen(string(key)+" = "+string(value), lineno); ok {
+			findings = append(findings, Finding{lineno, LintDuplicate, "\"" + string(key) + " = " + string(value) + "\" also appears on line " + strconv.FormatUint(first, 10)})
+		}
+	}
+	for len(stack) > 1 {
+		pop()
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Line < findings[j].Line })
+	return findings
+}
+
+// A section of a document being linted.
+type lintSection struct {
+	name     string
+	line     uint64
+	children int
+	first    map[string]uint64 // line on which each header or setting first appeared
+}
+
+// Return the line on which entry first appeared in s, if it did, or else
+// record that it first appears on line.
+func (s *lintSection) seen(entry string, line uint64) (first uint64, ok bool) {
+	if first, ok = s.first[entry]; !ok {
+		if s.first == nil {
+			s.first = make(map[string]uint64)
+		}
+		s.first[entry] = line
+	}
+	return
+}
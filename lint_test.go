@@ -0,0 +1,46 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	src := []byte("# comment \n" +
+		"version = 1\n" +
+		"main\n" +
+		"\tkey = 1\n" +
+		"   odd = 2\n" +
+		"    bind = a\n" +
+		"    bind = b\n" +
+		"    bind = a\n" +
+		"empty\n" +
+		"main\n" +
+		"    other = 1\n" +
+		"last\n")
+	var rules []string
+	var lines []uint64
+	for _, f := range Lint(src) {
+		rules = append(rules, f.Rule)
+		lines = append(lines, f.Line)
+	}
+	expectRules := []string{LintTrailingSpace, LintTab, LintIndent, LintDuplicate, LintEmptySection, LintDuplicate, LintEmptySection}
+	expectLines := []uint64{1, 4, 5, 8, 9, 10, 12}
+	if !reflect.DeepEqual(rules, expectRules) || !reflect.DeepEqual(lines, expectLines) {
+		t.Errorf("unexpected findings: %v", Lint(src))
+	}
+	if findings := Lint(raw0); len(findings) != 0 {
+		t.Errorf("unexpected findings: %v", findings)
+	}
+}
+
+func TestFinding_String(t *testing.T) {
+	f := Finding{Line: 3, Rule: LintTab, Msg: "line contains a tab"}
+	if s := f.String(); s != "3:line contains a tab (tab)" {
+		t.Errorf("unexpected string: %s", s)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// LogValue returns a slog.Value that logs v as structured attributes, so that
+// a service can log its effective configuration without dumping a multi-line
+// document:
+//
+//     slog.Info("starting", "config", zpl.LogValue(cfg))
+//
+// v is converted as by Encode, with struct fields tagged with the "mask"
+// option masked, and then logged as by Section.LogValue.  Strings containing
+// line breaks are escaped as by LineBreakEscape.  The conversion is deferred
+// until the record is handled; if it fails, the error is logged instead.
+//
+func LogValue(v interface{}) slog.Value {
+	return slog.AnyValue(logValuer{v})
+}
+
+type logValuer struct {
+	v interface{}
+}
+
+func (l logValuer) LogValue() slog.Value {
+	doc := new(Section)
+	e := NewEncoder(io.Discard)
+	e.SetLineBreaks(LineBreakEscape)
+	e.tree = []*Section{doc}
+	if err := e.Encode(l.v); err != nil {
+		return slog.AnyValue(err)
+	}
+	return doc.LogValue()
+}
+
+// LogValue implements slog.LogValuer.  Each property becomes an attribute
+// with its value, or all of its values if it is repeated, and each
+// subsection becomes a group.  The values of properties whose keys suggest
+// secrets, such as "password", "db/secret" or "apitoken", are logged as
+// "******".
+//
+func (s *Section) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(s.keys))
+	for _, key := range s.keys {
+		if values := s.values[key]; len(values) > 0 {
+			if isSecretKey(key) {
+				masked := make([]string, len(values))
+				for i := range masked {
+					masked[i] = "******"
+				}
+				values = masked
+			}
+			if len(values) == 1 {
+				attrs = append(attrs, slog.String(key, values[0]))
+			} else {
+				attrs = append(attrs, slog.Any(key, values))
+			}
+		}
+		if sub, ok := s.sections[key]; ok {
+			attrs = append(attrs, slog.Attr{Key: key, Value: sub.LogValue()})
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Words that mark a key, ignoring case, as naming a secret.
+var secretWords = []string{"password", "passwd", "secret", "token", "credential", "privatekey"}
+
+// Report whether the last name in key suggests that its values are secret.
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key[strings.LastIndex(key, "/")+1:])
+	for _, word := range secretWords {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
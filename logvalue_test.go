@@ -0,0 +1,60 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+// Log value under the key "cfg" with a text handler and return the output
+// without the time and level.
+func logText(value interface{}) string {
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	slog.New(h).Info("config", "cfg", value)
+	return buf.String()
+}
+
+func TestSection_LogValue(t *testing.T) {
+	doc, err := Parse([]byte("name = api\nbind = tcp://a\nbind = tcp://b\ndb\n    host = localhost\n    apiToken = abc\n    password = hunter2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "msg=config cfg.name=api cfg.bind=\"[tcp://a tcp://b]\" cfg.db.host=localhost cfg.db.apiToken=****** cfg.db.password=******\n"
+	if out := logText(doc); out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestLogValue(t *testing.T) {
+	token := "s3cr3t"
+	v := struct {
+		User  string            `zpl:"user"`
+		Login *maskMock         `zpl:"login"`
+		Notes string            `zpl:"notes"`
+		Extra map[string]string `zpl:"*"`
+	}{
+		User:  "admin",
+		Login: &maskMock{User: "svc", Password: "hunter2", Token: &token},
+		Notes: "a\nb",
+		Extra: map[string]string{"secretsauce": "ketchup"},
+	}
+	expected := "msg=config cfg.user=admin cfg.login.user=svc cfg.login.password=****** cfg.login.token=****** cfg.notes=\"\\\"a\\\\nb\\\"\" cfg.secretsauce=******\n"
+	if out := logText(LogValue(&v)); out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	if out := logText(LogValue(map[deviceID]int{{-1, 0}: 1})); !bytes.Contains([]byte(out), []byte("negative bus")) {
+		t.Errorf("expected an error to be logged, got:\n%s", out)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Report whether typ is one of the nullable types of package database/sql,
+// such as sql.NullString or sql.Null[T], which hold a value and a Valid flag.
+// A valid one is encoded as its value and an invalid one as a nil pointer,
+// and decoding a value into one sets Valid, so that an absent key leaves it
+// invalid.
+func isNullType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && typ.PkgPath() == "database/sql" &&
+		strings.HasPrefix(typ.Name(), "Null") && typ.NumField() == 2 &&
+		typ.Field(1).Name == "Valid" && typ.Field(1).Type.Kind() == reflect.Bool
+}
+
+// Encode a value for which isNullType is true.
+func marshalNull(e *Encoder, name string, tag tagInfo, value reflect.Value) error {
+	if value.Field(1).Bool() {
+		return marshalProperty(e, name, tag, value.Field(0))
+	}
+	return e.encodeNil(name, value.Type().Field(0).Type)
+}
+
+// Decode value into a new value of typ, for which isNullType is true.
+func (b *builder) appendNullValue(typ reflect.Type, value string) (result reflect.Value, err error) {
+	var inner reflect.Value
+	if inner, err = b.appendValue(typ.Field(0).Type, inner, value); err != nil || !inner.IsValid() {
+		return
+	}
+	result = reflect.New(typ).Elem()
+	result.Field(0).Set(inner)
+	result.Field(1).SetBool(true)
+	return
+}
@@ -0,0 +1,37 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"database/sql"
+	"testing"
+)
+
+type nullMock struct {
+	Name    sql.NullString            `zpl:"name"`
+	Port    sql.NullInt64             `zpl:"port"`
+	Debug   sql.NullBool              `zpl:"debug"`
+	Ratio   sql.NullFloat64           `zpl:"ratio"`
+	Retries sql.Null[int]             `zpl:"retries"`
+	Limits  map[string]sql.NullString `zpl:"limits"`
+}
+
+func TestNullTypes(t *testing.T) {
+	var v nullMock
+	if err := Unmarshal([]byte("name = app\ndebug = 0\nretries = 3\nlimits\n    cpu = 2\n"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if !v.Name.Valid || v.Name.String != "app" || v.Port.Valid || !v.Debug.Valid || v.Debug.Bool ||
+		v.Ratio.Valid || !v.Retries.Valid || v.Retries.V != 3 || v.Limits["cpu"].String != "2" {
+		t.Errorf("unexpected result: %+v", v)
+	}
+	out, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "name = app\ndebug = 0\nretries = 3\nlimits\n    cpu = 2\n" {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+}
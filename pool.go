@@ -0,0 +1,76 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Pools of the values that every call to Unmarshal or Decode needs and then
+// discards, so that servers decoding many small documents reuse them rather
+// than leave them for the garbage collector.
+var (
+	decoderPool = sync.Pool{New: func() interface{} { return new(Decoder) }}
+	builderPool = sync.Pool{New: func() interface{} {
+		return &builder{
+			filled: make(map[arrayID]int),
+			seen:   make(map[arrayID]bool),
+		}
+	}}
+)
+
+// Pooled values that have grown past this many entries are dropped rather
+// than kept, so that one huge document does not pin its memory forever.
+const maxPooledEntries = 1 << 10
+
+// Return a Decoder from the pool that reads from src.
+func getDecoder(src []byte) *Decoder {
+	d := decoderPool.Get().(*Decoder)
+	*d = Decoder{buffer: src, eof: true, queue: d.queue[:0]}
+	return d
+}
+
+// Return d, which must not be used again, to the pool.
+func putDecoder(d *Decoder) {
+	if cap(d.queue) > maxPooledEntries {
+		return
+	}
+	queue := d.queue[:cap(d.queue)]
+	for i := range queue {
+		queue[i] = Event{}
+	}
+	*d = Decoder{queue: queue[:0]}
+	decoderPool.Put(d)
+}
+
+// Return a builder from the pool that decodes into value.
+func getBuilder(d *Decoder, value reflect.Value) *builder {
+	b := builderPool.Get().(*builder)
+	b.dec = d
+	b.refs = append(b.refs[:0], value)
+	b.path = append(b.path[:0], d.at...)
+	return b
+}
+
+// Return b, which must not be used again, to the pool.  The builder's
+// violations belong to the caller and are not reused.
+func putBuilder(b *builder) {
+	if len(b.filled) > maxPooledEntries || len(b.seen) > maxPooledEntries {
+		return
+	}
+	for id := range b.filled {
+		delete(b.filled, id)
+	}
+	for id := range b.seen {
+		delete(b.seen, id)
+	}
+	refs := b.refs[:cap(b.refs)]
+	for i := range refs {
+		refs[i] = reflect.Value{}
+	}
+	*b = builder{refs: refs[:0], path: b.path[:0], filled: b.filled, seen: b.seen}
+	builderPool.Put(b)
+}
@@ -0,0 +1,58 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDecoder_Decode_Reuse(t *testing.T) {
+	var v snippetMock
+	for i := 0; i < 3; i++ {
+		d := NewDecoder(strings.NewReader("hwm = " + strconv.Itoa(i) + "\n"))
+		if err := d.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		if v.Hwm != i || len(d.Warnings()) != 0 {
+			t.Errorf("decode %d: hwm = %d, warnings %v", i, v.Hwm, d.Warnings())
+		}
+	}
+	var c constraintMock
+	first := Unmarshal([]byte("type = other\n"), &c)
+	if errs, ok := first.(ErrorList); !ok || len(errs) != 1 {
+		t.Fatalf("expected one violation, got %v", first)
+	}
+	if err := Unmarshal([]byte("type = zmq_queue\n"), &c); err != nil {
+		t.Errorf("violations carried over to a later call: %v", err)
+	} else if len(first.(ErrorList)) != 1 {
+		t.Errorf("earlier violations were modified: %v", first)
+	}
+}
+
+func TestUnmarshal_Concurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				var v snippetMock
+				src := "bind = tcp://host" + strconv.Itoa(g) + "\ntag = " + strconv.Itoa(i) + "\nlog\n    level = x\n"
+				if err := Unmarshal([]byte(src), &v); err != nil {
+					t.Error(err)
+					return
+				}
+				if v.Bind != "tcp://host"+strconv.Itoa(g) || len(v.Tags) != 1 || v.Tags[0] != strconv.Itoa(i) || v.Log.Level != "x" {
+					t.Errorf("goroutine %d, iteration %d: unexpected result %+v", g, i, v)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,157 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// SetProfile lets one document serve several environments.  With a profile
+// set, a top-level section whose name starts with "@" holds the settings of
+// the profile it names, e.g.:
+//
+//     bind = tcp://*:5555
+//     log = debug
+//     @production
+//         bind = tcp://eth0:5555
+//         log = warning
+//
+// The section of the selected profile is decoded after the rest of the
+// document, as if its contents were written there at the top level, so that
+// they override the base settings wherever the section appears.  A property
+// the profile sets keeps only the profile's values: those of the base are
+// dropped rather than overwritten, so a slice does not accumulate both.
+// Sections of other profiles are ignored, as are all of them if profile is
+// "".  Without a profile, "@" is not allowed in section names.
+//
+// With a profile set, the whole input is read before any of it is decoded.
+//
+func (d *Decoder) SetProfile(profile string) {
+	d.profile = &profileState{name: profile}
+}
+
+// State of a Decoder that recognizes profile sections.
+type profileState struct {
+	name     string        // the selected profile
+	in       bool          // whether the lines being read are in a profile section
+	selected bool          // whether that section is for the selected profile
+	lines    []profileLine // lines of the selected profile, without their indent, then those to return
+	read     bool          // whether the input has been read
+}
+
+type profileLine struct {
+	lineno uint64
+	text   []byte
+}
+
+// Return the next line of input, counting it.  If a profile is set, the whole
+// input is read first, and its lines are then returned with their original
+// line numbers: those outside profile sections, less the properties that the
+// selected profile sets, followed by those of the selected profile.
+func (d *Decoder) nextLine() ([]byte, error) {
+	p := d.profile
+	if p == nil {
+		line, err := d.readLine()
+		if err != nil {
+			return nil, err
+		}
+		d.lineno += 1
+		d.stats.Lines++
+		return line, nil
+	}
+	if !p.read {
+		if err := d.readProfiled(); err != nil {
+			return nil, err
+		}
+	}
+	if len(p.lines) == 0 {
+		return nil, io.EOF
+	}
+	l := p.lines[0]
+	p.lines = p.lines[1:]
+	d.lineno = l.lineno
+	return l.text, nil
+}
+
+// Read the rest of the input, setting aside the lines of profile sections, and
+// queue the lines to be returned by nextLine.
+func (d *Decoder) readProfiled() error {
+	p := d.profile
+	var base []profileLine
+	for {
+		line, err := d.readLine()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		d.lineno += 1
+		d.stats.Lines++
+		if !p.setAside(line, d.lineno) {
+			base = append(base, profileLine{d.lineno, append([]byte(nil), line...)})
+		}
+	}
+	set := make(map[string]bool)
+	var open []string
+	for _, l := range p.lines {
+		if path, ok := propertyPath(&open, l.text, d.lowerKeys); ok {
+			set[path] = true
+		}
+	}
+	lines := base[:0]
+	open = open[:0]
+	for _, l := range base {
+		if path, ok := propertyPath(&open, l.text, d.lowerKeys); !ok || !set[path] {
+			lines = append(lines, l)
+		}
+	}
+	p.lines, p.read = append(lines, p.lines...), true
+	return nil
+}
+
+// Track in *open the names of the sections enclosing line, and return the
+// path of the property it sets, if it sets one.
+func propertyPath(open *[]string, line []byte, lower bool) (string, bool) {
+	depth, key, _, hasValue, ok := scanLine(line)
+	if !ok || depth > len(*open) {
+		return "", false
+	}
+	if lower {
+		key = bytes.ToLower(key)
+	}
+	*open = append((*open)[:depth], string(key))
+	if !hasValue {
+		return "", false
+	}
+	path := strings.Join(*open, ".")
+	*open = (*open)[:depth]
+	return path, true
+}
+
+// Report whether line is the header or part of a profile section, keeping it
+// if it is part of the selected profile's section.
+func (p *profileState) setAside(line []byte, lineno uint64) bool {
+	if trimmed := bytes.TrimLeft(line, " \t"); len(trimmed) == 0 || trimmed[0] == '#' {
+		return false
+	}
+	if len(line) > 0 && line[0] == '@' {
+		depth, name, _, hasValue, ok := scanLine(line[1:])
+		if !ok || hasValue || depth != 0 {
+			return false
+		}
+		p.in, p.selected = true, string(name) == p.name
+		return true
+	}
+	if p.in && bytes.HasPrefix(line, []byte("    ")) {
+		if p.selected {
+			p.lines = append(p.lines, profileLine{lineno, append([]byte(nil), line[4:]...)})
+		}
+		return true
+	}
+	p.in = false
+	return false
+}
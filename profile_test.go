@@ -0,0 +1,99 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"testing"
+)
+
+type profileMock struct {
+	Bind  string `zpl:"bind"`
+	Log   string `zpl:"log"`
+	Cache *struct {
+		Size int `zpl:"size"`
+		TTL  int `zpl:"ttl"`
+	} `zpl:"cache"`
+}
+
+var profileSrc = []byte(`@production
+    log = warning
+    cache
+        size = 4096
+bind = tcp://*:5555
+log = debug
+cache
+    size = 16
+    ttl = 60
+@development
+    bind = tcp://localhost:5555
+`)
+
+func TestDecoder_SetProfile(t *testing.T) {
+	for _, tt := range []struct {
+		profile string
+		bind    string
+		log     string
+		size    int
+	}{
+		{"production", "tcp://*:5555", "warning", 4096},
+		{"development", "tcp://localhost:5555", "debug", 16},
+		{"", "tcp://*:5555", "debug", 16},
+	} {
+		var v profileMock
+		d := NewDecoder(bytes.NewReader(profileSrc))
+		d.SetProfile(tt.profile)
+		if err := d.Decode(&v); err != nil {
+			t.Errorf("%q: %s", tt.profile, err)
+			continue
+		}
+		if v.Bind != tt.bind || v.Log != tt.log || v.Cache.Size != tt.size || v.Cache.TTL != 60 {
+			t.Errorf("%q: unexpected result %+v", tt.profile, v)
+		}
+	}
+	if err := Unmarshal(profileSrc, new(profileMock)); err == nil {
+		t.Errorf("expected a syntax error without a profile")
+	}
+}
+
+func TestDecoder_SetProfile_Positions(t *testing.T) {
+	d := NewDecoder(bytes.NewReader(profileSrc))
+	d.SetProfile("production")
+	doc := new(Section)
+	if err := d.Decode(doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Value("log") != "warning" || doc.Section("cache").Value("size") != "4096" {
+		t.Errorf("unexpected result:\n%s", doc)
+	}
+	if line := doc.Position("bind").Line; line != 5 {
+		t.Errorf("expected bind on line 5, got %d", line)
+	}
+	if stats := d.Stats(); stats.Lines != 11 {
+		t.Errorf("expected 11 lines, got %d", stats.Lines)
+	}
+}
+
+func TestDecoder_SetProfile_Replace(t *testing.T) {
+	src := []byte("bind = tcp://*:5555\nbind = ipc://service\nlabels\n    zone = a\n    tier = web\nlog = debug\n@production\n    bind = tcp://eth0:5555\n    labels\n        zone = b\n")
+	var v struct {
+		Bind   []string          `zpl:"bind"`
+		Labels map[string]string `zpl:"labels"`
+		Log    string            `zpl:"log"`
+	}
+	d := NewDecoder(bytes.NewReader(src))
+	d.SetProfile("production")
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Bind) != 1 || v.Bind[0] != "tcp://eth0:5555" || v.Labels["zone"] != "b" || v.Labels["tier"] != "web" || v.Log != "debug" {
+		t.Errorf("unexpected result %+v", v)
+	}
+	for _, w := range d.Warnings() {
+		if w.Kind == WarnOverwritten {
+			t.Errorf("unexpected warning: %v", w)
+		}
+	}
+}
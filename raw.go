@@ -0,0 +1,106 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// RawSection is the raw text of a ZPL subsection, with the indentation of its
+// header removed so that it can be decoded on its own.  It can be used as the
+// type of a struct field or map value to delay decoding a subsection, e.g. a
+// plugin-specific block whose schema is not known until later:
+//
+//     type Config struct {
+//         Plugin   string         `zpl:"plugin"`
+//         Settings zpl.RawSection `zpl:"settings"`
+//     }
+//
+// The captured text contains the subsection's property and section lines
+// exactly as they were written, minus comments and blank lines.  When a
+// subsection appears more than once, the text of each is appended.
+//
+// When encoded, a RawSection is written as a subsection whose contents are
+// the properties and sections it contains.
+//
+type RawSection []byte
+
+var rawSectionType = reflect.TypeOf(RawSection(nil))
+
+// RawValue is the text of a ZPL value exactly as it was written, including
+// any quotes around it.  Unlike a string, a RawValue is not affected by a
+// Decoder's value transformer or secret resolvers, so tools that re-emit a
+// configuration can use it to reproduce values without normalizing them.
+//
+// A RawValue is encoded as written, without quoting.
+//
+type RawValue string
+
+var rawValueType = reflect.TypeOf(RawValue(""))
+
+// State of a builder while it copies the lines of a subsection into a
+// RawSection rather than decoding them.
+type rawCapture struct {
+	dest   reflect.Value // the RawSection being filled
+	mapv   reflect.Value // if valid, the map that dest is stored in
+	key    reflect.Value // the key of dest in mapv
+	indent int           // number of spaces to remove from each line
+	depth  int           // nesting of sections within the captured one
+}
+
+// Start copying the subsection about to be entered into dest.  If mapv is
+// valid, dest is stored in it under key after every line since the input may
+// end before the subsection does.
+func (b *builder) startRaw(dest, mapv, key reflect.Value) {
+	b.raw = &rawCapture{
+		dest:   dest,
+		mapv:   mapv,
+		key:    key,
+		indent: (len(b.path) + 1) * 4,
+	}
+	b.raw.store()
+}
+
+func (raw *rawCapture) store() {
+	if raw.mapv.IsValid() {
+		raw.mapv.SetMapIndex(raw.key, raw.dest)
+	}
+}
+
+// Copy the line behind e into the RawSection being filled, or finish the
+// capture if e ends the subsection it started in.
+func (b *builder) captureRaw(e *Event) {
+	raw := b.raw
+	switch e.Type {
+	case EndSection:
+		if raw.depth == 0 {
+			b.raw = nil
+			return
+		}
+		raw.depth--
+		return
+	case StartSection:
+		raw.depth++
+	}
+	line := b.dec.line
+	if len(line) >= raw.indent && len(bytes.TrimLeft(line[:raw.indent], " ")) == 0 {
+		line = line[raw.indent:]
+	}
+	text := raw.dest.Bytes()
+	text = append(text, line...)
+	text = append(text, '\n')
+	raw.dest.SetBytes(text)
+	raw.store()
+}
+
+// Return the text after the "=" in a key = value line, as written.
+func rawValueText(line []byte) string {
+	i := bytes.IndexByte(line, '=')
+	if i < 0 {
+		return ""
+	}
+	return string(bytes.TrimLeft(line[i+1:], " \t\n\f\r"))
+}
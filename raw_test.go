@@ -0,0 +1,121 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type rawMock struct {
+	Plugin   string                `zpl:"plugin"`
+	Settings RawSection            `zpl:"settings"`
+	Extra    map[string]RawSection `zpl:"extra"`
+}
+
+func TestRawSection_Decode(t *testing.T) {
+	src := []byte(`plugin = cache
+settings
+    # comment lines are not kept
+    size = "64 M"
+    backend
+        address = tcp://localhost:6379
+extra
+    first
+        x = 1
+    second
+        y = 2
+    first
+        x = 3
+`)
+	var v rawMock
+	if err := Unmarshal(src, &v); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	if v.Plugin != "cache" {
+		t.Errorf("plugin = %q", v.Plugin)
+	}
+	expect := "size = \"64 M\"\nbackend\n    address = tcp://localhost:6379\n"
+	if string(v.Settings) != expect {
+		t.Errorf("settings = %q", v.Settings)
+	}
+	if first := string(v.Extra["first"]); first != "x = 1\nx = 3\n" {
+		t.Errorf("extra/first = %q", first)
+	}
+	if second := string(v.Extra["second"]); second != "y = 2\n" {
+		t.Errorf("extra/second = %q", second)
+	}
+	var later struct {
+		Size    string `zpl:"size"`
+		Backend *struct {
+			Address string `zpl:"address"`
+		} `zpl:"backend"`
+	}
+	if err := Unmarshal(v.Settings, &later); err != nil {
+		t.Fatalf("failed to unmarshal settings: %s", err)
+	}
+	if later.Size != "64 M" || later.Backend.Address != "tcp://localhost:6379" {
+		t.Errorf("settings decoded to %+v", later)
+	}
+}
+
+func TestRawSection_Encode(t *testing.T) {
+	v := rawMock{
+		Plugin:   "cache",
+		Settings: RawSection("size = 64\nbackend\n    address = here\n"),
+	}
+	out, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "plugin = cache\nsettings\n    size = 64\n    backend\n        address = here\nextra\n"
+	if string(out) != expect {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+}
+
+func TestRawValue_Decode(t *testing.T) {
+	src := []byte(`name = "quoted"
+spaced = trailing spaces  
+list = "a"
+list = b
+`)
+	var v struct {
+		Name   RawValue   `zpl:"name"`
+		Spaced RawValue   `zpl:"spaced"`
+		List   []RawValue `zpl:"list"`
+	}
+	d := NewDecoder(bytes.NewReader(src))
+	d.SetValueTransformer(func(path, key, value string) (string, error) {
+		return strings.ToUpper(value), nil
+	})
+	if err := d.Decode(&v); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if v.Name != `"quoted"` {
+		t.Errorf("name = %q", v.Name)
+	}
+	if v.Spaced != "trailing spaces  " {
+		t.Errorf("spaced = %q", v.Spaced)
+	}
+	if !reflect.DeepEqual(v.List, []RawValue{`"a"`, "b"}) {
+		t.Errorf("list = %q", v.List)
+	}
+}
+
+func TestRawValue_Encode(t *testing.T) {
+	v := struct {
+		Name RawValue `zpl:"name"`
+	}{`"quoted"`}
+	out, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "name = \"quoted\"\n" {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// AddSecretResolver registers a function that resolves values beginning with
+// scheme followed by a colon.  The resolver is called with the remainder of
+// the value and its result is decoded in place of the original value.  For
+// example, after
+//
+//     d.AddSecretResolver("env", zpl.EnvResolver)
+//     d.AddSecretResolver("vault", lookupVaultSecret)
+//
+// the value "env:DB_PASS" decodes as the contents of the DB_PASS environment
+// variable and "vault:secret/db#password" is passed to lookupVaultSecret as
+// "secret/db#password".  Secrets are resolved after any value transformer.
+//
+func (d *Decoder) AddSecretResolver(scheme string, fn func(ref string) (string, error)) {
+	if d.secrets == nil {
+		d.secrets = make(map[string]func(string) (string, error))
+	}
+	d.secrets[scheme] = fn
+}
+
+// EnvResolver resolves a secret reference by looking up the environment
+// variable it names.  It returns an error if the variable is not set.
+//
+func EnvResolver(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", errors.New("zpl: environment variable " + name + " is not set")
+	}
+	return value, nil
+}
+
+// Resolve value if it begins with the scheme of a registered resolver.
+func (d *Decoder) resolveSecret(value string) (string, error) {
+	if i := strings.Index(value, ":"); i > 0 && d.secrets != nil {
+		if fn, ok := d.secrets[value[:i]]; ok {
+			return fn(value[i+1:])
+		}
+	}
+	return value, nil
+}
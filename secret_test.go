@@ -0,0 +1,41 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestDecoder_AddSecretResolver(t *testing.T) {
+	os.Setenv("ZPL_TEST_PASS", "hunter2")
+	defer os.Unsetenv("ZPL_TEST_PASS")
+	raw := []byte("pass = env:ZPL_TEST_PASS\ntoken = vault:secret/db#token\nbind = tcp://eth0:5555")
+	m := make(map[string]string)
+	d := NewDecoder(bytes.NewReader(raw))
+	d.AddSecretResolver("env", EnvResolver)
+	d.AddSecretResolver("vault", func(ref string) (string, error) {
+		return "<" + ref + ">", nil
+	})
+	if err := d.Decode(m); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	expect := map[string]string{
+		"pass":  "hunter2",
+		"token": "<secret/db#token>",
+		"bind":  "tcp://eth0:5555",
+	}
+	for k, v := range expect {
+		if m[k] != v {
+			t.Errorf("%s = %v, expected %v", k, m[k], v)
+		}
+	}
+	d = NewDecoder(bytes.NewReader([]byte("pass = env:ZPL_TEST_UNSET")))
+	d.AddSecretResolver("env", EnvResolver)
+	if err := d.Decode(m); err == nil {
+		t.Errorf("expected error for unset variable, got success.")
+	}
+}
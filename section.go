@@ -0,0 +1,203 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+)
+
+// A Section is a generic, order-preserving representation of a ZPL section
+// and everything it contains.  It is useful for tools that must handle any
+// ZPL document rather than one described by a particular Go type.
+//
+// Decoding into a *Section records every property and subsection along with
+// the position at which it first appeared.  As when decoding into maps,
+// sections that appear more than once are merged and repeated properties
+// accumulate their values.  Values are stored exactly as parsed: value
+// transformers and secret resolvers are not applied.
+//
+// The zero value is an empty section ready to use.
+//
+type Section struct {
+	keys     []string // properties and subsections, in order of appearance
+	values   map[string][]string
+	sections map[string]*Section
+	pos      map[string]Position
+}
+
+// A Position describes a location in a ZPL document.
+//
+type Position struct {
+	Filename string // name of the document, if known (see Decoder.SetFilename)
+	Line     uint64 // line number, starting at 1
+}
+
+// IsValid reports whether the position is known.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String returns the position in the form "file:line", "line N" if the
+// filename is unknown, or "-" if the position is not valid.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	} else if p.Filename == "" {
+		return "line " + strconv.FormatUint(p.Line, 10)
+	}
+	return p.Filename + ":" + strconv.FormatUint(p.Line, 10)
+}
+
+// Parse parses the ZPL-encoded data and returns it as a Section.
+//
+func Parse(src []byte) (*Section, error) {
+	s := new(Section)
+	if err := Unmarshal(src, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Keys returns the names of the properties and subsections of s in the order
+// they first appeared.
+func (s *Section) Keys() []string {
+	return s.keys
+}
+
+// Values returns all values of the named property, in order.
+func (s *Section) Values(key string) []string {
+	return s.values[key]
+}
+
+// Value returns the last value of the named property, which is the value that
+// would be stored in a non-slice field by Unmarshal, or "" if there is none.
+func (s *Section) Value(key string) string {
+	if values := s.values[key]; len(values) > 0 {
+		return values[len(values)-1]
+	}
+	return ""
+}
+
+// HasValue reports whether s has at least one value for the named property.
+func (s *Section) HasValue(key string) bool {
+	return len(s.values[key]) > 0
+}
+
+// Section returns the named subsection, or nil if there is none.
+func (s *Section) Section(name string) *Section {
+	return s.sections[name]
+}
+
+// Position returns the position at which the named property or subsection
+// first appeared, which is only valid if s was produced by a Decoder.
+func (s *Section) Position(name string) Position {
+	return s.pos[name]
+}
+
+// Add appends value to the named property.
+func (s *Section) Add(key string, value string) {
+	s.add(key, value, Position{})
+}
+
+// AddSection returns the named subsection, creating it if necessary.
+func (s *Section) AddSection(name string) *Section {
+	return s.addSection(name, Position{})
+}
+
+func (s *Section) add(key string, value string, pos Position) {
+	if s.values == nil {
+		s.values = make(map[string][]string)
+	}
+	s.touch(key, pos)
+	s.values[key] = append(s.values[key], value)
+}
+
+func (s *Section) addSection(name string, pos Position) *Section {
+	if s.sections == nil {
+		s.sections = make(map[string]*Section)
+	}
+	s.touch(name, pos)
+	sub, ok := s.sections[name]
+	if !ok {
+		sub = new(Section)
+		s.sections[name] = sub
+	}
+	return sub
+}
+
+// Record the first appearance of name.
+func (s *Section) touch(name string, pos Position) {
+	if _, ok := s.values[name]; ok {
+		return
+	} else if _, ok := s.sections[name]; ok {
+		return
+	}
+	s.keys = append(s.keys, name)
+	if pos.IsValid() {
+		if s.pos == nil {
+			s.pos = make(map[string]Position)
+		}
+		s.pos[name] = pos
+	}
+}
+
+// String returns the ZPL encoding of s.
+func (s *Section) String() string {
+	var buf bytes.Buffer
+	NewEncoder(&buf).Encode(s)
+	return buf.String()
+}
+
+var sectionType = reflect.TypeOf(Section{})
+
+// A treeBuilder consumes parse events to build a Section.
+type treeBuilder struct {
+	dec  *Decoder
+	refs []*Section
+}
+
+func newTreeBuilder(d *Decoder, s *Section) *treeBuilder {
+	return &treeBuilder{dec: d, refs: []*Section{s}}
+}
+
+func (b *treeBuilder) Consume(e *Event) error {
+	ref := b.refs[len(b.refs)-1]
+	pos := Position{Filename: b.dec.filename, Line: b.dec.lineno}
+	switch e.Type {
+	case AddValue:
+		ref.add(e.Name, e.Value, pos)
+	case EndSection:
+		b.refs = b.refs[:len(b.refs)-1]
+	case StartSection:
+		b.refs = append(b.refs, ref.addSection(e.Name, pos))
+	default:
+		panic("zpl: program error: unsupported event type??")
+	}
+	return nil
+}
+
+// Write the properties and subsections of s in their original order.
+func (e *Encoder) encodeSection(s *Section) error {
+	for _, key := range s.keys {
+		for _, value := range s.values[key] {
+			if err := e.addString(key, value, reflect.ValueOf(value)); err != nil {
+				return err
+			}
+		}
+		if sub, ok := s.sections[key]; ok {
+			err := e.startSection(key)
+			if err == nil {
+				err = e.encodeSection(sub)
+			}
+			if err2 := e.endSection(); err == nil {
+				err = err2
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
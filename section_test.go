@@ -0,0 +1,99 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSection_Decode(t *testing.T) {
+	var s Section
+	d := NewDecoder(bytes.NewReader(raw0))
+	d.SetFilename("zdcf.zpl")
+	if err := d.Decode(&s); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if keys := s.Keys(); !reflect.DeepEqual(keys, []string{"version", "context", "auxiliary", "main"}) {
+		t.Errorf("keys = %v", keys)
+	}
+	if s.Value("version") != "0.1" {
+		t.Errorf("version = %v", s.Value("version"))
+	}
+	main := s.Section("main")
+	if main == nil {
+		t.Fatalf("main not found.")
+	}
+	if pos := main.Position("type"); pos.String() != "zdcf.zpl:14" {
+		t.Errorf("main/type position = %v", pos)
+	}
+	if pos := s.Position("main"); pos.Line != 13 {
+		t.Errorf("main position = %v", pos)
+	}
+	backend := main.Section("backend")
+	if bind := backend.Values("bind"); !reflect.DeepEqual(bind, []string{"tcp://eth0:5556", "inproc://device"}) {
+		t.Errorf("main/backend/bind = %v", bind)
+	}
+	if s.Section("missing") != nil || s.Position("missing").IsValid() {
+		t.Errorf("found missing section")
+	}
+}
+
+func TestSection_RoundTrip(t *testing.T) {
+	src := "a = \"\"\nb = \"  padded \"\nc = \"\"quoted\"\"\nd = plain\n"
+	s, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.String() != src {
+		t.Errorf("unexpected result:\n%s", s.String())
+	}
+	again, err := Parse([]byte(s.String()))
+	if err != nil {
+		t.Fatalf("failed to parse %q: %s", s.String(), err)
+	}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if s.Value(key) != again.Value(key) {
+			t.Errorf("%s: %q became %q", key, s.Value(key), again.Value(key))
+		}
+	}
+	var reformatted bytes.Buffer
+	if err := Reformat(&reformatted, strings.NewReader(src)); err != nil || reformatted.String() != src {
+		t.Errorf("Reformat gave %q, %v", reformatted.String(), err)
+	}
+	transformed, err := Transform([]byte(src), func(path []string, key, value string, section bool) (string, string, bool) {
+		return key, value, true
+	})
+	if err != nil || string(transformed) != src {
+		t.Errorf("Transform gave %q, %v", transformed, err)
+	}
+	out, err := Marshal(map[string]string{"e": ""})
+	if err != nil || string(out) != "e = \"\"\n" {
+		t.Errorf("Marshal gave %q, %v", out, err)
+	}
+}
+
+func TestSection_Encode(t *testing.T) {
+	raw := []byte("b = 1\na\n    y = 2\n    x = 3\n    x = 4\nb = 5\n")
+	s, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	expect := "b = 1\nb = 5\na\n    y = 2\n    x = 3\n    x = 4\n"
+	if s.String() != expect {
+		t.Errorf("unexpected result:\n%s", s.String())
+	}
+	var built Section
+	built.AddSection("root").Add("key", "value")
+	out, err := Marshal(map[string]interface{}{"doc": &built})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "doc\n    root\n        key = value\n" {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Expand a size such as "25M" into its plain decimal form.  The suffixes K, M,
+// G and T (in either case) multiply by successive powers of 1024.
+func expandSize(value string) (string, bool) {
+	if len(value) == 0 {
+		return value, false
+	}
+	var shift uint
+	switch strings.ToUpper(value[len(value)-1:]) {
+	case "K":
+		shift = 10
+	case "M":
+		shift = 20
+	case "G":
+		shift = 30
+	case "T":
+		shift = 40
+	default:
+		return value, true
+	}
+	digits := value[:len(value)-1]
+	if strings.HasPrefix(digits, "-") {
+		n, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil || n < -(1<<(63-shift)) {
+			return value, false
+		}
+		return strconv.FormatInt(n<<shift, 10), true
+	}
+	n, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil || n > (1<<(64-shift))-1 {
+		return value, false
+	}
+	return strconv.FormatUint(n<<shift, 10), true
+}
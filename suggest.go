@@ -0,0 +1,111 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Most suggestions offered for an unknown key.
+const maxSuggestions = 3
+
+// Return the keys of struct type typ that are within a small edit distance of
+// key, which is most likely a typo of one of them, closest first.  Every name
+// a field accepts is considered, including those of fields within fields
+// tagged ",flatten", but a key close to an alias is offered as the field's
+// first name instead.
+func suggestKeys(typ reflect.Type, key string, useJSON bool) []string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	limit := len(key) / 3
+	if limit < 1 {
+		limit = 1
+	} else if limit > 3 {
+		limit = 3
+	}
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	consider := func(name, suggestion string) {
+		d := editDistance(key, name)
+		if d > limit {
+			return
+		}
+		for i := range candidates {
+			if candidates[i].name == suggestion {
+				if d < candidates[i].distance {
+					candidates[i].distance = d
+				}
+				return
+			}
+		}
+		candidates = append(candidates, candidate{suggestion, d})
+	}
+	var collect func(typ reflect.Type)
+	collect = func(typ reflect.Type) {
+		for i, tag := range fieldTags(typ, useJSON) {
+			if inner, ok := flattenedType(typ.Field(i), tag); ok {
+				collect(inner)
+				continue
+			}
+			if tag.Name == "" || tag.Name == "-" || tag.Name == "*" {
+				continue
+			}
+			for _, name := range tag.Names {
+				consider(name, name)
+			}
+			if aliases, ok := tag.option("alias"); ok {
+				for _, alias := range strings.Split(aliases, ",") {
+					consider(alias, tag.Name)
+				}
+			}
+		}
+	}
+	collect(typ)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+	var names []string
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		names = append(names, candidates[i].name)
+	}
+	return names
+}
+
+// Return the Levenshtein distance between a and b: the fewest insertions,
+// deletions and substitutions of bytes that turn a into b.
+func editDistance(a, b string) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			next := prev + cost
+			if row[j]+1 < next {
+				next = row[j] + 1
+			}
+			if row[j-1]+1 < next {
+				next = row[j-1] + 1
+			}
+			prev, row[j] = row[j], next
+		}
+	}
+	return row[len(b)]
+}
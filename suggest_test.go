@@ -0,0 +1,76 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type suggestMock struct {
+	Bind    []string `zpl:"bind"`
+	Connect []string `zpl:"connect"`
+	Type    string   `zpl:"type"`
+	Linger  int      `zpl:"linger"`
+}
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		A, B     string
+		Distance int
+	}{
+		{"", "", 0},
+		{"bind", "bind", 0},
+		{"bnid", "bind", 2},
+		{"conect", "connect", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, c := range cases {
+		if d := editDistance(c.A, c.B); d != c.Distance {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", c.A, c.B, d, c.Distance)
+		}
+	}
+}
+
+func TestUnmarshalFieldError_Suggestions(t *testing.T) {
+	var v suggestMock
+	err := Unmarshal([]byte("type = sub\nconect = tcp://localhost:5555\n"), &v)
+	fe, ok := err.(*UnmarshalFieldError)
+	if !ok {
+		t.Fatalf("expected an UnmarshalFieldError, got %v", err)
+	}
+	if len(fe.Suggestions) != 1 || fe.Suggestions[0] != "connect" {
+		t.Errorf("suggestions = %q", fe.Suggestions)
+	}
+	if !strings.HasSuffix(err.Error(), `; did you mean "connect"?`) {
+		t.Errorf("unexpected message: %s", err)
+	}
+	err = Unmarshal([]byte("compression = 1\n"), &v)
+	if fe, ok := err.(*UnmarshalFieldError); !ok || len(fe.Suggestions) != 0 {
+		t.Errorf("expected no suggestions, got %v", err)
+	}
+}
+
+type suggestNamesMock struct {
+	Address string `zpl:"addr|address"`
+	Timeout int    `zpl:"timeout,alias=expiry"`
+	Log     *struct {
+		Level string `zpl:"level"`
+	} `zpl:",flatten"`
+}
+
+func TestSuggestKeys_Names(t *testing.T) {
+	typ := reflect.TypeOf(suggestNamesMock{})
+	for key, expect := range map[string][]string{
+		"adress":  {"address"},
+		"expirey": {"timeout"},
+		"levl":    {"level"},
+	} {
+		if got := suggestKeys(typ, key, false); !reflect.DeepEqual(got, expect) {
+			t.Errorf("%s: suggestions = %q, expected %q", key, got, expect)
+		}
+	}
+}
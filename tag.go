@@ -0,0 +1,114 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// The parsed tag of a struct field, e.g. `zpl:"addr|address,format=size"`.
+type tagInfo struct {
+	Name    string            // the key name used when encoding, or "" if there is none
+	Names   []string          // every key name accepted when decoding, starting with Name
+	Options map[string]string // option values by name, "" for an option without "="
+}
+
+// Parse the tag of the struct field named field.  The tag may be either a
+// conventional `zpl:"..."` tag or, for brevity, the bare key name.  If useJSON
+// is true and there is no "zpl" tag, the "json" tag is used instead, with the
+// field's name as its key name if it gives none, as in `json:",omitempty"`.
+// The key names are separated by "|" and followed by comma-separated
+// options.  The values of an option that is repeated, as in
+// "alias=addr,alias=host", are joined by commas.
+func parseTag(tag reflect.StructTag, field string, useJSON bool) (info tagInfo) {
+	var s string
+	if strings.Contains(string(tag), ":") {
+		var ok bool
+		if s, ok = tag.Lookup("zpl"); !ok && useJSON {
+			if s, ok = tag.Lookup("json"); ok && (s == "" || s[0] == ',') {
+				s = field + s
+			}
+		}
+	} else {
+		s = string(tag)
+	}
+	parts := strings.Split(s, ",")
+	info.Names = strings.Split(parts[0], "|")
+	info.Name = info.Names[0]
+	for _, opt := range parts[1:] {
+		if info.Options == nil {
+			info.Options = make(map[string]string)
+		}
+		key, value := opt, ""
+		if i := strings.Index(opt, "="); i >= 0 {
+			key, value = opt[:i], opt[i+1:]
+		}
+		if prev, ok := info.Options[key]; ok && prev != "" {
+			value = prev + "," + value
+		}
+		info.Options[key] = value
+	}
+	return
+}
+
+// Return the value of the named option, e.g. "size" for "format" in
+// "omitempty,format=size".
+func (t tagInfo) option(key string) (value string, ok bool) {
+	value, ok = t.Options[key]
+	return
+}
+
+// Report whether name is one of the key names.
+func (t tagInfo) hasName(name string) bool {
+	for _, n := range t.Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Report whether the option key, which may be repeated, has the given value,
+// e.g. "host" for "alias" in "alias=addr,alias=host".
+func (t tagInfo) hasOptionValue(key string, value string) bool {
+	values, ok := t.Options[key]
+	if !ok {
+		return false
+	}
+	for _, v := range strings.Split(values, ",") {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// The parsed tags of the fields of struct types, by tagCacheKey, so that
+// decoding many documents into the same types does not parse and allocate
+// the same tags again each time.
+var tagCache sync.Map
+
+type tagCacheKey struct {
+	typ     reflect.Type
+	useJSON bool
+}
+
+// Return the parsed tags of the fields of the struct type typ, in order.  The
+// result is shared and must not be modified.
+func fieldTags(typ reflect.Type, useJSON bool) []tagInfo {
+	key := tagCacheKey{typ, useJSON}
+	if tags, ok := tagCache.Load(key); ok {
+		return tags.([]tagInfo)
+	}
+	tags := make([]tagInfo, typ.NumField())
+	for i := range tags {
+		f := typ.Field(i)
+		tags[i] = parseTag(f.Tag, f.Name, useJSON)
+	}
+	tagCache.Store(key, tags)
+	return tags
+}
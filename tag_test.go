@@ -0,0 +1,54 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		tag     reflect.StructTag
+		useJSON bool
+		expect  tagInfo
+	}{
+		{`version`, false, tagInfo{Name: "version", Names: []string{"version"}}},
+		{`zpl:"-"`, false, tagInfo{Name: "-", Names: []string{"-"}}},
+		{`json:"port"`, false, tagInfo{Name: "", Names: []string{""}}},
+		{`json:"port,omitempty"`, true, tagInfo{
+			Name:    "port",
+			Names:   []string{"port"},
+			Options: map[string]string{"omitempty": ""},
+		}},
+		{`json:",omitempty"`, true, tagInfo{
+			Name:    "Field",
+			Names:   []string{"Field"},
+			Options: map[string]string{"omitempty": ""},
+		}},
+		{`json:""`, true, tagInfo{Name: "Field", Names: []string{"Field"}}},
+		{`json:"-"`, true, tagInfo{Name: "-", Names: []string{"-"}}},
+		{`zpl:"addr|address|host,alias=a,alias=b,format=size" json:"x"`, true, tagInfo{
+			Name:    "addr",
+			Names:   []string{"addr", "address", "host"},
+			Options: map[string]string{"alias": "a,b", "format": "size"},
+		}},
+	}
+	for _, test := range tests {
+		if info := parseTag(test.tag, "Field", test.useJSON); !reflect.DeepEqual(info, test.expect) {
+			t.Errorf("%s: got %+v", test.tag, info)
+		}
+	}
+	info := parseTag(`zpl:"addr|host,alias=a,alias=b,min=1"`, "Field", false)
+	if !info.hasName("host") || info.hasName("a") {
+		t.Errorf("hasName failed for %+v", info)
+	}
+	if !info.hasOptionValue("alias", "b") || info.hasOptionValue("alias", "host") {
+		t.Errorf("hasOptionValue failed for %+v", info)
+	}
+	if min, ok := info.option("min"); !ok || min != "1" {
+		t.Errorf("option failed for %+v", info)
+	}
+}
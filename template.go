@@ -0,0 +1,54 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"text/template"
+)
+
+// SetTemplate causes the Decoder to run its entire input through text/template
+// before parsing it as ZPL, executing the template with data and making funcs
+// available to it.  This allows one file to generate environment-specific
+// configuration, e.g.:
+//
+//     bind = tcp://{{.Host}}:{{.Port}}
+//
+// Because a template must be complete before it can be executed, the Decoder
+// reads all remaining input the first time Decode is called.
+//
+func (d *Decoder) SetTemplate(data interface{}, funcs template.FuncMap) {
+	d.template = &templateOptions{data: data, funcs: funcs}
+}
+
+type templateOptions struct {
+	data  interface{}
+	funcs template.FuncMap
+}
+
+// Replace the decoder's input with the result of executing it as a template,
+// if a template has been configured and not yet executed.
+func (d *Decoder) expandTemplate() error {
+	if d.template == nil {
+		return nil
+	}
+	opts := d.template
+	d.template = nil
+	src, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+	t, err := template.New("zpl").Funcs(opts.funcs).Parse(string(src))
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err = t.Execute(&out, opts.data); err != nil {
+		return err
+	}
+	d.r = &out
+	return nil
+}
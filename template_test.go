@@ -0,0 +1,33 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestDecoder_SetTemplate(t *testing.T) {
+	raw := []byte("main\n    bind = tcp://{{.Host}}:5555\n    type = {{upper \"zmq_queue\"}}")
+	m := make(map[string]map[string]string)
+	d := NewDecoder(bytes.NewReader(raw))
+	d.SetTemplate(map[string]string{"Host": "eth1"}, template.FuncMap{"upper": strings.ToUpper})
+	if err := d.Decode(m); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if m["main"]["bind"] != "tcp://eth1:5555" {
+		t.Errorf("main/bind = %v", m["main"]["bind"])
+	}
+	if m["main"]["type"] != "ZMQ_QUEUE" {
+		t.Errorf("main/type = %v", m["main"]["type"])
+	}
+	d = NewDecoder(bytes.NewReader([]byte("key = {{")))
+	d.SetTemplate(nil, nil)
+	if err := d.Decode(m); err == nil {
+		t.Errorf("expected template error, got success.")
+	}
+}
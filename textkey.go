@@ -0,0 +1,62 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"encoding"
+	"reflect"
+)
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Report whether the keys of a map of type typ can be encoded: whether they
+// are strings, implement encoding.TextMarshaler, or are interfaces that may
+// hold either.
+func isEncodableMap(typ reflect.Type) bool {
+	kind := typ.Key().Kind()
+	return kind == reflect.String || kind == reflect.Interface || typ.Key().Implements(textMarshalerType)
+}
+
+// Return the ZPL name of a map key.  As in package encoding/json, keys of
+// any string type are used directly, and other keys are marshalled as text.
+// An interface key is named for the key it holds.
+func mapKeyName(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.Interface && !key.IsNil() {
+		key = key.Elem()
+		if key.Kind() != reflect.String && !key.Type().Implements(textMarshalerType) {
+			return "", &UnsupportedTypeError{Type: key.Type()}
+		}
+	}
+	if key.Kind() == reflect.String {
+		return key.String(), nil
+	}
+	if (key.Kind() == reflect.Ptr || key.Kind() == reflect.Interface) && key.IsNil() {
+		return "", &UnsupportedValueError{Value: key, Str: "nil map key"}
+	}
+	text, err := key.Interface().(encoding.TextMarshaler).MarshalText()
+	return string(text), err
+}
+
+// Report whether a map of type typ can be decoded into: whether its keys are
+// of a string type or a type whose pointer implements encoding.TextUnmarshaler.
+func isDecodableMap(typ reflect.Type) bool {
+	return typ.Key().Kind() == reflect.String || reflect.PtrTo(typ.Key()).Implements(textUnmarshalerType)
+}
+
+// Return the key of a map of type typ for the ZPL name.
+func mapKey(typ reflect.Type, name string) (reflect.Value, error) {
+	kt := typ.Key()
+	if kt.Kind() == reflect.String {
+		return reflect.ValueOf(name).Convert(kt), nil
+	}
+	key := reflect.New(kt)
+	if err := key.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(name)); err != nil {
+		return reflect.Value{}, &UnmarshalTypeError{Value: "key \"" + name + "\"", Type: kt, Err: err}
+	}
+	return key.Elem(), nil
+}
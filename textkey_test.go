@@ -0,0 +1,113 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type deviceID struct {
+	Bus, Slot int
+}
+
+func (id deviceID) MarshalText() ([]byte, error) {
+	if id.Bus < 0 {
+		return nil, errors.New("negative bus")
+	}
+	return []byte("dev" + strconv.Itoa(id.Bus) + "/" + strconv.Itoa(id.Slot)), nil
+}
+
+type deviceMock struct {
+	Model string `zpl:"model"`
+}
+
+func TestMarshal_TextMarshalerKeys(t *testing.T) {
+	v := map[deviceID]*deviceMock{
+		{1, 2}: {Model: "x1"},
+		{0, 7}: {Model: "y2"},
+	}
+	expected := `dev0/7
+    model = y2
+dev1/2
+    model = x1
+`
+	out, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != expected {
+		t.Fatalf("unexpected result:\n%s", out)
+	}
+	if _, err := Marshal(map[deviceID]int{{-1, 0}: 1}); err == nil {
+		t.Errorf("expected the error from MarshalText")
+	}
+}
+
+func (id *deviceID) UnmarshalText(text []byte) error {
+	s := string(text)
+	i := strings.IndexByte(s, '/')
+	if !strings.HasPrefix(s, "dev") || i < 0 {
+		return errors.New("malformed device id " + strconv.Quote(s))
+	}
+	var err error
+	if id.Bus, err = strconv.Atoi(s[3:i]); err != nil {
+		return err
+	}
+	id.Slot, err = strconv.Atoi(s[i+1:])
+	return err
+}
+
+type hostName string
+
+type fleetMock struct {
+	Devices map[deviceID]*deviceMock `zpl:"devices"`
+	Weights map[hostName]int         `zpl:"weights"`
+}
+
+func TestUnmarshal_CustomKeys(t *testing.T) {
+	src := `devices
+    dev1/2
+        model = x1
+    dev0/7
+        model = y2
+weights
+    alpha = 3
+    beta = 5
+`
+	var v fleetMock
+	if err := Unmarshal([]byte(src), &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Devices) != 2 || v.Devices[deviceID{1, 2}] == nil || v.Devices[deviceID{1, 2}].Model != "x1" {
+		t.Errorf("unexpected devices: %v", v.Devices)
+	}
+	if v.Weights["alpha"] != 3 || v.Weights["beta"] != 5 {
+		t.Errorf("unexpected weights: %v", v.Weights)
+	}
+	out, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back fleetMock
+	if err := Unmarshal(out, &back); err != nil {
+		t.Fatal(err)
+	} else if len(back.Devices) != 2 || back.Weights["beta"] != 5 {
+		t.Errorf("round trip gave %+v", back)
+	}
+	err = Unmarshal([]byte("devices\n    bogus\n        model = z\n"), &fleetMock{})
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Errorf("expected an UnmarshalTypeError, got %v", err)
+	}
+	ids := map[deviceID]int{}
+	if err := Unmarshal([]byte("dev3/4 = 9\n"), &ids); err != nil {
+		t.Fatal(err)
+	} else if ids[deviceID{3, 4}] != 9 {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+}
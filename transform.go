@@ -0,0 +1,79 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// Transform parses the ZPL-encoded data and returns a new document in which
+// every property and section has been passed through fn, for bulk edits such
+// as renaming keys, redacting values or dropping sections.
+//
+// For each value of each property, fn receives the names of the enclosing
+// sections as they appear in src, the key, the value and false for section,
+// and returns the key and value to write in their place, or false to leave
+// the value out.  For each section header fn receives the section's name, an
+// empty value and true for section, and returns the section's new name, or
+// false to leave out the section and everything in it.  A property's value
+// may itself be empty, as in `key = ""`, so fn must tell the two apart by
+// section rather than by the value.
+//
+// The new document is written by an Encoder: properties and sections keep
+// their order, and repeated sections are not merged, but comments are
+// dropped and indentation is normalized.
+//
+func Transform(src []byte, fn func(path []string, key, value string, section bool) (string, string, bool)) ([]byte, error) {
+	var buf bytes.Buffer
+	t := &transformer{enc: NewEncoder(&buf), fn: fn}
+	d := &Decoder{buffer: src, eof: true}
+	if err := d.run(t, true); err != nil {
+		return nil, err
+	}
+	if err := t.enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// A transformer consumes parse events and writes each through an Encoder
+// after passing it to a function.
+type transformer struct {
+	enc  *Encoder
+	fn   func(path []string, key, value string, section bool) (string, string, bool)
+	path []string // names of the enclosing sections in the input
+	skip int      // depth within a section that is being left out
+}
+
+func (t *transformer) Consume(e *Event) error {
+	if t.skip > 0 {
+		switch e.Type {
+		case StartSection:
+			t.skip++
+		case EndSection:
+			t.skip--
+		}
+		return nil
+	}
+	switch e.Type {
+	case AddValue:
+		if key, value, ok := t.fn(t.path, e.Name, e.Value, false); ok {
+			return t.enc.addString(key, value, reflect.ValueOf(value))
+		}
+	case EndSection:
+		t.path = t.path[:len(t.path)-1]
+		return t.enc.endSection()
+	case StartSection:
+		name, _, ok := t.fn(t.path, e.Name, "", true)
+		if !ok {
+			t.skip = 1
+			return nil
+		}
+		t.path = append(t.path, e.Name)
+		return t.enc.startSection(name)
+	}
+	return nil
+}
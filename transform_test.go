@@ -0,0 +1,61 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	out, err := Transform(raw0, func(path []string, key, value string, section bool) (string, string, bool) {
+		switch strings.Join(append(path, key), "/") {
+		case "auxiliary", "main/frontend/option":
+			return "", "", false
+		case "context":
+			return "threads", "", true
+		case "context/iothreads":
+			return "count", value, true
+		case "main/backend/bind":
+			return key, "<redacted>", value != "inproc://device"
+		}
+		return key, value, true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out2, err := Transform([]byte("a = \"\"\nb\n    c = 1\n"), func(path []string, key, value string, section bool) (string, string, bool) {
+		if section {
+			return "s" + key, "", true
+		}
+		return "p" + key, value, true
+	})
+	if err != nil || string(out2) != "pa = \"\"\nsb\n    pc = 1\n" {
+		t.Errorf("Transform gave %q, %v", out2, err)
+	}
+	expect := `version = 0.1
+threads
+    count = 1
+    verbose = 1
+main
+    type = zmq_queue
+    frontend
+        bind = tcp://eth0:5555
+    backend
+        bind = <redacted>
+`
+	if string(out) != expect {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+}
+
+func TestTransform_SyntaxError(t *testing.T) {
+	identity := func(path []string, key, value string, section bool) (string, string, bool) {
+		return key, value, true
+	}
+	if _, err := Transform(bad0, identity); err == nil {
+		t.Errorf("expected a syntax error")
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+// WalkFunc is the type of the function called by Walk for each value in a
+// document.  The path holds the names of the sections enclosing the property,
+// outermost first, and is only valid until the function returns.
+//
+type WalkFunc func(path []string, key, value string) error
+
+// Walk calls fn for each value of each property in doc and its subsections,
+// in the order in which they appear when doc is encoded.  If fn returns an
+// error, Walk stops and returns that error.
+//
+func Walk(doc *Section, fn WalkFunc) error {
+	return walk(doc, nil, fn)
+}
+
+func walk(s *Section, path []string, fn WalkFunc) error {
+	for _, key := range s.keys {
+		for _, value := range s.values[key] {
+			if err := fn(path, key, value); err != nil {
+				return err
+			}
+		}
+		if sub, ok := s.sections[key]; ok {
+			if err := walk(sub, append(path, key), fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
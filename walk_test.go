@@ -0,0 +1,62 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpl
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	doc, err := Parse(raw0)
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	var visited []string
+	err = Walk(doc, func(path []string, key, value string) error {
+		visited = append(visited, strings.Join(append(path, key), "/")+"="+value)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"version=0.1",
+		"context/iothreads=1",
+		"context/verbose=1",
+		"auxiliary/type=foo",
+		"main/type=zmq_queue",
+		"main/frontend/option/hwm=1000",
+		"main/frontend/option/swap=25000000",
+		"main/frontend/option/subscribe=#2",
+		"main/frontend/bind=tcp://eth0:5555",
+		"main/backend/bind=tcp://eth0:5556",
+		"main/backend/bind=inproc://device",
+	}
+	if !reflect.DeepEqual(visited, expect) {
+		t.Errorf("visited %v", visited)
+	}
+}
+
+func TestWalk_Error(t *testing.T) {
+	doc, err := Parse(raw0)
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	stop := errors.New("stop")
+	count := 0
+	err = Walk(doc, func(path []string, key, value string) error {
+		count++
+		if key == "hwm" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 6 {
+		t.Errorf("Walk returned %v after %d values", err, count)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zdcf
+
+import (
+	"sort"
+)
+
+// A Factory creates ZeroMQ sockets, typically by wrapping a ZeroMQ binding's
+// context.
+//
+type Factory interface {
+	// NewSocket returns a new socket of the given ZDCF type, e.g. "sub".
+	NewSocket(typ string) (Socket, error)
+}
+
+// A Socket is a ZeroMQ socket created by a Factory.
+//
+// SetOption is called with the ZDCF name of an option and a value whose type
+// depends on the option: uint64 for "hwm", "affinity", "sndbuf" and
+// "rcvbuf", int64 for "swap" and "rate", and string for "identity" and
+// "subscribe".  Options are set before the socket binds or connects.
+//
+type Socket interface {
+	SetOption(name string, value interface{}) error
+	Bind(endpoint string) error
+	Connect(endpoint string) error
+	Close() error
+}
+
+// A SocketError records the device and socket that could not be created.
+//
+type SocketError struct {
+	Device string // name of the device
+	Socket string // name of the socket
+	Err    error  // the error from the Factory or Socket
+}
+
+func (e *SocketError) Error() string {
+	return "zdcf: " + e.Device + "/" + e.Socket + ": " + e.Err.Error()
+}
+
+// Build creates every socket described by doc using f, sets its options and
+// binds and connects it, in the lexical order of device and socket names.  It
+// returns the sockets by device name and then socket name.  If any step
+// fails, Build closes the sockets it has created and returns a *SocketError.
+//
+func Build(doc *Document, f Factory) (map[string]map[string]Socket, error) {
+	devices := make(map[string]map[string]Socket)
+	for _, dname := range deviceNames(doc.Devices) {
+		device := doc.Devices[dname]
+		if device == nil {
+			continue
+		}
+		sockets := make(map[string]Socket)
+		devices[dname] = sockets
+		for _, sname := range socketNames(device.Sockets) {
+			spec := device.Sockets[sname]
+			if spec == nil {
+				continue
+			}
+			s, err := f.NewSocket(spec.Type)
+			if err == nil {
+				sockets[sname] = s
+				err = configure(s, spec)
+			}
+			if err != nil {
+				closeAll(devices)
+				return nil, &SocketError{Device: dname, Socket: sname, Err: err}
+			}
+		}
+	}
+	return devices, nil
+}
+
+// Set the options of s and bind and connect it as described by spec.
+func configure(s Socket, spec *SocketConfig) error {
+	for _, opt := range spec.Options.list() {
+		if err := s.SetOption(opt.name, opt.value); err != nil {
+			return err
+		}
+	}
+	for _, endpoint := range spec.Bind {
+		if err := s.Bind(endpoint); err != nil {
+			return err
+		}
+	}
+	for _, endpoint := range spec.Connect {
+		if err := s.Connect(endpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type option struct {
+	name  string
+	value interface{}
+}
+
+// Return the options that are set, in a fixed order.
+func (o *Options) list() (opts []option) {
+	if o == nil {
+		return nil
+	}
+	if o.Hwm != nil {
+		opts = append(opts, option{"hwm", *o.Hwm})
+	}
+	if o.Swap != nil {
+		opts = append(opts, option{"swap", *o.Swap})
+	}
+	if o.Affinity != nil {
+		opts = append(opts, option{"affinity", *o.Affinity})
+	}
+	if o.Identity != nil {
+		opts = append(opts, option{"identity", *o.Identity})
+	}
+	if o.Rate != nil {
+		opts = append(opts, option{"rate", *o.Rate})
+	}
+	if o.Sndbuf != nil {
+		opts = append(opts, option{"sndbuf", *o.Sndbuf})
+	}
+	if o.Rcvbuf != nil {
+		opts = append(opts, option{"rcvbuf", *o.Rcvbuf})
+	}
+	for _, s := range o.Subscribe {
+		opts = append(opts, option{"subscribe", s})
+	}
+	return
+}
+
+func closeAll(devices map[string]map[string]Socket) {
+	for _, sockets := range devices {
+		for _, s := range sockets {
+			s.Close()
+		}
+	}
+}
+
+func deviceNames(m map[string]*Device) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func socketNames(m map[string]*SocketConfig) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
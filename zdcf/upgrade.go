@@ -0,0 +1,101 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zdcf
+
+import (
+	"strings"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// Upgrade converts a ZDCF 0.x document to the ZDCF 1.0 layout, in which the
+// context and devices belong to a named application and each device's sockets
+// are grouped in a "sockets" section:
+//
+//     version = 1.0
+//     apps
+//         listener
+//             context
+//                 iothreads = 1
+//             devices
+//                 main
+//                     type = zmq_queue
+//                     sockets
+//                         frontend
+//                             type = SUB
+//                             options
+//                                 hwm = 1000
+//                             bind = tcp://eth0:5555
+//
+// Each socket's "option" section is renamed "options" and its type is written
+// in upper case.  The document is placed in the application named app.  A
+// document that is already at version 1.0 is returned unchanged; any other
+// version must start with "0.".
+//
+func Upgrade(doc *zpl.Section, app string) (*zpl.Section, error) {
+	version := doc.Value("version")
+	if version == "1.0" {
+		return doc, nil
+	} else if !strings.HasPrefix(version, "0.") {
+		return nil, &ValidationError{Path: "version", Line: doc.Position("version").Line, Msg: "cannot upgrade from version \"" + version + "\""}
+	}
+	out := new(zpl.Section)
+	out.Add("version", "1.0")
+	target := out.AddSection("apps").AddSection(app)
+	for _, key := range doc.Keys() {
+		switch key {
+		case "version":
+		case "context":
+			copySection(target.AddSection(key), doc.Section(key))
+		default:
+			device := doc.Section(key)
+			if device == nil {
+				return nil, &ValidationError{Path: key, Line: doc.Position(key).Line, Msg: "unexpected property"}
+			}
+			upgradeDevice(target.AddSection("devices").AddSection(key), device)
+		}
+	}
+	return out, nil
+}
+
+func upgradeDevice(dst, src *zpl.Section) {
+	for _, key := range src.Keys() {
+		for _, value := range src.Values(key) {
+			dst.Add(key, value)
+		}
+		if socket := src.Section(key); socket != nil {
+			upgradeSocket(dst.AddSection("sockets").AddSection(key), socket)
+		}
+	}
+}
+
+func upgradeSocket(dst, src *zpl.Section) {
+	for _, key := range src.Keys() {
+		for _, value := range src.Values(key) {
+			if key == "type" {
+				value = strings.ToUpper(value)
+			}
+			dst.Add(key, value)
+		}
+		if sub := src.Section(key); sub != nil {
+			name := key
+			if name == "option" {
+				name = "options"
+			}
+			copySection(dst.AddSection(name), sub)
+		}
+	}
+}
+
+func copySection(dst, src *zpl.Section) {
+	for _, key := range src.Keys() {
+		for _, value := range src.Values(key) {
+			dst.Add(key, value)
+		}
+		if sub := src.Section(key); sub != nil {
+			copySection(dst.AddSection(key), sub)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zdcf
+
+import (
+	"testing"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+func TestUpgrade(t *testing.T) {
+	doc, err := zpl.Parse(doc0)
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	upgraded, err := Upgrade(doc, "listener")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `version = 1.0
+apps
+    listener
+        context
+            iothreads = 1
+        devices
+            main
+                type = zmq_queue
+                sockets
+                    frontend
+                        type = SUB
+                        options
+                            hwm = 1000
+                            swap = 25M
+                            subscribe = #2
+                            subscribe = #3
+                        bind = tcp://eth0:5555
+                    backend
+                        type = XPUB
+                        bind = tcp://eth0:5556
+                        connect = inproc://device
+`
+	if upgraded.String() != expect {
+		t.Errorf("unexpected result:\n%s", upgraded)
+	}
+	if err := Validate(upgraded); err != nil {
+		t.Errorf("upgraded document is invalid: %s", err)
+	}
+	if again, err := Upgrade(upgraded, "other"); err != nil || again != upgraded {
+		t.Errorf("upgrading version 1.0 changed it: %v", err)
+	}
+	if err := Validate(doc); err != nil {
+		t.Errorf("original document is invalid: %s", err)
+	}
+}
+
+func TestUpgrade_Errors(t *testing.T) {
+	for _, src := range []string{"version = 2.0\n", "version = 0.1\noops = 1\n"} {
+		doc, err := zpl.Parse([]byte(src))
+		if err != nil {
+			t.Fatalf("failed to parse: %s", err)
+		}
+		if _, err := Upgrade(doc, "app"); err == nil {
+			t.Errorf("expected an error upgrading %q", src)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zdcf
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// A ValidationError describes a problem found by Validate.
+//
+type ValidationError struct {
+	Path string // "/"-separated path of the offending key
+	Line uint64 // line on which the key first appeared, if known
+	Msg  string // description of the problem
+}
+
+func (e *ValidationError) Error() string {
+	if e.Line == 0 {
+		return "zdcf: " + e.Path + ": " + e.Msg
+	}
+	return "zdcf: " + e.Path + " (line " + strconv.FormatUint(e.Line, 10) + "): " + e.Msg
+}
+
+// Versions of ZDCF that Validate accepts.
+var Versions = []string{"0.1", "1.0"}
+
+// Device types defined by ZDCF.
+var DeviceTypes = []string{"zmq_queue", "zmq_forwarder", "zmq_streamer"}
+
+// Socket types accepted by Validate.
+var SocketTypes = []string{
+	"pair", "pub", "sub", "req", "rep", "dealer", "router",
+	"pull", "push", "xpub", "xsub", "xreq", "xrep",
+}
+
+// Validate checks a parsed ZDCF document more strictly than Parse does: the
+// version must be known, every device and socket must have a known type, and
+// every key must be one that ZDCF defines, with a value in range, so that a
+// typo such as "iothread" is reported rather than ignored or taken for the
+// name of a device.  Documents at version 1.0 must use the layout produced by
+// Upgrade; earlier versions the layout read by Parse.  It returns nil if doc
+// is valid, or else a zpl.ErrorList of *ValidationError values in document
+// order.
+//
+func Validate(doc *zpl.Section) error {
+	var v validator
+	v.root(doc)
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+type validator struct {
+	errs zpl.ErrorList
+	v1   bool // whether the document uses the ZDCF 1.0 layout
+}
+
+func (v *validator) fail(s *zpl.Section, path []string, key string, msg string) {
+	v.errs = append(v.errs, &ValidationError{
+		Path: strings.Join(append(path[:len(path):len(path)], key), "/"),
+		Line: s.Position(key).Line,
+		Msg:  msg,
+	})
+}
+
+// Report key in s if it is a property or a section when it should not be.
+func (v *validator) expect(s *zpl.Section, path []string, key string, property, section bool) bool {
+	if s.HasValue(key) && !property {
+		v.fail(s, path, key, "unexpected property")
+		return false
+	} else if s.Section(key) != nil && !section {
+		v.fail(s, path, key, "unexpected section")
+		return false
+	}
+	return true
+}
+
+func (v *validator) root(doc *zpl.Section) {
+	if !doc.HasValue("version") {
+		v.errs = append(v.errs, &ValidationError{Path: "version", Msg: "missing"})
+	}
+	v.v1 = doc.Value("version") == "1.0"
+	for _, key := range doc.Keys() {
+		switch key {
+		case "version":
+			if v.expect(doc, nil, key, true, false) && !contains(Versions, doc.Value(key)) {
+				v.fail(doc, nil, key, "unknown version \""+doc.Value(key)+"\"")
+			}
+		case "context":
+			if !v.v1 && v.expect(doc, nil, key, false, true) {
+				v.context(doc.Section(key), []string{key})
+			} else if v.v1 {
+				v.fail(doc, nil, key, "context belongs in an application in version 1.0")
+			}
+		case "apps":
+			if v.v1 && v.expect(doc, nil, key, false, true) {
+				v.apps(doc.Section(key), []string{key})
+			} else if !v.v1 {
+				v.fail(doc, nil, key, "applications require version 1.0")
+			}
+		default:
+			if v.v1 {
+				v.fail(doc, nil, key, "unknown setting")
+			} else if v.expect(doc, nil, key, false, true) {
+				v.device(doc.Section(key), []string{key})
+			}
+		}
+	}
+}
+
+func (v *validator) apps(s *zpl.Section, path []string) {
+	for _, name := range s.Keys() {
+		if !v.expect(s, path, name, false, true) {
+			continue
+		}
+		app, path := s.Section(name), append(path, name)
+		for _, key := range app.Keys() {
+			if !v.expect(app, path, key, false, true) {
+				continue
+			}
+			switch key {
+			case "context":
+				v.context(app.Section(key), append(path, key))
+			case "devices":
+				devices, path := app.Section(key), append(path, key)
+				for _, device := range devices.Keys() {
+					if v.expect(devices, path, device, false, true) {
+						v.device(devices.Section(device), append(path, device))
+					}
+				}
+			default:
+				v.fail(app, path, key, "unknown application setting")
+			}
+		}
+	}
+}
+
+func (v *validator) context(s *zpl.Section, path []string) {
+	for _, key := range s.Keys() {
+		if !v.expect(s, path, key, true, false) {
+			continue
+		}
+		value := s.Value(key)
+		switch key {
+		case "iothreads":
+			if n, err := strconv.Atoi(value); err != nil || n < 0 {
+				v.fail(s, path, key, "\""+value+"\" is not a non-negative integer")
+			}
+		case "verbose":
+			if value != "0" && value != "1" {
+				v.fail(s, path, key, "\""+value+"\" is not 0 or 1")
+			}
+		default:
+			v.fail(s, path, key, "unknown context setting")
+		}
+	}
+}
+
+func (v *validator) device(s *zpl.Section, path []string) {
+	if !s.HasValue("type") {
+		v.errs = append(v.errs, &ValidationError{Path: strings.Join(path, "/") + "/type", Msg: "missing"})
+	}
+	for _, key := range s.Keys() {
+		if key == "type" {
+			if v.expect(s, path, key, true, false) && !contains(DeviceTypes, s.Value(key)) {
+				v.fail(s, path, key, "unknown device type \""+s.Value(key)+"\"")
+			}
+		} else if !v.expect(s, path, key, false, true) {
+			continue
+		} else if !v.v1 {
+			v.socket(s.Section(key), append(path, key))
+		} else if key != "sockets" {
+			v.fail(s, path, key, "unknown device setting")
+		} else {
+			sockets, path := s.Section(key), append(path, key)
+			for _, socket := range sockets.Keys() {
+				if v.expect(sockets, path, socket, false, true) {
+					v.socket(sockets.Section(socket), append(path, socket))
+				}
+			}
+		}
+	}
+}
+
+func (v *validator) socket(s *zpl.Section, path []string) {
+	if !s.HasValue("type") {
+		v.errs = append(v.errs, &ValidationError{Path: strings.Join(path, "/") + "/type", Msg: "missing"})
+	}
+	options := "option"
+	if v.v1 {
+		options = "options"
+	}
+	for _, key := range s.Keys() {
+		switch key {
+		case "type":
+			if v.expect(s, path, key, true, false) && !contains(SocketTypes, strings.ToLower(s.Value(key))) {
+				v.fail(s, path, key, "unknown socket type \""+s.Value(key)+"\"")
+			}
+		case "bind", "connect":
+			v.expect(s, path, key, true, false)
+		case options:
+			if v.expect(s, path, key, false, true) {
+				v.options(s.Section(key), append(path, key))
+			}
+		default:
+			v.fail(s, path, key, "unknown socket setting")
+		}
+	}
+}
+
+func (v *validator) options(s *zpl.Section, path []string) {
+	for _, key := range s.Keys() {
+		if !v.expect(s, path, key, true, false) {
+			continue
+		}
+		for _, value := range s.Values(key) {
+			// Decoding the value into Options checks its syntax and range.
+			var o Options
+			if err := zpl.Unmarshal([]byte(key+" = "+value), &o); err != nil {
+				if _, ok := err.(*zpl.UnmarshalFieldError); ok {
+					v.fail(s, path, key, "unknown socket option")
+					break
+				}
+				v.fail(s, path, key, "invalid value \""+value+"\"")
+			} else if o.Rate != nil && *o.Rate <= 0 {
+				v.fail(s, path, key, "rate must be positive")
+			} else if o.Identity != nil && len(*o.Identity) > 255 {
+				v.fail(s, path, key, "identity is longer than 255 bytes")
+			}
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
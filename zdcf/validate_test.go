@@ -0,0 +1,66 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zdcf
+
+import (
+	"testing"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+func TestValidate(t *testing.T) {
+	doc, err := zpl.Parse(doc0)
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	if err := Validate(doc); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestValidate_Errors(t *testing.T) {
+	doc, err := zpl.Parse([]byte(`version = 0.3
+context
+    iothread = 1
+main
+    type = zmq_queue
+    frontend
+        type = subscriber
+        option
+            hwm = lots
+            hwn = 10
+        bnd = tcp://eth0:5555
+oops = 1
+`))
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	err = Validate(doc)
+	errs, ok := err.(zpl.ErrorList)
+	if !ok {
+		t.Fatalf("expected zpl.ErrorList, got %T: %v", err, err)
+	}
+	expect := []string{
+		"zdcf: version (line 1): unknown version \"0.3\"",
+		"zdcf: context/iothread (line 3): unknown context setting",
+		"zdcf: main/frontend/type (line 7): unknown socket type \"subscriber\"",
+		"zdcf: main/frontend/option/hwm (line 9): invalid value \"lots\"",
+		"zdcf: main/frontend/option/hwn (line 10): unknown socket option",
+		"zdcf: main/frontend/bnd (line 11): unknown socket setting",
+		"zdcf: oops (line 12): unexpected property",
+	}
+	if len(errs) != len(expect) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expect), len(errs), errs)
+	}
+	for i, e := range errs {
+		if e.Error() != expect[i] {
+			t.Errorf("expected %s, got %s", expect[i], e)
+		}
+	}
+	missing, _ := zpl.Parse([]byte("main\n    front\n        bind = x\n"))
+	if errs, ok := Validate(missing).(zpl.ErrorList); !ok || len(errs) != 3 {
+		t.Errorf("expected 3 errors for missing keys, got %v", errs)
+	}
+}
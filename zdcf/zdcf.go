@@ -0,0 +1,96 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zdcf reads ZeroMQ Device Configuration Files (ZDCF) and creates the
+// sockets they describe.  ZDCF is defined here: http://rfc.zeromq.org/spec:5.
+//
+// A ZDCF document names a set of devices, each of which has a type and a set
+// of sockets:
+//
+//     version = 0.1
+//     context
+//         iothreads = 1
+//     main
+//         type = zmq_queue
+//         frontend
+//             type = sub
+//             option
+//                 hwm = 1000
+//                 subscribe = "#2"
+//             bind = tcp://eth0:5555
+//
+// This package does not import a ZeroMQ binding: sockets are created through
+// the Factory interface, which is easily implemented for any of them.
+//
+package zdcf
+
+import (
+	"log/slog"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// A Document is a parsed ZDCF document.
+//
+type Document struct {
+	Version string             `zpl:"version"`
+	Context *Context           `zpl:"context"`
+	Devices map[string]*Device `zpl:"*"`
+}
+
+// Context holds the settings of the ZeroMQ context.
+//
+type Context struct {
+	IoThreads int  `zpl:"iothreads"`
+	Verbose   bool `zpl:"verbose"`
+}
+
+// A Device has a type, e.g. "zmq_queue", and named sockets.
+//
+type Device struct {
+	Type    string                   `zpl:"type"`
+	Sockets map[string]*SocketConfig `zpl:"*"`
+}
+
+// A SocketConfig describes a socket: its type, e.g. "sub", its options, and
+// the endpoints it binds and connects to.
+//
+type SocketConfig struct {
+	Type    string   `zpl:"type"`
+	Options *Options `zpl:"option"`
+	Bind    []string `zpl:"bind"`
+	Connect []string `zpl:"connect"`
+}
+
+// Options holds the socket options defined by ZDCF.  A nil pointer means the
+// option is not set.
+//
+type Options struct {
+	Hwm       *uint64  `zpl:"hwm"`
+	Swap      *int64   `zpl:"swap,format=size"`
+	Affinity  *uint64  `zpl:"affinity"`
+	Identity  *string  `zpl:"identity"`
+	Subscribe []string `zpl:"subscribe"`
+	Rate      *int64   `zpl:"rate"`
+	Sndbuf    *uint64  `zpl:"sndbuf,format=size"`
+	Rcvbuf    *uint64  `zpl:"rcvbuf,format=size"`
+}
+
+// Parse parses ZDCF-encoded data in the 0.x layout, where devices appear at
+// the top level.  See Upgrade for the 1.0 layout.
+//
+func Parse(src []byte) (*Document, error) {
+	doc := new(Document)
+	if err := zpl.Unmarshal(src, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// LogValue implements slog.LogValuer, logging the document's settings as
+// nested groups as zpl.LogValue does.
+//
+func (d *Document) LogValue() slog.Value {
+	return zpl.LogValue(d).Resolve()
+}
@@ -0,0 +1,139 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zdcf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var doc0 = []byte(`version = 0.1
+context
+    iothreads = 1
+main
+    type = zmq_queue
+    frontend
+        type = sub
+        option
+            hwm = 1000
+            swap = 25M
+            subscribe = "#2"
+            subscribe = "#3"
+        bind = tcp://eth0:5555
+    backend
+        type = xpub
+        bind = tcp://eth0:5556
+        connect = inproc://device
+`)
+
+type mockFactory struct {
+	calls []string
+	fail  string
+}
+
+func (f *mockFactory) NewSocket(typ string) (Socket, error) {
+	f.calls = append(f.calls, "new "+typ)
+	return &mockSocket{f}, nil
+}
+
+type mockSocket struct{ f *mockFactory }
+
+func (s *mockSocket) record(call string) error {
+	s.f.calls = append(s.f.calls, call)
+	if call == s.f.fail {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func (s *mockSocket) SetOption(name string, value interface{}) error {
+	return s.record(fmt.Sprintf("set %s %T %v", name, value, value))
+}
+
+func (s *mockSocket) Bind(endpoint string) error    { return s.record("bind " + endpoint) }
+func (s *mockSocket) Connect(endpoint string) error { return s.record("connect " + endpoint) }
+func (s *mockSocket) Close() error                  { return s.record("close") }
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(doc0)
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	if doc.Version != "0.1" || doc.Context.IoThreads != 1 {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+	frontend := doc.Devices["main"].Sockets["frontend"]
+	if frontend == nil || *frontend.Options.Swap != 25<<20 {
+		t.Errorf("unexpected frontend: %+v", frontend)
+	}
+}
+
+func TestDocument_LogValue(t *testing.T) {
+	doc, err := Parse(doc0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("zdcf", "doc", doc)
+	for _, attr := range []string{
+		`"version":"0.1"`,
+		`"context":{"iothreads":"1"`,
+		`"option":{"hwm":"1000","swap":"26214400","subscribe":["#2","#3"]`,
+		`"connect":"inproc://device"`,
+	} {
+		if !strings.Contains(buf.String(), attr) {
+			t.Errorf("expected %s in %s", attr, buf.String())
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	doc, err := Parse(doc0)
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	f := new(mockFactory)
+	devices, err := Build(doc, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices["main"]) != 2 {
+		t.Errorf("unexpected sockets: %v", devices)
+	}
+	expect := []string{
+		"new xpub",
+		"bind tcp://eth0:5556",
+		"connect inproc://device",
+		"new sub",
+		"set hwm uint64 1000",
+		"set swap int64 26214400",
+		"set subscribe string #2",
+		"set subscribe string #3",
+		"bind tcp://eth0:5555",
+	}
+	if !reflect.DeepEqual(f.calls, expect) {
+		t.Errorf("unexpected calls:\n%s", strings.Join(f.calls, "\n"))
+	}
+}
+
+func TestBuild_Error(t *testing.T) {
+	doc, err := Parse(doc0)
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	f := &mockFactory{fail: "bind tcp://eth0:5555"}
+	devices, err := Build(doc, f)
+	if devices != nil || err == nil || err.Error() != "zdcf: main/frontend: failed" {
+		t.Fatalf("unexpected result: %v, %v", devices, err)
+	}
+	if n := len(f.calls); f.calls[n-1] != "close" || f.calls[n-2] != "close" {
+		t.Errorf("sockets were not closed:\n%s", strings.Join(f.calls, "\n"))
+	}
+}
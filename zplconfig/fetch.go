@@ -0,0 +1,119 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zplconfig provides helpers for services that load their
+// configuration from ZPL, such as fetching it from a central HTTP endpoint.
+//
+package zplconfig
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// A Fetcher fetches a ZPL document over HTTP(S) and decodes it.  It remembers
+// the document's ETag and Last-Modified headers so that later fetches are
+// conditional, and falls back to the last document it fetched, or to a local
+// cache file, when the endpoint cannot be reached.
+//
+// A Fetcher must not be used by more than one goroutine at a time.
+//
+type Fetcher struct {
+	URL       string       // the document to fetch
+	Client    *http.Client // the client to use, or nil for http.DefaultClient
+	CacheFile string       // if not empty, a local copy of the last document fetched
+
+	body         []byte
+	etag         string
+	lastModified string
+}
+
+// Metadata describes the result of a call to Fetch.
+//
+type Metadata struct {
+	ETag         string    // the ETag of the document, if any
+	LastModified string    // the Last-Modified time of the document, if any
+	Fetched      time.Time // when the document was fetched or found unmodified
+	NotModified  bool      // whether the endpoint reported no change
+	FromCache    bool      // whether the document came from the cache after an error
+	Err          error     // the error that caused a fallback to the cache, if any
+}
+
+// Fetch fetches the document and stores it in the value pointed to by v, as
+// zpl.Unmarshal does.  If the endpoint reports that the document has not
+// changed since the last fetch, the previous document is decoded again.
+//
+// If the request fails or the endpoint responds with an unexpected status, Fetch
+// decodes the last document it fetched or, if there is none, the cache file,
+// and reports the failure in Metadata.Err rather than returning it.  Fetch
+// returns an error only if there is no document to decode, or if decoding
+// fails.
+//
+func (f *Fetcher) Fetch(v interface{}) (Metadata, error) {
+	meta, err := f.fetch()
+	if err != nil {
+		meta = Metadata{FromCache: true, Err: err}
+		if f.body == nil && f.CacheFile != "" {
+			if f.body, err = ioutil.ReadFile(f.CacheFile); err != nil {
+				f.body = nil
+			}
+		}
+		if f.body == nil {
+			return meta, meta.Err
+		}
+	}
+	return meta, zpl.Unmarshal(f.body, v)
+}
+
+// Fetch the document, updating f unless there is an error.
+func (f *Fetcher) fetch() (meta Metadata, err error) {
+	req, err := http.NewRequest("GET", f.URL, nil)
+	if err != nil {
+		return
+	}
+	if f.body != nil {
+		if f.etag != "" {
+			req.Header.Set("If-None-Match", f.etag)
+		}
+		if f.lastModified != "" {
+			req.Header.Set("If-Modified-Since", f.lastModified)
+		}
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && f.body != nil:
+		meta.NotModified = true
+	case resp.StatusCode == http.StatusOK:
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return meta, err
+		}
+		f.body = body
+		f.etag = resp.Header.Get("ETag")
+		f.lastModified = resp.Header.Get("Last-Modified")
+		if f.CacheFile != "" {
+			// The cache is only a fallback, so failing to write it is not
+			// worth failing the fetch.
+			ioutil.WriteFile(f.CacheFile, body, 0644)
+		}
+	default:
+		return meta, errors.New("zplconfig: " + f.URL + ": unexpected status " + strconv.Itoa(resp.StatusCode))
+	}
+	meta.ETag, meta.LastModified = f.etag, f.lastModified
+	meta.Fetched = time.Now()
+	return
+}
@@ -0,0 +1,67 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplconfig
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+type fetchMock struct {
+	Name string `zpl:"name"`
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	body, down, requests := "name = first\n", false, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if down {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		etag := `"` + strconv.Itoa(len(body)) + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	cache := filepath.Join(t.TempDir(), "config.zpl")
+	f := &Fetcher{URL: server.URL, CacheFile: cache}
+
+	var v fetchMock
+	meta, err := f.Fetch(&v)
+	if err != nil || v.Name != "first" || meta.NotModified || meta.ETag != `"13"` {
+		t.Fatalf("first fetch: %+v, %+v, %v", v, meta, err)
+	}
+	v = fetchMock{}
+	if meta, err = f.Fetch(&v); err != nil || v.Name != "first" || !meta.NotModified {
+		t.Errorf("second fetch: %+v, %+v, %v", v, meta, err)
+	}
+	body = "name = second\n"
+	if meta, err = f.Fetch(&v); err != nil || v.Name != "second" || meta.NotModified {
+		t.Errorf("third fetch: %+v, %+v, %v", v, meta, err)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+
+	down = true
+	cold := &Fetcher{URL: server.URL, CacheFile: cache}
+	v = fetchMock{}
+	if meta, err = cold.Fetch(&v); err != nil || v.Name != "second" || !meta.FromCache || meta.Err == nil {
+		t.Errorf("fetch from cache: %+v, %+v, %v", v, meta, err)
+	}
+	os.Remove(cache)
+	if _, err = (&Fetcher{URL: server.URL, CacheFile: cache}).Fetch(&v); err == nil {
+		t.Errorf("expected an error with no cache")
+	}
+}
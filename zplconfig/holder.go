@@ -0,0 +1,129 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplconfig
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// A Holder holds the configuration decoded from a ZPL file and replaces it
+// atomically when the file changes, so that a long-running service can read
+// its current configuration from any goroutine without locking:
+//
+//     h, err := zplconfig.NewHolder[Config]("/etc/service.zpl", nil)
+//     ...
+//     go h.Watch(ctx, 5*time.Second, func(err error) { log.Print(err) })
+//     ...
+//     cfg := h.Load()
+//
+// A new configuration replaces the current one only if it decodes without
+// error and passes the validation function, if any.
+//
+type Holder[T any] struct {
+	path     string
+	validate func(*T) error
+	value    atomic.Pointer[T]
+
+	mu      sync.Mutex // serializes reloads
+	modTime time.Time  // modification time of the file last loaded
+	size    int64      // size of the file last loaded
+}
+
+// NewHolder returns a Holder for the ZPL file at path, which must decode into
+// a T that validate accepts.  If validate is nil, every T is accepted.
+//
+func NewHolder[T any](path string, validate func(*T) error) (*Holder[T], error) {
+	h := &Holder[T]{path: path, validate: validate}
+	if err := h.Reload(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Load returns the current configuration.  The caller must not modify it.
+//
+func (h *Holder[T]) Load() *T {
+	return h.value.Load()
+}
+
+// Reload reads and decodes the file again and, if the result is valid,
+// replaces the current configuration.  Otherwise the current configuration is
+// kept and the error is returned.
+//
+func (h *Holder[T]) Reload() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return err
+	}
+	return h.reload(info)
+}
+
+// Decode the file and store the result if it is valid.  The file is read
+// rather than mapped into memory, as zpl.DecodeFile would, since it may be
+// truncated or rewritten while it is being read.
+func (h *Holder[T]) reload(info os.FileInfo) error {
+	src, err := os.ReadFile(h.path)
+	if err != nil {
+		return err
+	}
+	v := new(T)
+	if err := zpl.Unmarshal(src, v); err != nil {
+		return err
+	}
+	if h.validate != nil {
+		if err := h.validate(v); err != nil {
+			return err
+		}
+	}
+	h.value.Store(v)
+	h.modTime, h.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// Watch checks the file every interval until ctx is done, reloading it when
+// its modification time or size changes.  Errors from reloading are passed to
+// onError, if it is not nil, and the file is tried again once it changes
+// again.
+//
+func (h *Holder[T]) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := h.check(); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// Reload the file if it has changed since it was last loaded or tried.
+func (h *Holder[T]) check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(h.modTime) && info.Size() == h.size {
+		return nil
+	}
+	if err = h.reload(info); err != nil {
+		// Do not report the same bad file again on every tick.
+		h.modTime, h.size = info.ModTime(), info.Size()
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplconfig
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type holderMock struct {
+	Workers int `zpl:"workers"`
+}
+
+func validWorkers(v *holderMock) error {
+	if v.Workers < 1 {
+		return errors.New("workers must be positive")
+	}
+	return nil
+}
+
+func TestHolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.zpl")
+	write := func(src string, age time.Duration) {
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		when := time.Now().Add(-age)
+		os.Chtimes(path, when, when)
+	}
+	write("workers = 4\n", time.Hour)
+	h, err := NewHolder[holderMock](path, validWorkers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Load().Workers != 4 {
+		t.Fatalf("workers = %d", h.Load().Workers)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 10)
+	go h.Watch(ctx, time.Millisecond, func(err error) { errs <- err })
+
+	write("workers = 0\n", 30*time.Minute)
+	select {
+	case err := <-errs:
+		if err.Error() != "workers must be positive" {
+			t.Errorf("unexpected error: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("invalid configuration was not reported")
+	}
+	if h.Load().Workers != 4 {
+		t.Errorf("invalid configuration replaced the current one")
+	}
+
+	write("workers = 8\n", 0)
+	deadline := time.Now().Add(5 * time.Second)
+	for h.Load().Workers != 8 {
+		if time.Now().After(deadline) {
+			t.Fatal("configuration was not reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNewHolder_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.zpl")
+	os.WriteFile(path, []byte("workers = 0\n"), 0644)
+	if _, err := NewHolder[holderMock](path, validWorkers); err == nil {
+		t.Errorf("expected an error")
+	}
+}
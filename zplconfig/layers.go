@@ -0,0 +1,107 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplconfig
+
+import (
+	"io/fs"
+	"os"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// Layers describes a configuration assembled from compiled-in defaults, a
+// site file and the environment, each laid over the one before so that it
+// overrides the values it sets:
+//
+//     //go:embed defaults.zpl
+//     var defaults embed.FS
+//     ...
+//     layers := zplconfig.Layers{
+//         Defaults:  defaults,
+//         Pattern:   "defaults.zpl",
+//         File:      "/etc/service.zpl",
+//         EnvPrefix: "SERVICE",
+//     }
+//     var cfg Config
+//     err := layers.Load(&cfg)
+//
+// A property set by a later layer replaces every value it had in the earlier
+// ones, so that a slice holds the values of one layer only, while sections
+// found in several layers are merged.  Within the defaults, documents are
+// merged as by zpl.UnmarshalFS.
+//
+type Layers struct {
+	Defaults  fs.FS  // if not nil, holds the default documents
+	Pattern   string // the default documents in Defaults, as for zpl.UnmarshalFS
+	File      string // if not empty, a site file decoded over the defaults, if it exists
+	EnvPrefix string // if not empty, variables read as by zpl.FromEnv are laid over the rest
+}
+
+// Load decodes the layers into the value pointed to by v.  A site file that
+// does not exist is skipped, but any other error stops Load and is returned.
+//
+func (l *Layers) Load(v interface{}) error {
+	doc := new(zpl.Section)
+	if l.Defaults != nil {
+		if err := zpl.UnmarshalFS(l.Defaults, l.Pattern, doc); err != nil {
+			return err
+		}
+	}
+	if l.File != "" {
+		// The site file is read rather than mapped into memory, as by
+		// zpl.DecodeFile, since it may be rewritten while it is read.
+		src, err := os.ReadFile(l.File)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		} else if err == nil {
+			site, err := zpl.Parse(src)
+			if err != nil {
+				return err
+			}
+			doc = overlay(doc, site)
+		}
+	}
+	if l.EnvPrefix != "" {
+		env, err := zpl.FromEnv(os.Environ(), l.EnvPrefix)
+		if err != nil {
+			return err
+		}
+		doc = overlay(doc, env)
+	}
+	return zpl.Unmarshal([]byte(doc.String()), v)
+}
+
+// Return the contents of base with those of over laid on top: the values that
+// over gives a property replace all of its values in base, and subsections
+// found in both are overlaid in the same way.
+func overlay(base, over *zpl.Section) *zpl.Section {
+	out := new(zpl.Section)
+	for _, key := range base.Keys() {
+		switch baseSub, overSub := base.Section(key), over.Section(key); {
+		case over.HasValue(key) || overSub != nil && baseSub == nil:
+			put(out, over, key)
+		case overSub != nil:
+			*out.AddSection(key) = *overlay(baseSub, overSub)
+		default:
+			put(out, base, key)
+		}
+	}
+	for _, key := range over.Keys() {
+		if !base.HasValue(key) && base.Section(key) == nil {
+			put(out, over, key)
+		}
+	}
+	return out
+}
+
+// Copy the values and subsection named key from src to dst.
+func put(dst, src *zpl.Section, key string) {
+	if sub := src.Section(key); sub != nil {
+		*dst.AddSection(key) = *sub
+	}
+	for _, value := range src.Values(key) {
+		dst.Add(key, value)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+type layersMock struct {
+	Bind    string `zpl:"bind"`
+	Workers int    `zpl:"workers"`
+	Log     *struct {
+		Level string `zpl:"level"`
+	} `zpl:"log"`
+}
+
+func TestLayers(t *testing.T) {
+	defaults := fstest.MapFS{
+		"defaults.zpl": {Data: []byte("bind = tcp://*:5555\nworkers = 4\nlog\n    level = info\n")},
+	}
+	path := filepath.Join(t.TempDir(), "site.zpl")
+	if err := os.WriteFile(path, []byte("workers = 16\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LAYERSTEST_LOG_LEVEL", "debug")
+	layers := Layers{Defaults: defaults, Pattern: "defaults.zpl", File: path, EnvPrefix: "LAYERSTEST"}
+	var v layersMock
+	if err := layers.Load(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Bind != "tcp://*:5555" || v.Workers != 16 || v.Log == nil || v.Log.Level != "debug" {
+		t.Errorf("unexpected result: %+v", v)
+	}
+	layers.File = filepath.Join(t.TempDir(), "missing.zpl")
+	layers.EnvPrefix = ""
+	v = layersMock{}
+	if err := layers.Load(&v); err != nil {
+		t.Fatal(err)
+	} else if v.Workers != 4 || v.Log.Level != "info" {
+		t.Errorf("unexpected result without a site file: %+v", v)
+	}
+	if err := os.WriteFile(path, []byte("workers = many\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	layers.File = path
+	if err := layers.Load(new(layersMock)); err == nil {
+		t.Errorf("expected an error from an invalid site file")
+	}
+}
+
+func TestLayers_Replace(t *testing.T) {
+	defaults := fstest.MapFS{
+		"defaults.zpl": {Data: []byte("name = broker\nbind = tcp://*:5555\nbind = ipc://broker\nlog\n    level = info\n    file = broker.log\n")},
+	}
+	path := filepath.Join(t.TempDir(), "site.zpl")
+	if err := os.WriteFile(path, []byte("bind = tcp://*:6000\nlog\n    level = warn\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Name string   `zpl:"name"`
+		Bind []string `zpl:"bind"`
+		Log  *struct {
+			Level string `zpl:"level"`
+			File  string `zpl:"file"`
+		} `zpl:"log"`
+	}
+	layers := Layers{Defaults: defaults, Pattern: "defaults.zpl", File: path}
+	if err := layers.Load(&v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Bind) != 1 || v.Bind[0] != "tcp://*:6000" || v.Log == nil || v.Log.Level != "warn" || v.Log.File != "broker.log" {
+		t.Errorf("unexpected result with a site file: %+v", v)
+	}
+	t.Setenv("LAYERSREPLACE_NAME", "")
+	t.Setenv("LAYERSREPLACE_BIND", " inproc://broker ")
+	layers.EnvPrefix = "LAYERSREPLACE"
+	v.Bind = nil
+	if err := layers.Load(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "" || len(v.Bind) != 1 || v.Bind[0] != " inproc://broker " || v.Log.Level != "warn" {
+		t.Errorf("unexpected result with the environment: %+v", v)
+	}
+}
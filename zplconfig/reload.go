@@ -0,0 +1,85 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplconfig
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// A Reloader re-reads and re-decodes a ZPL file each time the process
+// receives a signal, which is the classic way to reconfigure a daemon:
+//
+//     r := &zplconfig.Reloader[Config]{
+//         Path: "/etc/service.zpl",
+//         OnReload: func(cfg *Config, changes []zpl.Change) { ... },
+//         OnError: func(err error) { log.Print(err) },
+//     }
+//     go r.Run(ctx)
+//
+type Reloader[T any] struct {
+	Path     string                           // the file to read
+	Signals  []os.Signal                      // the signals to reload on, or nil for SIGHUP
+	OnReload func(v *T, changes []zpl.Change) // called with each new configuration
+	OnError  func(err error)                  // called when a reload fails, if not nil
+}
+
+// Run reads the file once, then waits for signals until ctx is done.  On each
+// signal it reads the file again, decodes it into a new T and passes that to
+// OnReload along with the properties that changed since the last successful
+// read (see zpl.Diff).  If reading or decoding fails, the error is passed to
+// OnError and the next reload is compared with the last good file.
+//
+// Run returns an error only if the first read fails, and nil once ctx is
+// done.
+//
+func (r *Reloader[T]) Run(ctx context.Context) error {
+	doc, _, err := r.read()
+	if err != nil {
+		return err
+	}
+	signals := r.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ch:
+		}
+		next, src, err := r.read()
+		if err == nil {
+			v := new(T)
+			if err = zpl.Unmarshal(src, v); err == nil {
+				changes := zpl.Diff(doc, next)
+				doc = next
+				r.OnReload(v, changes)
+				continue
+			}
+		}
+		if r.OnError != nil {
+			r.OnError(err)
+		}
+	}
+}
+
+// Read and parse the file.
+func (r *Reloader[T]) read() (*zpl.Section, []byte, error) {
+	src, err := ioutil.ReadFile(r.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc, err := zpl.Parse(src)
+	return doc, src, err
+}
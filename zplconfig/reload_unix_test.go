@@ -0,0 +1,87 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package zplconfig
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+func TestReloader_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.zpl")
+	os.WriteFile(path, []byte("workers = 4\n"), 0644)
+	type reload struct {
+		v       *holderMock
+		changes []zpl.Change
+	}
+	reloads := make(chan reload, 1)
+	errs := make(chan error, 1)
+	r := &Reloader[holderMock]{
+		Path:     path,
+		Signals:  []os.Signal{syscall.SIGUSR1},
+		OnReload: func(v *holderMock, changes []zpl.Change) { reloads <- reload{v, changes} },
+		OnError:  func(err error) { errs <- err },
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	// Catch the signal here too so that it cannot terminate the test before
+	// Run has registered for it.
+	caught := make(chan os.Signal, 1)
+	signal.Notify(caught, syscall.SIGUSR1)
+	defer signal.Stop(caught)
+	send := func() { syscall.Kill(os.Getpid(), syscall.SIGUSR1) }
+
+	// Once a first reload has happened, Run has read the original file.
+	ready := false
+	for i := 0; i < 500 && !ready; i++ {
+		send()
+		select {
+		case rl := <-reloads:
+			if rl.v.Workers != 4 || len(rl.changes) != 0 {
+				t.Errorf("unexpected reload: %+v, %+v", rl.v, rl.changes)
+			}
+			ready = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if !ready {
+		t.Fatal("signal was not handled")
+	}
+	// Discard reloads caused by signals still pending.
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-reloads:
+	default:
+	}
+
+	os.WriteFile(path, []byte("workers = 8\n"), 0644)
+	send()
+	rl := <-reloads
+	if rl.v.Workers != 8 || len(rl.changes) != 1 || rl.changes[0].Path != "workers" {
+		t.Errorf("unexpected reload: %+v, %+v", rl.v, rl.changes)
+	}
+
+	os.WriteFile(path, []byte("workers = many\n"), 0644)
+	send()
+	if err := <-errs; err == nil {
+		t.Errorf("expected an error")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run returned %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplconfig
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// A Value shares a configuration of type T among goroutines.  Get may be
+// called from any goroutine without locking, and functions registered with
+// Subscribe are told of each new configuration:
+//
+//     var cfg zplconfig.Value[Config]
+//     cfg.Subscribe(func(c Config) { pool.Resize(c.Workers) })
+//     if err := cfg.DecodeFiles("/etc/service/defaults.zpl", "/etc/service.zpl"); err != nil {
+//         ...
+//     }
+//
+// The zero value holds the zero T and has no subscribers.
+//
+type Value[T any] struct {
+	current atomic.Pointer[T]
+
+	mu     sync.Mutex // serializes Set so subscribers see every value in order
+	subs   map[int]func(T)
+	nextID int
+}
+
+// Get returns the current configuration.
+//
+func (v *Value[T]) Get() T {
+	if p := v.current.Load(); p != nil {
+		return *p
+	}
+	var zero T
+	return zero
+}
+
+// Set replaces the current configuration with x and then calls each
+// subscriber with it, in the order they subscribed.  Concurrent calls to Set
+// are serialized, so subscribers see every configuration in the same order.
+//
+func (v *Value[T]) Set(x T) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.current.Store(&x)
+	ids := make([]int, 0, len(v.subs))
+	for id := range v.subs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		v.subs[id](x)
+	}
+}
+
+// Subscribe registers fn to be called with each configuration passed to Set,
+// and returns a function that cancels the subscription.  A subscriber must
+// not call Set or Subscribe itself.
+//
+func (v *Value[T]) Subscribe(fn func(T)) (cancel func()) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.subs == nil {
+		v.subs = make(map[int]func(T))
+	}
+	id := v.nextID
+	v.nextID++
+	v.subs[id] = fn
+	return func() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		delete(v.subs, id)
+	}
+}
+
+// Decode lays each of the ZPL documents in srcs over the ones before it, as
+// Layers does, decodes the result into a new T, and then sets that as the
+// current configuration.  A property set by a later document replaces every
+// value it had in the earlier ones, while sections found in several
+// documents are merged.  If any document fails to decode, the current
+// configuration is kept and the error is returned.
+//
+func (v *Value[T]) Decode(srcs ...[]byte) error {
+	doc := new(zpl.Section)
+	for _, src := range srcs {
+		next, err := zpl.Parse(src)
+		if err != nil {
+			return err
+		}
+		doc = overlay(doc, next)
+	}
+	x := new(T)
+	if err := zpl.Unmarshal([]byte(doc.String()), x); err != nil {
+		return err
+	}
+	v.Set(*x)
+	return nil
+}
+
+// DecodeFiles is like Decode but reads the documents from the named files.
+// The files are read rather than mapped into memory, as by zpl.DecodeFile,
+// since configuration files may be rewritten while they are being read.
+//
+func (v *Value[T]) DecodeFiles(paths ...string) error {
+	srcs := make([][]byte, len(paths))
+	for i, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		srcs[i] = src
+	}
+	return v.Decode(srcs...)
+}
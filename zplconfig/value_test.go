@@ -0,0 +1,92 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type valueConfig struct {
+	Workers int    `zpl:"workers"`
+	Mode    string `zpl:"mode"`
+}
+
+func TestValue_Decode_Override(t *testing.T) {
+	var v Value[struct {
+		Bind []string `zpl:"bind"`
+		Log  *struct {
+			Level string `zpl:"level"`
+			File  string `zpl:"file"`
+		} `zpl:"log"`
+	}]
+	if err := v.Decode(
+		[]byte("bind = a\nbind = b\nlog\n    level = info\n    file = x.log\n"),
+		[]byte("bind = c\nlog\n    level = debug\n"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	got := v.Get()
+	if !reflect.DeepEqual(got.Bind, []string{"c"}) || got.Log == nil || got.Log.Level != "debug" || got.Log.File != "x.log" {
+		t.Errorf("unexpected result %+v", got)
+	}
+	path := filepath.Join(t.TempDir(), "site.zpl")
+	if err := os.WriteFile(path, []byte("bind = d\nbind = e\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.DecodeFiles(path); err != nil {
+		t.Fatal(err)
+	} else if got := v.Get(); !reflect.DeepEqual(got.Bind, []string{"d", "e"}) {
+		t.Errorf("unexpected result from a file %+v", got)
+	}
+}
+
+func TestValue(t *testing.T) {
+	var v Value[valueConfig]
+	if got := v.Get(); got != (valueConfig{}) {
+		t.Errorf("zero Value holds %+v", got)
+	}
+	var seen []int
+	cancel := v.Subscribe(func(c valueConfig) { seen = append(seen, c.Workers) })
+	if err := v.Decode([]byte("workers = 2\nmode = fast\n"), []byte("workers = 4\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := v.Get(); got != (valueConfig{Workers: 4, Mode: "fast"}) {
+		t.Errorf("Get returned %+v", got)
+	}
+	if err := v.Decode([]byte("workers = many\n")); err == nil {
+		t.Errorf("expected an error for an invalid document")
+	}
+	if v.Get().Workers != 4 {
+		t.Errorf("invalid document replaced the configuration")
+	}
+	cancel()
+	v.Set(valueConfig{Workers: 8})
+	if !reflect.DeepEqual(seen, []int{4}) {
+		t.Errorf("subscriber saw %v", seen)
+	}
+}
+
+func TestValue_Concurrent(t *testing.T) {
+	var (
+		v  Value[valueConfig]
+		wg sync.WaitGroup
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			v.Set(valueConfig{Workers: i})
+		}(i)
+		go func() {
+			defer wg.Done()
+			v.Get()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,215 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zplgen generates Go struct definitions, with zpl tags, that a
+// representative ZPL document can be decoded into, as a starting point for
+// modeling a configuration by hand.
+//
+// Each section becomes a struct type and each property a field.  A property
+// whose values are all integers becomes an int, one whose values are all
+// numbers a float64, one whose values are all "true" or "false" a bool, and
+// any other a string.  A property that is repeated within a section becomes
+// a slice.  A section that contains only subsections, at least two of which
+// have a key in common, is taken to be a collection of similar items, as the
+// devices of a ZDCF document are, and becomes a map of pointers to a struct
+// type that describes all of them.
+//
+package zplgen
+
+import (
+	"bytes"
+	"go/format"
+	"strconv"
+	"strings"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// Options control the code generated by Generate.
+//
+type Options struct {
+	Package string // package of the generated file, "main" if empty
+	Type    string // name of the type of the whole document, "Config" if empty
+}
+
+// Generate returns the formatted source of a Go file declaring the types that
+// doc can be decoded into.  Nested types are named after the type and field
+// that contain them, e.g. ConfigFrontend for the "frontend" section of a
+// Config.
+//
+func Generate(doc *zpl.Section, opts Options) ([]byte, error) {
+	if opts.Package == "" {
+		opts.Package = "main"
+	}
+	if opts.Type == "" {
+		opts.Type = "Config"
+	}
+	g := &generator{names: make(map[string]bool)}
+	g.buf.WriteString("// Code generated by zplgen. DO NOT EDIT.\n\npackage " + opts.Package + "\n")
+	g.queue = append(g.queue, pending{g.typeName(opts.Type), []*zpl.Section{doc}})
+	for len(g.queue) > 0 {
+		next := g.queue[0]
+		g.queue = g.queue[1:]
+		g.structType(next.name, next.docs)
+	}
+	return format.Source(g.buf.Bytes())
+}
+
+type generator struct {
+	buf   bytes.Buffer
+	names map[string]bool // type names already used
+	queue []pending       // struct types not yet written
+}
+
+// A struct type to be written, and the sections it must describe.
+type pending struct {
+	name string
+	docs []*zpl.Section
+}
+
+// Write a struct type with a field for every key of docs.
+func (g *generator) structType(name string, docs []*zpl.Section) {
+	g.buf.WriteString("\ntype " + name + " struct {\n")
+	fields := make(map[string]bool)
+	for _, key := range keysOf(docs) {
+		field := uniqueName(exportedName(key), fields)
+		var subs []*zpl.Section
+		var values [][]string
+		for _, doc := range docs {
+			if sub := doc.Section(key); sub != nil {
+				subs = append(subs, sub)
+			} else if doc.HasValue(key) {
+				values = append(values, doc.Values(key))
+			}
+		}
+		var typ string
+		if len(subs) > 0 {
+			elem := g.typeName(name + field)
+			if items := itemsOf(subs); items != nil {
+				typ = "map[string]*" + elem
+				g.queue = append(g.queue, pending{elem, items})
+			} else {
+				typ = "*" + elem
+				g.queue = append(g.queue, pending{elem, subs})
+			}
+		} else {
+			typ = valueType(values)
+		}
+		g.buf.WriteString(field + " " + typ + " `zpl:" + strconv.Quote(key) + "`\n")
+	}
+	g.buf.WriteString("}\n")
+}
+
+// Return an unused type name based on name.
+func (g *generator) typeName(name string) string {
+	return uniqueName(name, g.names)
+}
+
+// Return name, or name followed by the smallest number from 2 that makes it
+// unique among used, and record it as used.
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// Return the keys of docs in the order they first appear.
+func keysOf(docs []*zpl.Section) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, doc := range docs {
+		for _, key := range doc.Keys() {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// Return the subsections of secs if they look like a collection of similar
+// items rather than distinct settings: if secs have no properties and at
+// least two subsections, each of which shares a key with the first.
+// Otherwise return nil.
+func itemsOf(secs []*zpl.Section) []*zpl.Section {
+	var items []*zpl.Section
+	for _, sec := range secs {
+		for _, key := range sec.Keys() {
+			if sec.HasValue(key) {
+				return nil
+			}
+			items = append(items, sec.Section(key))
+		}
+	}
+	if len(items) < 2 {
+		return nil
+	}
+	first := make(map[string]bool)
+	for _, key := range items[0].Keys() {
+		first[key] = true
+	}
+	for _, item := range items[1:] {
+		shared := false
+		for _, key := range item.Keys() {
+			shared = shared || first[key]
+		}
+		if !shared {
+			return nil
+		}
+	}
+	return items
+}
+
+// Return the Go type of a property with the given values in each section
+// where it appears.
+func valueType(values [][]string) string {
+	ints, floats, bools, repeated := true, true, true, false
+	for _, vs := range values {
+		repeated = repeated || len(vs) > 1
+		for _, v := range vs {
+			if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+				ints = false
+			}
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				floats = false
+			}
+			if v != "true" && v != "false" {
+				bools = false
+			}
+		}
+	}
+	typ := "string"
+	switch {
+	case ints:
+		typ = "int"
+	case floats:
+		typ = "float64"
+	case bools:
+		typ = "bool"
+	}
+	if repeated {
+		typ = "[]" + typ
+	}
+	return typ
+}
+
+// Return an exported Go identifier for a ZPL key, e.g. "IoThreads" for
+// "io/threads".
+func exportedName(key string) string {
+	var name strings.Builder
+	for _, part := range strings.Split(key, "/") {
+		if part != "" {
+			name.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	s := name.String()
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		s = "X" + s
+	}
+	return s
+}
@@ -0,0 +1,92 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplgen
+
+import (
+	"testing"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+var sample = []byte(`version = 1.5
+debug = false
+context
+    iothreads = 1
+main
+    type = zmq_queue
+    frontend
+        bind = tcp://eth0:5555
+        bind = inproc://device
+        hwm = 1000
+    backend
+        bind = tcp://eth0:5556
+devices
+    queue
+        type = zmq_queue
+    forwarder
+        type = zmq_forwarder
+        hwm = 1e3
+`)
+
+func TestGenerate(t *testing.T) {
+	doc, err := zpl.Parse(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Generate(doc, Options{Package: "zdcf", Type: "Root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "// Code generated by zplgen. DO NOT EDIT.\n" + `
+package zdcf
+
+type Root struct {
+	Version float64                 ` + "`zpl:\"version\"`" + `
+	Debug   bool                    ` + "`zpl:\"debug\"`" + `
+	Context *RootContext            ` + "`zpl:\"context\"`" + `
+	Main    *RootMain               ` + "`zpl:\"main\"`" + `
+	Devices map[string]*RootDevices ` + "`zpl:\"devices\"`" + `
+}
+
+type RootContext struct {
+	Iothreads int ` + "`zpl:\"iothreads\"`" + `
+}
+
+type RootMain struct {
+	Type     string            ` + "`zpl:\"type\"`" + `
+	Frontend *RootMainFrontend ` + "`zpl:\"frontend\"`" + `
+	Backend  *RootMainBackend  ` + "`zpl:\"backend\"`" + `
+}
+
+type RootDevices struct {
+	Type string  ` + "`zpl:\"type\"`" + `
+	Hwm  float64 ` + "`zpl:\"hwm\"`" + `
+}
+
+type RootMainFrontend struct {
+	Bind []string ` + "`zpl:\"bind\"`" + `
+	Hwm  int      ` + "`zpl:\"hwm\"`" + `
+}
+
+type RootMainBackend struct {
+	Bind string ` + "`zpl:\"bind\"`" + `
+}
+`
+	if string(out) != expected {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+}
+
+func TestExportedName(t *testing.T) {
+	for key, expected := range map[string]string{
+		"bind":       "Bind",
+		"io/threads": "IoThreads",
+		"2fa":        "X2fa",
+	} {
+		if name := exportedName(key); name != expected {
+			t.Errorf("exportedName(%q) = %q, expected %q", key, name, expected)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package example holds types with methods generated by zplgen -methods, to
+// test that they behave as the reflection-based encoder and decoder do.
+//
+package example
+
+//go:generate go run ../../../cmd/zplgen -methods example.go
+
+// Root is a ZDCF document.
+//
+//zplgen:methods
+type Root struct {
+	Version float32            `zpl:"version"`
+	Context *Context           `zpl:"context"`
+	Devices map[string]*Device `zpl:"devices"`
+	Secret  string             `zpl:"-"`
+}
+
+//zplgen:methods
+type Context struct {
+	IoThreads int  `zpl:"iothreads"`
+	Verbose   bool `zpl:"verbose"`
+}
+
+//zplgen:methods
+type Device struct {
+	Type    string             `zpl:"type"`
+	Sockets map[string]*Socket `zpl:"sockets"`
+}
+
+//zplgen:methods
+type Socket struct {
+	Type    string   `zpl:"type"`
+	Hwm     uint16   `zpl:"hwm"`
+	Swap    []int64  `zpl:"swap"`
+	Rate    float64  `zpl:"rate"`
+	Bind    []string `zpl:"bind"`
+	Connect []string `zpl:"connect"`
+}
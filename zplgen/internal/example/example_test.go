@@ -0,0 +1,80 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package example
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+var raw = []byte(`version = 1.5
+context
+    iothreads = 2
+    verbose = 1
+devices
+    main
+        type = zmq_queue
+        sockets
+            frontend
+                type = SUB
+                hwm = 1000
+                swap = 25000000
+                swap = -1
+                rate = 0.25
+                bind = tcp://eth0:5555
+            backend
+                bind = tcp://eth0:5556
+                bind = inproc://device
+    aux
+        type = zmq_forwarder
+`)
+
+func TestMethods(t *testing.T) {
+	var generated, reflected Root
+	if err := generated.UnmarshalZPL(raw); err != nil {
+		t.Fatal(err)
+	}
+	if err := zpl.NewDecoder(bytes.NewReader(raw)).Decode(&reflected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(generated, reflected) {
+		t.Errorf("UnmarshalZPL gave %+v, expected %+v", generated, reflected)
+	}
+	out, err := generated.MarshalZPL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := zpl.NewEncoder(&buf).Encode(&reflected); err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != buf.String() {
+		t.Errorf("MarshalZPL gave:\n%s\nexpected:\n%s", out, buf.String())
+	}
+	if viaMarshal, err := zpl.Marshal(&generated); err != nil || !bytes.Equal(viaMarshal, out) {
+		t.Errorf("zpl.Marshal did not use MarshalZPL")
+	}
+}
+
+func TestMethods_Errors(t *testing.T) {
+	for _, src := range []string{
+		"other = 1\n",
+		"context\n    iothreads = many\n",
+		"devices\n    main\n        sockets\n            s\n                hwm = 70000\n",
+		"devices\n    main = 1\n",
+		"version\n",
+	} {
+		if err := zpl.Unmarshal([]byte(src), new(Root)); err == nil {
+			t.Errorf("expected an error for %q", src)
+		}
+	}
+	bad := Root{Devices: map[string]*Device{"main": {Type: "a\nb"}}}
+	if _, err := bad.MarshalZPL(); err == nil {
+		t.Errorf("expected an error for a line break")
+	}
+}
@@ -0,0 +1,373 @@
+// Code generated by zplgen -methods. DO NOT EDIT.
+
+package example
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// MarshalZPL returns the ZPL encoding of x, as zpl.Marshal does.
+func (x *Root) MarshalZPL() ([]byte, error) {
+	var buf bytes.Buffer
+	err := x.marshalZPL(&buf, "")
+	return buf.Bytes(), err
+}
+
+func (x *Root) marshalZPL(buf *bytes.Buffer, indent string) error {
+	{
+		v := x.Version
+		if math.IsInf(float64(v), 0) || math.IsNaN(float64(v)) {
+			return errors.New("zpl: value of \"version\" is not finite")
+		}
+		buf.WriteString(indent + "version = " + strconv.FormatFloat(float64(v), 'f', -1, 32) + "\n")
+	}
+	if x.Context != nil {
+		buf.WriteString(indent + "context\n")
+		if err := x.Context.marshalZPL(buf, indent+"    "); err != nil {
+			return err
+		}
+	}
+	buf.WriteString(indent + "devices\n")
+	{
+		keys := make([]string, 0, len(x.Devices))
+		for k := range x.Devices {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if v := x.Devices[k]; v != nil {
+				buf.WriteString(indent + "    " + k + "\n")
+				if err := v.marshalZPL(buf, indent+"        "); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// UnmarshalZPL decodes the ZPL document src into x, as zpl.Unmarshal does.
+func (x *Root) UnmarshalZPL(src []byte) error {
+	return x.unmarshalZPL(zpl.NewDecoder(bytes.NewReader(src)))
+}
+
+func (x *Root) unmarshalZPL(d *zpl.Decoder) error {
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case e.Type == zpl.EndSection:
+			return nil
+		case e.Type == zpl.AddValue && e.Name == "version":
+			v, err := strconv.ParseFloat(e.Value, 32)
+			if err != nil {
+				return errors.New("zpl: cannot unmarshal \"" + e.Value + "\" into float32 for key \"version\": " + err.Error())
+			}
+			x.Version = float32(v)
+		case e.Type == zpl.StartSection && e.Name == "context":
+			if x.Context == nil {
+				x.Context = new(Context)
+			}
+			if err := x.Context.unmarshalZPL(d); err != nil {
+				return err
+			}
+		case e.Type == zpl.StartSection && e.Name == "devices":
+			if x.Devices == nil {
+				x.Devices = make(map[string]*Device)
+			}
+			if err := unmarshalZPLMapOfDevice(d, x.Devices); err != nil {
+				return err
+			}
+		default:
+			return errors.New("zpl: unknown key \"" + e.Name + "\" in Root")
+		}
+	}
+}
+
+func unmarshalZPLMapOfDevice(d *zpl.Decoder, m map[string]*Device) error {
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch e.Type {
+		case zpl.EndSection:
+			return nil
+		case zpl.StartSection:
+			v := m[e.Name]
+			if v == nil {
+				v = new(Device)
+				m[e.Name] = v
+			}
+			if err := v.unmarshalZPL(d); err != nil {
+				return err
+			}
+		default:
+			return errors.New("zpl: cannot unmarshal value for key \"" + e.Name + "\" into Device")
+		}
+	}
+}
+
+// MarshalZPL returns the ZPL encoding of x, as zpl.Marshal does.
+func (x *Context) MarshalZPL() ([]byte, error) {
+	var buf bytes.Buffer
+	err := x.marshalZPL(&buf, "")
+	return buf.Bytes(), err
+}
+
+func (x *Context) marshalZPL(buf *bytes.Buffer, indent string) error {
+	{
+		v := x.IoThreads
+		buf.WriteString(indent + "iothreads = " + strconv.FormatInt(int64(v), 10) + "\n")
+	}
+	{
+		v := x.Verbose
+		s := "0"
+		if v {
+			s = "1"
+		}
+		buf.WriteString(indent + "verbose = " + s + "\n")
+	}
+	return nil
+}
+
+// UnmarshalZPL decodes the ZPL document src into x, as zpl.Unmarshal does.
+func (x *Context) UnmarshalZPL(src []byte) error {
+	return x.unmarshalZPL(zpl.NewDecoder(bytes.NewReader(src)))
+}
+
+func (x *Context) unmarshalZPL(d *zpl.Decoder) error {
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case e.Type == zpl.EndSection:
+			return nil
+		case e.Type == zpl.AddValue && e.Name == "iothreads":
+			v, err := strconv.ParseInt(e.Value, 10, 0)
+			if err != nil {
+				return errors.New("zpl: cannot unmarshal \"" + e.Value + "\" into int for key \"iothreads\": " + err.Error())
+			}
+			x.IoThreads = int(v)
+		case e.Type == zpl.AddValue && e.Name == "verbose":
+			v, err := strconv.ParseBool(e.Value)
+			if err != nil {
+				return errors.New("zpl: cannot unmarshal \"" + e.Value + "\" into bool for key \"verbose\": " + err.Error())
+			}
+			x.Verbose = v
+		default:
+			return errors.New("zpl: unknown key \"" + e.Name + "\" in Context")
+		}
+	}
+}
+
+// MarshalZPL returns the ZPL encoding of x, as zpl.Marshal does.
+func (x *Device) MarshalZPL() ([]byte, error) {
+	var buf bytes.Buffer
+	err := x.marshalZPL(&buf, "")
+	return buf.Bytes(), err
+}
+
+func (x *Device) marshalZPL(buf *bytes.Buffer, indent string) error {
+	{
+		v := x.Type
+		if strings.ContainsAny(v, "\r\n") {
+			return errors.New("zpl: value of \"type\" contains a line break")
+		}
+		if n := len(v); n == 0 || strings.Trim(v, " \t\f") != v || n >= 2 && v[0] == '"' && v[n-1] == '"' {
+			v = "\"" + v + "\""
+		}
+		buf.WriteString(indent + "type = " + v + "\n")
+	}
+	buf.WriteString(indent + "sockets\n")
+	{
+		keys := make([]string, 0, len(x.Sockets))
+		for k := range x.Sockets {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if v := x.Sockets[k]; v != nil {
+				buf.WriteString(indent + "    " + k + "\n")
+				if err := v.marshalZPL(buf, indent+"        "); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// UnmarshalZPL decodes the ZPL document src into x, as zpl.Unmarshal does.
+func (x *Device) UnmarshalZPL(src []byte) error {
+	return x.unmarshalZPL(zpl.NewDecoder(bytes.NewReader(src)))
+}
+
+func (x *Device) unmarshalZPL(d *zpl.Decoder) error {
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case e.Type == zpl.EndSection:
+			return nil
+		case e.Type == zpl.AddValue && e.Name == "type":
+			x.Type = e.Value
+		case e.Type == zpl.StartSection && e.Name == "sockets":
+			if x.Sockets == nil {
+				x.Sockets = make(map[string]*Socket)
+			}
+			if err := unmarshalZPLMapOfSocket(d, x.Sockets); err != nil {
+				return err
+			}
+		default:
+			return errors.New("zpl: unknown key \"" + e.Name + "\" in Device")
+		}
+	}
+}
+
+func unmarshalZPLMapOfSocket(d *zpl.Decoder, m map[string]*Socket) error {
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch e.Type {
+		case zpl.EndSection:
+			return nil
+		case zpl.StartSection:
+			v := m[e.Name]
+			if v == nil {
+				v = new(Socket)
+				m[e.Name] = v
+			}
+			if err := v.unmarshalZPL(d); err != nil {
+				return err
+			}
+		default:
+			return errors.New("zpl: cannot unmarshal value for key \"" + e.Name + "\" into Socket")
+		}
+	}
+}
+
+// MarshalZPL returns the ZPL encoding of x, as zpl.Marshal does.
+func (x *Socket) MarshalZPL() ([]byte, error) {
+	var buf bytes.Buffer
+	err := x.marshalZPL(&buf, "")
+	return buf.Bytes(), err
+}
+
+func (x *Socket) marshalZPL(buf *bytes.Buffer, indent string) error {
+	{
+		v := x.Type
+		if strings.ContainsAny(v, "\r\n") {
+			return errors.New("zpl: value of \"type\" contains a line break")
+		}
+		if n := len(v); n == 0 || strings.Trim(v, " \t\f") != v || n >= 2 && v[0] == '"' && v[n-1] == '"' {
+			v = "\"" + v + "\""
+		}
+		buf.WriteString(indent + "type = " + v + "\n")
+	}
+	{
+		v := x.Hwm
+		buf.WriteString(indent + "hwm = " + strconv.FormatUint(uint64(v), 10) + "\n")
+	}
+	for _, v := range x.Swap {
+		buf.WriteString(indent + "swap = " + strconv.FormatInt(int64(v), 10) + "\n")
+	}
+	{
+		v := x.Rate
+		if math.IsInf(float64(v), 0) || math.IsNaN(float64(v)) {
+			return errors.New("zpl: value of \"rate\" is not finite")
+		}
+		buf.WriteString(indent + "rate = " + strconv.FormatFloat(float64(v), 'f', -1, 64) + "\n")
+	}
+	for _, v := range x.Bind {
+		if strings.ContainsAny(v, "\r\n") {
+			return errors.New("zpl: value of \"bind\" contains a line break")
+		}
+		if n := len(v); n == 0 || strings.Trim(v, " \t\f") != v || n >= 2 && v[0] == '"' && v[n-1] == '"' {
+			v = "\"" + v + "\""
+		}
+		buf.WriteString(indent + "bind = " + v + "\n")
+	}
+	for _, v := range x.Connect {
+		if strings.ContainsAny(v, "\r\n") {
+			return errors.New("zpl: value of \"connect\" contains a line break")
+		}
+		if n := len(v); n == 0 || strings.Trim(v, " \t\f") != v || n >= 2 && v[0] == '"' && v[n-1] == '"' {
+			v = "\"" + v + "\""
+		}
+		buf.WriteString(indent + "connect = " + v + "\n")
+	}
+	return nil
+}
+
+// UnmarshalZPL decodes the ZPL document src into x, as zpl.Unmarshal does.
+func (x *Socket) UnmarshalZPL(src []byte) error {
+	return x.unmarshalZPL(zpl.NewDecoder(bytes.NewReader(src)))
+}
+
+func (x *Socket) unmarshalZPL(d *zpl.Decoder) error {
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case e.Type == zpl.EndSection:
+			return nil
+		case e.Type == zpl.AddValue && e.Name == "type":
+			x.Type = e.Value
+		case e.Type == zpl.AddValue && e.Name == "hwm":
+			v, err := strconv.ParseUint(e.Value, 10, 16)
+			if err != nil {
+				return errors.New("zpl: cannot unmarshal \"" + e.Value + "\" into uint16 for key \"hwm\": " + err.Error())
+			}
+			x.Hwm = uint16(v)
+		case e.Type == zpl.AddValue && e.Name == "swap":
+			v, err := strconv.ParseInt(e.Value, 10, 64)
+			if err != nil {
+				return errors.New("zpl: cannot unmarshal \"" + e.Value + "\" into int64 for key \"swap\": " + err.Error())
+			}
+			x.Swap = append(x.Swap, v)
+		case e.Type == zpl.AddValue && e.Name == "rate":
+			v, err := strconv.ParseFloat(e.Value, 64)
+			if err != nil {
+				return errors.New("zpl: cannot unmarshal \"" + e.Value + "\" into float64 for key \"rate\": " + err.Error())
+			}
+			x.Rate = v
+		case e.Type == zpl.AddValue && e.Name == "bind":
+			x.Bind = append(x.Bind, e.Value)
+		case e.Type == zpl.AddValue && e.Name == "connect":
+			x.Connect = append(x.Connect, e.Value)
+		default:
+			return errors.New("zpl: unknown key \"" + e.Name + "\" in Socket")
+		}
+	}
+}
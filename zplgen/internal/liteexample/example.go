@@ -0,0 +1,27 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package liteexample holds types with methods generated by zplgen -methods
+// -lite, to test that they behave as the reflection-based decoder does.
+//
+package liteexample
+
+//go:generate go run ../../../cmd/zplgen -methods -lite example.go
+
+// Agent is the configuration of an edge agent.
+//
+//zplgen:methods
+type Agent struct {
+	Name     string            `zpl:"name"`
+	Interval uint32            `zpl:"interval"`
+	Enabled  bool              `zpl:"enabled"`
+	Upstream []string          `zpl:"upstream"`
+	Sensors  map[string]*Probe `zpl:"sensors"`
+}
+
+//zplgen:methods
+type Probe struct {
+	Pin   int8    `zpl:"pin"`
+	Scale float32 `zpl:"scale"`
+}
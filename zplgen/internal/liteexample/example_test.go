@@ -0,0 +1,53 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package liteexample
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+var raw = []byte(`name = "edge 7"
+interval = 30
+enabled = true
+upstream = tcp://hub:5555
+upstream = tcp://backup:5555
+sensors
+    temperature
+        pin = 4
+        scale = 0.5
+    humidity
+        pin = -3
+`)
+
+func TestLiteMethods(t *testing.T) {
+	var generated, reflected Agent
+	if err := generated.UnmarshalZPL(raw); err != nil {
+		t.Fatal(err)
+	}
+	if err := zpl.NewDecoder(bytes.NewReader(raw)).Decode(&reflected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(generated, reflected) {
+		t.Errorf("UnmarshalZPL gave %+v, expected %+v", generated, reflected)
+	}
+	out, err := generated.MarshalZPL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := zpl.NewEncoder(&buf).Encode(&reflected); err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != buf.String() {
+		t.Errorf("MarshalZPL gave:\n%s\nexpected:\n%s", out, buf.String())
+	}
+	if err := new(Agent).UnmarshalZPL([]byte("sensors\n    a\n        pin = 300\n")); err == nil {
+		t.Errorf("expected an error for a value out of range")
+	}
+}
@@ -0,0 +1,200 @@
+// Code generated by zplgen -methods. DO NOT EDIT.
+
+package liteexample
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jtacoma/go-zpl/zpllite"
+)
+
+// MarshalZPL returns the ZPL encoding of x, as zpl.Marshal does.
+func (x *Agent) MarshalZPL() ([]byte, error) {
+	var buf bytes.Buffer
+	err := x.marshalZPL(&buf, "")
+	return buf.Bytes(), err
+}
+
+func (x *Agent) marshalZPL(buf *bytes.Buffer, indent string) error {
+	{
+		v := x.Name
+		if strings.ContainsAny(v, "\r\n") {
+			return errors.New("zpl: value of \"name\" contains a line break")
+		}
+		if n := len(v); n == 0 || strings.Trim(v, " \t\f") != v || n >= 2 && v[0] == '"' && v[n-1] == '"' {
+			v = "\"" + v + "\""
+		}
+		buf.WriteString(indent + "name = " + v + "\n")
+	}
+	{
+		v := x.Interval
+		buf.WriteString(indent + "interval = " + strconv.FormatUint(uint64(v), 10) + "\n")
+	}
+	{
+		v := x.Enabled
+		s := "0"
+		if v {
+			s = "1"
+		}
+		buf.WriteString(indent + "enabled = " + s + "\n")
+	}
+	for _, v := range x.Upstream {
+		if strings.ContainsAny(v, "\r\n") {
+			return errors.New("zpl: value of \"upstream\" contains a line break")
+		}
+		if n := len(v); n == 0 || strings.Trim(v, " \t\f") != v || n >= 2 && v[0] == '"' && v[n-1] == '"' {
+			v = "\"" + v + "\""
+		}
+		buf.WriteString(indent + "upstream = " + v + "\n")
+	}
+	buf.WriteString(indent + "sensors\n")
+	{
+		keys := make([]string, 0, len(x.Sensors))
+		for k := range x.Sensors {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if v := x.Sensors[k]; v != nil {
+				buf.WriteString(indent + "    " + k + "\n")
+				if err := v.marshalZPL(buf, indent+"        "); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// UnmarshalZPL decodes the ZPL document src into x, as zpl.Unmarshal does.
+func (x *Agent) UnmarshalZPL(src []byte) error {
+	return x.unmarshalZPL(zpllite.NewDecoder(bytes.NewReader(src)))
+}
+
+func (x *Agent) unmarshalZPL(d *zpllite.Decoder) error {
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case e.Type == zpllite.EndSection:
+			return nil
+		case e.Type == zpllite.AddValue && e.Name == "name":
+			x.Name = e.Value
+		case e.Type == zpllite.AddValue && e.Name == "interval":
+			v, err := strconv.ParseUint(e.Value, 10, 32)
+			if err != nil {
+				return errors.New("zpl: cannot unmarshal \"" + e.Value + "\" into uint32 for key \"interval\": " + err.Error())
+			}
+			x.Interval = uint32(v)
+		case e.Type == zpllite.AddValue && e.Name == "enabled":
+			v, err := strconv.ParseBool(e.Value)
+			if err != nil {
+				return errors.New("zpl: cannot unmarshal \"" + e.Value + "\" into bool for key \"enabled\": " + err.Error())
+			}
+			x.Enabled = v
+		case e.Type == zpllite.AddValue && e.Name == "upstream":
+			x.Upstream = append(x.Upstream, e.Value)
+		case e.Type == zpllite.StartSection && e.Name == "sensors":
+			if x.Sensors == nil {
+				x.Sensors = make(map[string]*Probe)
+			}
+			if err := unmarshalZPLMapOfProbe(d, x.Sensors); err != nil {
+				return err
+			}
+		default:
+			return errors.New("zpl: unknown key \"" + e.Name + "\" in Agent")
+		}
+	}
+}
+
+func unmarshalZPLMapOfProbe(d *zpllite.Decoder, m map[string]*Probe) error {
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch e.Type {
+		case zpllite.EndSection:
+			return nil
+		case zpllite.StartSection:
+			v := m[e.Name]
+			if v == nil {
+				v = new(Probe)
+				m[e.Name] = v
+			}
+			if err := v.unmarshalZPL(d); err != nil {
+				return err
+			}
+		default:
+			return errors.New("zpl: cannot unmarshal value for key \"" + e.Name + "\" into Probe")
+		}
+	}
+}
+
+// MarshalZPL returns the ZPL encoding of x, as zpl.Marshal does.
+func (x *Probe) MarshalZPL() ([]byte, error) {
+	var buf bytes.Buffer
+	err := x.marshalZPL(&buf, "")
+	return buf.Bytes(), err
+}
+
+func (x *Probe) marshalZPL(buf *bytes.Buffer, indent string) error {
+	{
+		v := x.Pin
+		buf.WriteString(indent + "pin = " + strconv.FormatInt(int64(v), 10) + "\n")
+	}
+	{
+		v := x.Scale
+		if math.IsInf(float64(v), 0) || math.IsNaN(float64(v)) {
+			return errors.New("zpl: value of \"scale\" is not finite")
+		}
+		buf.WriteString(indent + "scale = " + strconv.FormatFloat(float64(v), 'f', -1, 32) + "\n")
+	}
+	return nil
+}
+
+// UnmarshalZPL decodes the ZPL document src into x, as zpl.Unmarshal does.
+func (x *Probe) UnmarshalZPL(src []byte) error {
+	return x.unmarshalZPL(zpllite.NewDecoder(bytes.NewReader(src)))
+}
+
+func (x *Probe) unmarshalZPL(d *zpllite.Decoder) error {
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case e.Type == zpllite.EndSection:
+			return nil
+		case e.Type == zpllite.AddValue && e.Name == "pin":
+			v, err := strconv.ParseInt(e.Value, 10, 8)
+			if err != nil {
+				return errors.New("zpl: cannot unmarshal \"" + e.Value + "\" into int8 for key \"pin\": " + err.Error())
+			}
+			x.Pin = int8(v)
+		case e.Type == zpllite.AddValue && e.Name == "scale":
+			v, err := strconv.ParseFloat(e.Value, 32)
+			if err != nil {
+				return errors.New("zpl: cannot unmarshal \"" + e.Value + "\" into float32 for key \"scale\": " + err.Error())
+			}
+			x.Scale = float32(v)
+		default:
+			return errors.New("zpl: unknown key \"" + e.Name + "\" in Probe")
+		}
+	}
+}
@@ -0,0 +1,391 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplgen
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MethodsDirective marks a struct type for which GenerateMethods writes
+// methods.  It must appear on a line of its own in the type's doc comment.
+//
+const MethodsDirective = "//zplgen:methods"
+
+// GenerateMethods returns the formatted source of a Go file declaring
+// MarshalZPL and UnmarshalZPL methods, which implement zpl.Marshaler and
+// zpl.Unmarshaler without reflection, for each struct type in the Go source
+// file src that is marked with MethodsDirective:
+//
+//     //zplgen:methods
+//     type Config struct {
+//         Bind    []string `zpl:"bind"`
+//         Workers int      `zpl:"workers"`
+//         Log     *Log     `zpl:"log"`
+//     }
+//
+// Fields may be strings, bools, integers and floats, slices of those, and
+// pointers to, or maps with string keys of pointers to, other marked types.
+// Fields are written in the order they are declared and keys are matched
+// exactly; tag options and the "*" tag are not supported.  Types that need
+// more than this should be left to the reflection-based encoder and decoder.
+//
+// The generated methods write and read the same documents as zpl.Marshal and
+// zpl.Unmarshal with their default settings, except that conflicts between
+// properties and sections are not detected.
+//
+func GenerateMethods(filename string, src []byte) ([]byte, error) {
+	return generateMethods(filename, src, "zpl", "github.com/jtacoma/go-zpl")
+}
+
+// GenerateLiteMethods is like GenerateMethods but the methods it generates
+// use package zpllite instead of package zpl, so that they can be built with
+// TinyGo.  Since zpllite does not define the Marshaler and Unmarshaler
+// interfaces, the methods must be called directly.
+//
+func GenerateLiteMethods(filename string, src []byte) ([]byte, error) {
+	return generateMethods(filename, src, "zpllite", "github.com/jtacoma/go-zpl/zpllite")
+}
+
+// Generate methods that use the package with the given name and path.
+func generateMethods(filename string, src []byte, pkg, path string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	g := &methodsGenerator{
+		pkg:     pkg,
+		path:    path,
+		marked:  make(map[string]*ast.StructType),
+		imports: map[string]bool{"bytes": true, "errors": true, "io": true},
+		maps:    make(map[string]bool),
+	}
+	var names []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			doc := ts.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok || !hasDirective(doc) {
+				continue
+			}
+			g.marked[ts.Name.Name] = st
+			names = append(names, ts.Name.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("zplgen: " + filename + " has no struct types marked " + MethodsDirective)
+	}
+	for _, name := range names {
+		if err := g.methods(name, g.marked[name]); err != nil {
+			return nil, err
+		}
+	}
+	var out bytes.Buffer
+	out.WriteString("// Code generated by zplgen -methods. DO NOT EDIT.\n\npackage " + file.Name.Name + "\n\nimport (\n")
+	var paths []string
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		out.WriteString(strconv.Quote(path) + "\n")
+	}
+	out.WriteString("\n" + strconv.Quote(g.path) + "\n)\n")
+	out.Write(g.buf.Bytes())
+	return format.Source(out.Bytes())
+}
+
+// Report whether a doc comment contains MethodsDirective.
+func hasDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == MethodsDirective {
+			return true
+		}
+	}
+	return false
+}
+
+type methodsGenerator struct {
+	buf     bytes.Buffer
+	pkg     string                     // name of the package that decodes events
+	path    string                     // import path of that package
+	marked  map[string]*ast.StructType // marked types by name
+	imports map[string]bool            // packages used by the generated code
+	maps    map[string]bool            // element types of maps with a decoding function
+}
+
+// The shape of a field that generated code can handle.
+type fieldKind int
+
+const (
+	scalarField  fieldKind = iota // a string, bool, integer or float
+	sliceField                    // a slice of scalars
+	pointerField                  // a pointer to a marked type
+	mapField                      // a map of pointers to a marked type
+)
+
+type methodField struct {
+	goName string
+	key    string
+	kind   fieldKind
+	typ    string // the scalar or marked type involved
+}
+
+// Return the fields of a marked struct type.
+func (g *methodsGenerator) fields(name string, st *ast.StructType) ([]methodField, error) {
+	var fields []methodField
+	for _, f := range st.Fields.List {
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			s, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(s)
+		}
+		key := string(tag)
+		if strings.Contains(key, ":") {
+			key = tag.Get("zpl")
+		}
+		if i := strings.Index(key, ","); i >= 0 {
+			return nil, errors.New("zplgen: field of " + name + " has tag options, which are not supported: " + key)
+		}
+		if key == "" || key == "-" {
+			continue
+		} else if key == "*" || strings.Contains(key, "|") {
+			return nil, errors.New("zplgen: field of " + name + " has unsupported tag " + strconv.Quote(key))
+		}
+		kind, typ, ok := g.fieldType(f.Type)
+		if !ok {
+			return nil, errors.New("zplgen: field " + strconv.Quote(key) + " of " + name + " has an unsupported type")
+		}
+		for _, id := range f.Names {
+			fields = append(fields, methodField{goName: id.Name, key: key, kind: kind, typ: typ})
+		}
+	}
+	return fields, nil
+}
+
+// Classify a field's type expression.
+func (g *methodsGenerator) fieldType(expr ast.Expr) (kind fieldKind, typ string, ok bool) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return scalarField, t.Name, scalarBits(t.Name) >= 0
+	case *ast.ArrayType:
+		if id, isIdent := t.Elt.(*ast.Ident); isIdent && t.Len == nil {
+			return sliceField, id.Name, scalarBits(id.Name) >= 0
+		}
+	case *ast.StarExpr:
+		if id, isIdent := t.X.(*ast.Ident); isIdent {
+			return pointerField, id.Name, g.marked[id.Name] != nil
+		}
+	case *ast.MapType:
+		key, isIdent := t.Key.(*ast.Ident)
+		if star, isStar := t.Value.(*ast.StarExpr); isIdent && key.Name == "string" && isStar {
+			if id, isIdent := star.X.(*ast.Ident); isIdent {
+				return mapField, id.Name, g.marked[id.Name] != nil
+			}
+		}
+	}
+	return
+}
+
+// Return the bit size to pass to strconv for a scalar type, 0 for string, bool,
+// int and uint, or -1 if typ is not a scalar type.
+func scalarBits(typ string) int {
+	switch typ {
+	case "string", "bool", "int", "uint":
+		return 0
+	case "int8", "uint8":
+		return 8
+	case "int16", "uint16":
+		return 16
+	case "int32", "uint32", "float32":
+		return 32
+	case "int64", "uint64", "float64":
+		return 64
+	}
+	return -1
+}
+
+// Write the methods of one marked type.
+func (g *methodsGenerator) methods(name string, st *ast.StructType) error {
+	fields, err := g.fields(name, st)
+	if err != nil {
+		return err
+	}
+	w := &g.buf
+	w.WriteString("\n// MarshalZPL returns the ZPL encoding of x, as zpl.Marshal does.\n")
+	w.WriteString("func (x *" + name + ") MarshalZPL() ([]byte, error) {\n")
+	w.WriteString("var buf bytes.Buffer\nerr := x.marshalZPL(&buf, \"\")\nreturn buf.Bytes(), err\n}\n")
+	w.WriteString("\nfunc (x *" + name + ") marshalZPL(buf *bytes.Buffer, indent string) error {\n")
+	for _, f := range fields {
+		g.marshalField(f)
+	}
+	w.WriteString("return nil\n}\n")
+
+	w.WriteString("\n// UnmarshalZPL decodes the ZPL document src into x, as zpl.Unmarshal does.\n")
+	w.WriteString("func (x *" + name + ") UnmarshalZPL(src []byte) error {\n")
+	w.WriteString("return x.unmarshalZPL(" + g.pkg + ".NewDecoder(bytes.NewReader(src)))\n}\n")
+	w.WriteString("\nfunc (x *" + name + ") unmarshalZPL(d *" + g.pkg + ".Decoder) error {\n")
+	w.WriteString("for {\ne, err := d.Next()\nif err == io.EOF {\nreturn nil\n} else if err != nil {\nreturn err\n}\n")
+	w.WriteString("switch {\ncase e.Type == " + g.pkg + ".EndSection:\nreturn nil\n")
+	for _, f := range fields {
+		g.unmarshalField(f)
+	}
+	w.WriteString("default:\nreturn errors.New(\"zpl: unknown key \\\"\" + e.Name + \"\\\" in " + name + "\")\n}\n}\n}\n")
+	for _, f := range fields {
+		if f.kind == mapField && !g.maps[f.typ] {
+			g.maps[f.typ] = true
+			g.mapFunc(f.typ)
+		}
+	}
+	return nil
+}
+
+// Write the statements that encode one field.
+func (g *methodsGenerator) marshalField(f methodField) {
+	w := &g.buf
+	switch f.kind {
+	case scalarField:
+		w.WriteString("{\nv := x." + f.goName + "\n")
+		g.marshalScalar(f)
+		w.WriteString("}\n")
+	case sliceField:
+		w.WriteString("for _, v := range x." + f.goName + " {\n")
+		g.marshalScalar(f)
+		w.WriteString("}\n")
+	case pointerField:
+		w.WriteString("if x." + f.goName + " != nil {\n")
+		w.WriteString("buf.WriteString(indent + " + strconv.Quote(f.key+"\n") + ")\n")
+		w.WriteString("if err := x." + f.goName + ".marshalZPL(buf, indent+\"    \"); err != nil {\nreturn err\n}\n}\n")
+	case mapField:
+		g.imports["sort"] = true
+		w.WriteString("buf.WriteString(indent + " + strconv.Quote(f.key+"\n") + ")\n")
+		w.WriteString("{\nkeys := make([]string, 0, len(x." + f.goName + "))\n")
+		w.WriteString("for k := range x." + f.goName + " {\nkeys = append(keys, k)\n}\nsort.Strings(keys)\n")
+		w.WriteString("for _, k := range keys {\nif v := x." + f.goName + "[k]; v != nil {\n")
+		w.WriteString("buf.WriteString(indent + \"    \" + k + \"\\n\")\n")
+		w.WriteString("if err := v.marshalZPL(buf, indent+\"        \"); err != nil {\nreturn err\n}\n}\n}\n}\n")
+	}
+}
+
+// Write the statements that encode the scalar v as the value of a field.
+func (g *methodsGenerator) marshalScalar(f methodField) {
+	w := &g.buf
+	prefix := strconv.Quote(f.key + " = ")
+	bits := strconv.Itoa(scalarBits(f.typ))
+	var text string
+	switch f.typ {
+	case "string":
+		g.imports["strings"] = true
+		w.WriteString("if strings.ContainsAny(v, \"\\r\\n\") {\n")
+		w.WriteString("return errors.New(\"zpl: value of \\\"" + f.key + "\\\" contains a line break\")\n}\n")
+		w.WriteString("if n := len(v); n == 0 || strings.Trim(v, \" \\t\\f\") != v || n >= 2 && v[0] == '\"' && v[n-1] == '\"' {\n")
+		w.WriteString("v = \"\\\"\" + v + \"\\\"\"\n}\n")
+		text = "v"
+	case "bool":
+		w.WriteString("s := \"0\"\nif v {\ns = \"1\"\n}\n")
+		text = "s"
+	case "float32", "float64":
+		g.imports["math"] = true
+		g.imports["strconv"] = true
+		w.WriteString("if math.IsInf(float64(v), 0) || math.IsNaN(float64(v)) {\n")
+		w.WriteString("return errors.New(\"zpl: value of \\\"" + f.key + "\\\" is not finite\")\n}\n")
+		text = "strconv.FormatFloat(float64(v), 'f', -1, " + bits + ")"
+	default:
+		g.imports["strconv"] = true
+		if strings.HasPrefix(f.typ, "u") {
+			text = "strconv.FormatUint(uint64(v), 10)"
+		} else {
+			text = "strconv.FormatInt(int64(v), 10)"
+		}
+	}
+	w.WriteString("buf.WriteString(indent + " + prefix + " + " + text + " + \"\\n\")\n")
+}
+
+// Write the case that decodes one field.
+func (g *methodsGenerator) unmarshalField(f methodField) {
+	w := &g.buf
+	key := strconv.Quote(f.key)
+	switch f.kind {
+	case scalarField, sliceField:
+		w.WriteString("case e.Type == " + g.pkg + ".AddValue && e.Name == " + key + ":\n")
+		value := g.unmarshalScalar(f)
+		if f.kind == sliceField {
+			w.WriteString("x." + f.goName + " = append(x." + f.goName + ", " + value + ")\n")
+		} else {
+			w.WriteString("x." + f.goName + " = " + value + "\n")
+		}
+	case pointerField:
+		w.WriteString("case e.Type == " + g.pkg + ".StartSection && e.Name == " + key + ":\n")
+		w.WriteString("if x." + f.goName + " == nil {\nx." + f.goName + " = new(" + f.typ + ")\n}\n")
+		w.WriteString("if err := x." + f.goName + ".unmarshalZPL(d); err != nil {\nreturn err\n}\n")
+	case mapField:
+		w.WriteString("case e.Type == " + g.pkg + ".StartSection && e.Name == " + key + ":\n")
+		w.WriteString("if x." + f.goName + " == nil {\nx." + f.goName + " = make(map[string]*" + f.typ + ")\n}\n")
+		w.WriteString("if err := unmarshalZPLMapOf" + f.typ + "(d, x." + f.goName + "); err != nil {\nreturn err\n}\n")
+	}
+}
+
+// Write the statements that parse e.Value for a field, returning the
+// expression for the parsed value.
+func (g *methodsGenerator) unmarshalScalar(f methodField) string {
+	w := &g.buf
+	bits := strconv.Itoa(scalarBits(f.typ))
+	var parse string
+	switch {
+	case f.typ == "string":
+		return "e.Value"
+	case f.typ == "bool":
+		parse = "strconv.ParseBool(e.Value)"
+	case strings.HasPrefix(f.typ, "float"):
+		parse = "strconv.ParseFloat(e.Value, " + bits + ")"
+	case strings.HasPrefix(f.typ, "u"):
+		parse = "strconv.ParseUint(e.Value, 10, " + bits + ")"
+	default:
+		parse = "strconv.ParseInt(e.Value, 10, " + bits + ")"
+	}
+	g.imports["strconv"] = true
+	w.WriteString("v, err := " + parse + "\nif err != nil {\n")
+	w.WriteString("return errors.New(\"zpl: cannot unmarshal \\\"\" + e.Value + \"\\\" into " + f.typ +
+		" for key \\\"" + f.key + "\\\": \" + err.Error())\n}\n")
+	if f.typ == "bool" || f.typ == "float64" || f.typ == "int64" || f.typ == "uint64" {
+		return "v"
+	}
+	return f.typ + "(v)"
+}
+
+// Write a function that decodes the subsections of a section into a map of
+// pointers to the marked type elem.
+func (g *methodsGenerator) mapFunc(elem string) {
+	w := &g.buf
+	w.WriteString("\nfunc unmarshalZPLMapOf" + elem + "(d *" + g.pkg + ".Decoder, m map[string]*" + elem + ") error {\n")
+	w.WriteString("for {\ne, err := d.Next()\nif err == io.EOF {\nreturn nil\n} else if err != nil {\nreturn err\n}\n")
+	w.WriteString("switch e.Type {\ncase " + g.pkg + ".EndSection:\nreturn nil\ncase " + g.pkg + ".StartSection:\n")
+	w.WriteString("v := m[e.Name]\nif v == nil {\nv = new(" + elem + ")\nm[e.Name] = v\n}\n")
+	w.WriteString("if err := v.unmarshalZPL(d); err != nil {\nreturn err\n}\n")
+	w.WriteString("default:\nreturn errors.New(\"zpl: cannot unmarshal value for key \\\"\" + e.Name + \"\\\" into " + elem + "\")\n}\n}\n}\n")
+}
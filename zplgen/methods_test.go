@@ -0,0 +1,63 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplgen
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateMethods(t *testing.T) {
+	path := filepath.Join("internal", "example", "example.go")
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := GenerateMethods("example.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadFile(filepath.Join("internal", "example", "example_zpl.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(expected) {
+		t.Errorf("generated methods differ from example_zpl.go; run go generate in internal/example")
+	}
+}
+
+func TestGenerateLiteMethods(t *testing.T) {
+	path := filepath.Join("internal", "liteexample", "example.go")
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := GenerateLiteMethods("example.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadFile(filepath.Join("internal", "liteexample", "example_zpl.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(expected) {
+		t.Errorf("generated methods differ from example_zpl.go; run go generate in internal/liteexample")
+	}
+}
+
+func TestGenerateMethods_Unsupported(t *testing.T) {
+	for _, src := range []string{
+		"package p\n\ntype T struct{}\n",
+		"package p\n\n//zplgen:methods\ntype T struct {\n\tC chan int `zpl:\"c\"`\n}\n",
+		"package p\n\n//zplgen:methods\ntype T struct {\n\tS *U `zpl:\"s\"`\n}\n\ntype U struct{}\n",
+		"package p\n\n//zplgen:methods\ntype T struct {\n\tN int `zpl:\"n,format=size\"`\n}\n",
+		"package p\n\n//zplgen:methods\ntype T struct {\n\tM map[string]*T `*`\n}\n",
+	} {
+		if _, err := GenerateMethods("p.go", []byte(src)); err == nil {
+			t.Errorf("expected an error for:\n%s", src)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zplhttp serves configurations over HTTP as ZPL, for introspecting
+// the configuration of a running service much as expvar exposes its
+// variables:
+//
+//     http.Handle("/debug/config", zplhttp.ConfigHandler(func() interface{} {
+//         return holder.Get()
+//     }))
+//
+package zplhttp
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// ConfigHandler returns a handler that responds to GET and HEAD requests with
+// the ZPL encoding of the value returned by get, which is called once per
+// request so that the response reflects the live configuration.  Properties
+// are aligned for reading, and struct fields tagged with the "mask" option are
+// masked as by zpl.Marshal, so secrets are not exposed.
+//
+func ConfigHandler(get func() interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var buf bytes.Buffer
+		e := zpl.NewEncoder(&buf)
+		e.SetAlign(true)
+		if err := e.Encode(get()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if r.Method == "GET" {
+			w.Write(buf.Bytes())
+		}
+	})
+}
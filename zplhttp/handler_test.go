@@ -0,0 +1,43 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type config struct {
+	Addr     string `zpl:"addr"`
+	Password string `zpl:"password,mask"`
+}
+
+func TestConfigHandler(t *testing.T) {
+	c := &config{Addr: "localhost", Password: "hunter2"}
+	h := ConfigHandler(func() interface{} { return c })
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/config", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if body := rec.Body.String(); body != "addr     = localhost\npassword = ******\n" {
+		t.Errorf("unexpected body:\n%s", body)
+	}
+
+	c = &config{Addr: "example.com"}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/config", nil))
+	if body := rec.Body.String(); body != "addr     = example.com\npassword = ******\n" {
+		t.Errorf("unexpected body after change:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/debug/config", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zplini converts between ZPL and INI files, so that tools built for
+// INI can read and write ZPL configurations.
+//
+// A section header such as "[main.frontend]" corresponds to the ZPL section
+// "frontend" within section "main", and properties before the first header
+// belong to the top level.  A key repeated within a section corresponds to a
+// repeated ZPL property.  Lines starting with ";" or "#" are comments.
+//
+package zplini
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// ToZPL converts the INI file src to ZPL, keeping the order in which sections
+// and keys first appear.  Section names split on dots, and keys, must be valid
+// ZPL key names.  One pair of double quotes around a value is removed, as for
+// the empty and space-padded values that FromZPL quotes.  Comments are
+// dropped.
+//
+func ToZPL(src []byte) ([]byte, error) {
+	doc := new(zpl.Section)
+	section := doc
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	lineno := 0
+	fail := func(msg string) error {
+		return errors.New("zplini: line " + strconv.Itoa(lineno) + ": " + msg)
+	}
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, fail("unterminated section header")
+			}
+			section = doc
+			for _, name := range strings.Split(strings.TrimSpace(line[1:len(line)-1]), ".") {
+				if !isKey(name) {
+					return nil, fail("invalid section name \"" + name + "\"")
+				} else if section.HasValue(name) {
+					return nil, fail("\"" + name + "\" is both a key and a section")
+				}
+				section = section.AddSection(name)
+			}
+			continue
+		}
+		i := strings.IndexAny(line, "=:")
+		if i < 0 {
+			return nil, fail("expected a section header or a key = value setting")
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if !isKey(key) {
+			return nil, fail("invalid key \"" + key + "\"")
+		} else if section.Section(key) != nil {
+			return nil, fail("\"" + key + "\" is both a key and a section")
+		}
+		if n := len(value); n >= 2 && value[0] == '"' && value[n-1] == '"' {
+			value = value[1 : n-1]
+		}
+		section.Add(key, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return zpl.Marshal(doc)
+}
+
+// FromZPL converts the ZPL document src to an INI file.  Top-level properties
+// come first, then a header for each section with properties of its own,
+// named by its dotted path.  Sections that hold only other sections get no
+// header of their own.  Values that are empty, have leading or trailing
+// spaces, or are already wrapped in double quotes are written in double
+// quotes, which ToZPL removes.
+//
+func FromZPL(src []byte) ([]byte, error) {
+	doc, err := zpl.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	writeProperties(&buf, doc)
+	writeSections(&buf, doc, nil)
+	return buf.Bytes(), nil
+}
+
+func writeProperties(buf *bytes.Buffer, s *zpl.Section) {
+	for _, key := range s.Keys() {
+		for _, value := range s.Values(key) {
+			if n := len(value); n == 0 || value != strings.TrimSpace(value) || n >= 2 && value[0] == '"' && value[n-1] == '"' {
+				value = `"` + value + `"`
+			}
+			buf.WriteString(key + " = " + value + "\n")
+		}
+	}
+}
+
+func writeSections(buf *bytes.Buffer, s *zpl.Section, path []string) {
+	for _, name := range s.Keys() {
+		sub := s.Section(name)
+		if sub == nil {
+			continue
+		}
+		subpath := append(path[:len(path):len(path)], name)
+		if hasProperties(sub) || len(sub.Keys()) == 0 {
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			buf.WriteString("[" + strings.Join(subpath, ".") + "]\n")
+			writeProperties(buf, sub)
+		}
+		writeSections(buf, sub, subpath)
+	}
+}
+
+func hasProperties(s *zpl.Section) bool {
+	for _, key := range s.Keys() {
+		if s.HasValue(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Report whether name may be used as a ZPL key.
+func isKey(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '/') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplini
+
+import (
+	"testing"
+)
+
+const iniDoc = `version = 1
+
+[main]
+type = zmq_queue
+
+[main.frontend]
+bind = tcp://eth0:5555
+bind = inproc://device
+
+[main.backend]
+`
+
+const zplDoc = `version = 1
+main
+    type = zmq_queue
+    frontend
+        bind = tcp://eth0:5555
+        bind = inproc://device
+    backend
+`
+
+func TestToZPL(t *testing.T) {
+	out, err := ToZPL([]byte("; comment\n" + iniDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != zplDoc {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	for _, src := range []string{"[main\n", "[a-b]\n", "x\n", "a = 1\n[a]\n"} {
+		if _, err := ToZPL([]byte(src)); err == nil {
+			t.Errorf("expected an error for %q", src)
+		}
+	}
+}
+
+func TestFromZPL(t *testing.T) {
+	out, err := FromZPL([]byte(zplDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != iniDoc {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := "a = \"\"\nb = \" x\"\nc = \"y \"\nd = \"\"z\"\"\ne = plain\n"
+	ini, err := FromZPL([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ToZPL(ini)
+	if err != nil {
+		t.Fatal(err)
+	} else if string(out) != src {
+		t.Errorf("round trip through\n%s\nchanged the document to\n%s", ini, out)
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zplk8s delivers ZPL configuration through Kubernetes ConfigMaps.
+// Manifest renders a Go value as ZPL held under one key of a ConfigMap
+// manifest, and Unmarshal decodes that key from a manifest such as the
+// output of "kubectl get configmap NAME -o yaml":
+//
+//     out, err := zplk8s.Manifest(zplk8s.Meta{Name: "broker"}, "broker.zpl", &cfg)
+//
+// This package does not import a YAML library.  It writes manifests in a
+// fixed layout and reads only what a ConfigMap needs: the string values of
+// its top-level "data" mapping, written as plain, quoted or literal block
+// scalars ("|").  Manifests in JSON are read too.
+//
+package zplk8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// Meta holds the metadata of a ConfigMap.
+//
+type Meta struct {
+	Name      string
+	Namespace string // omitted from the manifest if empty
+	Labels    map[string]string
+}
+
+// Manifest returns a ConfigMap manifest, in YAML, whose data holds the ZPL
+// encoding of v, as by zpl.Marshal, under key.  The document is written as a
+// literal block scalar, so that it reads in the manifest as it would in a
+// file, unless it contains characters that such a scalar cannot hold.
+//
+func Manifest(meta Meta, key string, v interface{}) ([]byte, error) {
+	if meta.Name == "" {
+		return nil, errors.New("zplk8s: a ConfigMap must have a name")
+	} else if key == "" {
+		return nil, errors.New("zplk8s: a ConfigMap key must not be empty")
+	}
+	doc, err := zpl.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("apiVersion: v1\nkind: ConfigMap\nmetadata:\n")
+	buf.WriteString("  name: " + scalar(meta.Name) + "\n")
+	if meta.Namespace != "" {
+		buf.WriteString("  namespace: " + scalar(meta.Namespace) + "\n")
+	}
+	if len(meta.Labels) > 0 {
+		names := make([]string, 0, len(meta.Labels))
+		for name := range meta.Labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		buf.WriteString("  labels:\n")
+		for _, name := range names {
+			buf.WriteString("    " + scalar(name) + ": " + strconv.Quote(meta.Labels[name]) + "\n")
+		}
+	}
+	buf.WriteString("data:\n  " + scalar(key) + ":")
+	if isLiteral(doc) {
+		buf.WriteString(" |\n")
+		for _, line := range strings.SplitAfter(string(doc), "\n") {
+			if line == "\n" {
+				buf.WriteString(line)
+			} else if line != "" {
+				buf.WriteString("    " + line)
+			}
+		}
+	} else {
+		buf.WriteString(" " + strconv.Quote(string(doc)) + "\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// Report whether doc can be written as a literal block scalar without
+// changing it: it is valid UTF-8 without control characters other than line
+// feeds and tabs, it ends with exactly one line feed, and its first line is
+// not indented.
+func isLiteral(doc []byte) bool {
+	n := len(doc)
+	if n < 2 || doc[n-1] != '\n' || doc[n-2] == '\n' || doc[0] == ' ' || doc[0] == '\t' || !utf8.Valid(doc) {
+		return false
+	}
+	for _, c := range string(doc) {
+		if c < 0x20 && c != '\n' && c != '\t' || c == 0x7f || c == '\uFEFF' {
+			return false
+		}
+	}
+	return true
+}
+
+// Return s as a YAML scalar: plain if it cannot be mistaken for anything but
+// a string, and otherwise double-quoted.
+func scalar(s string) string {
+	if s == "" || !isLetter(s[0]) {
+		return strconv.Quote(s)
+	}
+	for i := 1; i < len(s); i++ {
+		if c := s[i]; !isLetter(c) && !(c >= '0' && c <= '9') && c != '.' && c != '_' && c != '-' && c != '/' {
+			return strconv.Quote(s)
+		}
+	}
+	switch strings.ToLower(s) {
+	case "y", "n", "yes", "no", "true", "false", "on", "off", "null":
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// Unmarshal decodes the ZPL document held under key in the data of the
+// ConfigMap manifest into v, as by zpl.Unmarshal.
+//
+func Unmarshal(manifest []byte, key string, v interface{}) error {
+	doc, err := Extract(manifest, key)
+	if err != nil {
+		return err
+	}
+	return zpl.Unmarshal(doc, v)
+}
+
+// Extract returns the value held under key in the data of the ConfigMap
+// manifest, which may be YAML or JSON.
+//
+func Extract(manifest []byte, key string) ([]byte, error) {
+	var data map[string]string
+	if trimmed := bytes.TrimSpace(manifest); len(trimmed) > 0 && trimmed[0] == '{' {
+		var m struct {
+			Data map[string]string `json:"data"`
+		}
+		if err := json.Unmarshal(trimmed, &m); err != nil {
+			return nil, errors.New("zplk8s: " + err.Error())
+		}
+		data = m.Data
+	} else {
+		var err error
+		if data, err = parseData(manifest); err != nil {
+			return nil, err
+		}
+	}
+	value, ok := data[key]
+	if !ok {
+		return nil, errors.New("zplk8s: no key " + strconv.Quote(key) + " in the ConfigMap data")
+	}
+	return []byte(value), nil
+}
+
+// Parse the top-level "data" mapping of a YAML ConfigMap manifest.
+func parseData(manifest []byte) (map[string]string, error) {
+	text := strings.TrimSuffix(strings.ReplaceAll(string(manifest), "\r\n", "\n"), "\n")
+	lines := strings.Split(text, "\n")
+	data := make(map[string]string)
+	i := 0
+	for i < len(lines) && strings.TrimRight(lines[i], " ") != "data:" {
+		i++
+	}
+	if i == len(lines) {
+		return data, nil
+	}
+	i++
+	indent := -1
+	for i < len(lines) {
+		line := lines[i]
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed[0] == '#' {
+			i++
+			continue
+		}
+		n := len(line) - len(trimmed)
+		if n == 0 {
+			break
+		} else if indent < 0 {
+			indent = n
+		} else if n != indent {
+			return nil, lineError(i, "unexpected indentation")
+		}
+		key, rest, err := splitEntry(trimmed)
+		if err != nil {
+			return nil, lineError(i, err.Error())
+		}
+		at := i
+		i++
+		var value string
+		if strings.HasPrefix(rest, "|") {
+			value, i, err = blockScalar(lines, i, indent, rest)
+		} else {
+			value, err = flowScalar(rest)
+		}
+		if err != nil {
+			return nil, lineError(at, err.Error())
+		}
+		data[key] = value
+	}
+	return data, nil
+}
+
+func lineError(i int, msg string) error {
+	return errors.New("zplk8s: line " + strconv.Itoa(i+1) + ": " + msg)
+}
+
+// Split a "key: value" entry into its key, unquoted, and the rest of the line.
+func splitEntry(entry string) (key, rest string, err error) {
+	if entry[0] == '"' || entry[0] == '\'' {
+		end := closingQuote(entry)
+		if end < 0 || !strings.HasPrefix(entry[end+1:], ":") {
+			return "", "", errors.New("malformed key")
+		}
+		if key, err = flowScalar(entry[:end+1]); err != nil {
+			return "", "", err
+		}
+		return key, strings.TrimSpace(entry[end+2:]), nil
+	}
+	i := strings.Index(entry, ":")
+	if i < 0 || i+1 < len(entry) && entry[i+1] != ' ' {
+		return "", "", errors.New("expected \"key: value\"")
+	}
+	return entry[:i], strings.TrimSpace(entry[i+1:]), nil
+}
+
+// Return the index of the quote that closes the quoted scalar at the start
+// of s, or -1 if there is none.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[0] == '"' && s[i] == '\\':
+			i++
+		case s[i] == s[0] && s[0] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == s[0]:
+			return i
+		}
+	}
+	return -1
+}
+
+// Return the value of a scalar written on a single line.
+func flowScalar(s string) (string, error) {
+	switch {
+	case s == "":
+		return "", nil
+	case s[0] == '"':
+		if closingQuote(s) != len(s)-1 {
+			return "", errors.New("unsupported multi-line or malformed quoted value")
+		}
+		return unquote(s)
+	case s[0] == '\'':
+		if closingQuote(s) != len(s)-1 {
+			return "", errors.New("unsupported multi-line or malformed quoted value")
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s[0] == '>' || s[0] == '&' || s[0] == '*' || s[0] == '!' || s[0] == '[' || s[0] == '{':
+		return "", errors.New("unsupported value " + strconv.Quote(s))
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimRight(s[:i], " ")
+	}
+	return s, nil
+}
+
+// Unquote a double-quoted YAML scalar.  Its escapes are those of Go except
+// for a few that Go lacks.
+func unquote(s string) (string, error) {
+	s = strings.NewReplacer(`\/`, `/`, `\e`, `\x1b`, `\0`, `\x00`, `\ `, ` `, `\\`, `\\`).Replace(s)
+	value, err := strconv.Unquote(s)
+	if err != nil {
+		return "", errors.New("malformed quoted value")
+	}
+	return value, nil
+}
+
+// Read the literal block scalar whose header is the rest of the entry line
+// before lines[i], for an entry indented by indent spaces.  Return its value
+// and the index of the line after it.
+func blockScalar(lines []string, i, indent int, header string) (string, int, error) {
+	chomp, width := byte(0), 0
+	for _, c := range []byte(header[1:]) {
+		switch {
+		case (c == '-' || c == '+') && chomp == 0:
+			chomp = c
+		case c >= '1' && c <= '9' && width == 0:
+			width = indent + int(c-'0')
+		default:
+			return "", i, errors.New("unsupported block scalar header " + strconv.Quote(header))
+		}
+	}
+	var body []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimLeft(line, " ")
+		n := len(line) - len(trimmed)
+		if trimmed == "" {
+			if width > 0 && n > width {
+				body = append(body, line[width:])
+			} else {
+				body = append(body, "")
+			}
+			continue
+		}
+		if width == 0 {
+			width = n
+		}
+		if n < width || width <= indent {
+			break
+		}
+		body = append(body, line[width:])
+	}
+	// Trailing blank lines belong to the value only if it keeps them.
+	content := len(body)
+	for content > 0 && body[content-1] == "" {
+		content--
+	}
+	next := i - (len(body) - content)
+	value := strings.Join(body[:content], "\n")
+	switch {
+	case content == 0:
+	case chomp == '+':
+		value += strings.Repeat("\n", len(body)-content+1)
+		next = i
+	case chomp == 0:
+		value += "\n"
+	}
+	return value, next, nil
+}
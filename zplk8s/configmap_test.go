@@ -0,0 +1,118 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplk8s
+
+import (
+	"testing"
+)
+
+type brokerConfig struct {
+	Name     string            `zpl:"name"`
+	Bind     []string          `zpl:"bind"`
+	Backends map[string]string `zpl:"backend"`
+}
+
+func TestManifest(t *testing.T) {
+	cfg := brokerConfig{Name: "edge", Bind: []string{"tcp://*:5555", "ipc://x"}, Backends: map[string]string{"a": "tcp://a:1"}}
+	meta := Meta{Name: "broker", Namespace: "prod", Labels: map[string]string{"tier": "edge", "app": "broker"}}
+	out, err := Manifest(meta, "broker.zpl", &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: broker
+  namespace: prod
+  labels:
+    app: "broker"
+    tier: "edge"
+data:
+  broker.zpl: |
+    name = edge
+    bind = tcp://*:5555
+    bind = ipc://x
+    backend
+        a = tcp://a:1
+`
+	if string(out) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	var back brokerConfig
+	if err := Unmarshal(out, "broker.zpl", &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Name != "edge" || len(back.Bind) != 2 || back.Bind[1] != "ipc://x" || back.Backends["a"] != "tcp://a:1" {
+		t.Errorf("round trip gave %+v", back)
+	}
+	if _, err := Manifest(Meta{}, "broker.zpl", &cfg); err == nil {
+		t.Errorf("expected an error for a ConfigMap without a name")
+	}
+	out, err = Manifest(Meta{Name: "true"}, "1", map[string]string{"a": "tab\there"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc, err := Extract(out, "1"); err != nil || string(doc) != "a = tab\there\n" {
+		t.Errorf("unexpected result %q, %v from:\n%s", doc, err, out)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	manifest := `apiVersion: v1
+data:
+  clip.zpl: |
+    a
+        b = 1
+
+    c = 2
+
+  strip: |-
+    x = 1
+  keep: |+
+    x = 1
+
+  indented: |2
+      a = 1
+  plain: x = 1 # comment
+  "quoted key": "x = \"1\"\n"
+  single: 'it''s'
+  empty: ""
+kind: ConfigMap
+metadata:
+  name: broker
+`
+	for key, expected := range map[string]string{
+		"clip.zpl":   "a\n    b = 1\n\nc = 2\n",
+		"strip":      "x = 1",
+		"keep":       "x = 1\n\n",
+		"indented":   "  a = 1\n",
+		"plain":      "x = 1",
+		"quoted key": "x = \"1\"\n",
+		"single":     "it's",
+		"empty":      "",
+	} {
+		if got, err := Extract([]byte(manifest), key); err != nil {
+			t.Errorf("%s: %s", key, err)
+		} else if string(got) != expected {
+			t.Errorf("%s: expected %q, got %q", key, expected, got)
+		}
+	}
+	if _, err := Extract([]byte(manifest), "missing"); err == nil {
+		t.Errorf("expected an error for a missing key")
+	}
+	json := `{"apiVersion": "v1", "kind": "ConfigMap", "data": {"a.zpl": "a = 1\n"}}`
+	if got, err := Extract([]byte(json), "a.zpl"); err != nil || string(got) != "a = 1\n" {
+		t.Errorf("unexpected result %q, %v", got, err)
+	}
+	for _, bad := range []string{
+		"data:\n  a: >\n    folded\n",
+		"data:\n  a: 1\n   b: 2\n",
+		"data:\n  a: \"unterminated\n",
+	} {
+		if _, err := Extract([]byte(bad), "a"); err == nil {
+			t.Errorf("expected an error for:\n%s", bad)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zpllite reads and writes ZPL without package reflect, for programs
+// built with TinyGo, such as agents compiled to WebAssembly, where the
+// reflection that package zpl relies on is unsupported or too costly.
+//
+// It offers a Decoder that produces the parse events of a document, a generic
+// Section tree, and nothing else: Go values are encoded and decoded by the
+// methods that "zplgen -methods -lite" generates, which use this package in
+// place of package zpl.  It depends only on small standard packages, so it
+// builds with "tinygo build" for any target.
+//
+// Documents are parsed as package zpl parses them with its default settings.
+//
+package zpllite
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+)
+
+// An EventType identifies the kind of an Event.
+//
+type EventType int
+
+const (
+	AddValue     EventType = iota // a key = value property
+	EndSection                    // the end of the most recently started section
+	StartSection                  // a section header
+)
+
+// An Event is one step in the parsing of a ZPL document: a property, the
+// start of a section or the end of one.  Sections still open at the end of
+// the document are not explicitly ended.
+//
+type Event struct {
+	Type  EventType
+	Name  string // the key of a property or the name of a section
+	Value string // the value of a property, with any quotes removed
+}
+
+// A SyntaxError is a description of a ZPL syntax error.
+//
+type SyntaxError struct {
+	msg  string // description of error
+	Line uint64 // error occurred on this line
+}
+
+func (e *SyntaxError) Error() string {
+	return strconv.FormatUint(e.Line, 10) + ":" + e.msg
+}
+
+// A Decoder reads the parse events of a ZPL document from an input stream.
+//
+type Decoder struct {
+	r         io.Reader
+	buffer    []byte
+	chunk     []byte // scratch space for reading from r
+	eof       bool   // whether r has been read to the end
+	lineno    uint64
+	prevDepth int
+	queue     []Event // events parsed but not yet returned by Next
+	event     Event   // the event most recently returned by Next
+}
+
+// NewDecoder returns a new decoder that reads from r.
+//
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Next returns the next parse event, or io.EOF when there are no more.  The
+// event is only valid until the following call to Next.
+//
+func (d *Decoder) Next() (*Event, error) {
+	if len(d.queue) == 0 {
+		if err := d.scan(); err != nil {
+			return nil, err
+		}
+	}
+	d.event = d.queue[0]
+	d.queue = d.queue[1:]
+	return &d.event, nil
+}
+
+// Queue the events of the next line that is not blank or a comment.
+func (d *Decoder) scan() error {
+	var line []byte
+	for {
+		var err error
+		if line, err = d.readLine(); err != nil {
+			return err
+		}
+		d.lineno++
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) > 0 && trimmed[0] != '#' {
+			break
+		}
+	}
+	depth, key, value, hasValue, ok := scanLine(line)
+	if !ok {
+		return &SyntaxError{Line: d.lineno, msg: "is neither a comment, a section header, nor a key = value setting."}
+	} else if depth > d.prevDepth {
+		return &SyntaxError{Line: d.lineno, msg: "is indented more than its section allows."}
+	}
+	for ; depth < d.prevDepth; d.prevDepth-- {
+		d.queue = append(d.queue, Event{Type: EndSection})
+	}
+	if hasValue {
+		d.queue = append(d.queue, Event{Type: AddValue, Name: string(key), Value: string(value)})
+	} else {
+		d.queue = append(d.queue, Event{Type: StartSection, Name: string(key)})
+		d.prevDepth++
+	}
+	return nil
+}
+
+// Return the next line of input, without its line ending.  The error is
+// io.EOF only when there are no more lines.
+func (d *Decoder) readLine() (line []byte, err error) {
+	for {
+		if n := bytes.IndexAny(d.buffer, "\n\r"); n >= 0 {
+			line = d.buffer[:n]
+			if n+1 < len(d.buffer) && d.buffer[n] != d.buffer[n+1] &&
+				(d.buffer[n+1] == '\n' || d.buffer[n+1] == '\r') {
+				n++
+			}
+			d.buffer = d.buffer[n+1:]
+			return line, nil
+		}
+		if d.eof {
+			if len(d.buffer) == 0 {
+				return nil, io.EOF
+			}
+			line, d.buffer = d.buffer, nil
+			return line, nil
+		}
+		if d.chunk == nil {
+			d.chunk = make([]byte, 512)
+		}
+		var n int
+		n, err = d.r.Read(d.chunk)
+		d.buffer = append(d.buffer, d.chunk[:n]...)
+		if err == io.EOF {
+			d.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Split a line into its indentation depth, key, and value if it has one, as
+// package zpl does.
+func scanLine(line []byte) (depth int, key, value []byte, hasValue bool, ok bool) {
+	i := 0
+	for i < len(line) && line[i] == ' ' {
+		i++
+	}
+	if i%4 != 0 || i == len(line) || !isAlphanumeric(line[i]) {
+		return
+	}
+	depth = i / 4
+	start := i
+	for i < len(line) && (isAlphanumeric(line[i]) || line[i] == '/') {
+		i++
+	}
+	key = line[start:i]
+	if i == len(line) {
+		ok = true
+		return
+	}
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+	if i == len(line) || line[i] != '=' {
+		return
+	}
+	i++
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+	if i == len(line) {
+		return
+	}
+	value = line[i:]
+	if n := len(value); n >= 2 && value[0] == '"' && value[n-1] == '"' {
+		value = value[1 : n-1]
+	}
+	hasValue, ok = true, true
+	return
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\f', '\r':
+		return true
+	}
+	return false
+}
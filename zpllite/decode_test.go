@@ -0,0 +1,68 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpllite
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+var raw = []byte("# ZDCF\r\nversion = 0.1\r\ncontext\r\n    iothreads = 1\r\n\r\nmain\r\n    type = zmq_queue\r\n" +
+	"    frontend\r\n        option\r\n            hwm = 1000\r\n        bind = \"tcp://eth0:5555\"\r\n" +
+	"    backend\r\n        bind = tcp://eth0:5556\r\n        bind = inproc://device")
+
+// Return the events of src as produced by dec, or the first error.
+func events(next func() (*Event, error)) ([]Event, error) {
+	var evs []Event
+	for {
+		e, err := next()
+		if err == io.EOF {
+			return evs, nil
+		} else if err != nil {
+			return evs, err
+		}
+		evs = append(evs, *e)
+	}
+}
+
+func TestDecoder_Next(t *testing.T) {
+	got, err := events(NewDecoder(iotest.OneByteReader(bytes.NewReader(raw))).Next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected []Event
+	d := zpl.NewDecoder(bytes.NewReader(raw))
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, Event{Type: EventType(e.Type), Name: e.Name, Value: e.Value})
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got events %v, expected %v", got, expected)
+	}
+}
+
+func TestDecoder_SyntaxErrors(t *testing.T) {
+	for src, line := range map[string]uint64{
+		"a = 1\n  b = 2\n":      2,
+		"a = 1\n    b = 2\n":    2,
+		"a\n    b\n        =\n": 3,
+	} {
+		_, err := events(NewDecoder(strings.NewReader(src)).Next)
+		if e, ok := err.(*SyntaxError); !ok || e.Line != line {
+			t.Errorf("%q: expected a syntax error on line %d, got %v", src, line, err)
+		}
+	}
+}
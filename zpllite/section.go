@@ -0,0 +1,155 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpllite
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+)
+
+// A Section is a generic, order-preserving representation of a ZPL section
+// and everything it contains, like zpl.Section.  Sections that appear more
+// than once are merged and repeated properties accumulate their values.
+//
+// The zero value is an empty section ready to use.
+//
+type Section struct {
+	keys     []string // properties and subsections, in order of appearance
+	values   map[string][]string
+	sections map[string]*Section
+}
+
+// Parse parses the ZPL-encoded data and returns it as a Section.  It is an
+// error for a key to be used for both a property and a section within the
+// same section.
+//
+func Parse(src []byte) (*Section, error) {
+	doc := new(Section)
+	stack := []*Section{doc}
+	d := NewDecoder(bytes.NewReader(src))
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			return doc, nil
+		} else if err != nil {
+			return nil, err
+		}
+		s := stack[len(stack)-1]
+		switch e.Type {
+		case AddValue:
+			if s.sections[e.Name] != nil {
+				return nil, conflict(d, e.Name)
+			}
+			s.Add(e.Name, e.Value)
+		case StartSection:
+			if s.HasValue(e.Name) {
+				return nil, conflict(d, e.Name)
+			}
+			stack = append(stack, s.AddSection(e.Name))
+		case EndSection:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+func conflict(d *Decoder, key string) error {
+	return &SyntaxError{Line: d.lineno, msg: "uses \"" + key + "\" as both a property and a section."}
+}
+
+// Keys returns the names of the properties and subsections of s in the order
+// they first appeared.
+func (s *Section) Keys() []string {
+	return s.keys
+}
+
+// Values returns all values of the named property, in order.
+func (s *Section) Values(key string) []string {
+	return s.values[key]
+}
+
+// Value returns the last value of the named property, or "" if there is none.
+func (s *Section) Value(key string) string {
+	if values := s.values[key]; len(values) > 0 {
+		return values[len(values)-1]
+	}
+	return ""
+}
+
+// HasValue reports whether s has at least one value for the named property.
+func (s *Section) HasValue(key string) bool {
+	return len(s.values[key]) > 0
+}
+
+// Section returns the named subsection, or nil if there is none.
+func (s *Section) Section(name string) *Section {
+	return s.sections[name]
+}
+
+// Add appends value to the named property.
+func (s *Section) Add(key string, value string) {
+	if s.values == nil {
+		s.values = make(map[string][]string)
+	}
+	s.touch(key)
+	s.values[key] = append(s.values[key], value)
+}
+
+// AddSection returns the named subsection, creating it if necessary.
+func (s *Section) AddSection(name string) *Section {
+	if s.sections == nil {
+		s.sections = make(map[string]*Section)
+	}
+	s.touch(name)
+	sub, ok := s.sections[name]
+	if !ok {
+		sub = new(Section)
+		s.sections[name] = sub
+	}
+	return sub
+}
+
+// Record the first appearance of name.
+func (s *Section) touch(name string) {
+	if _, ok := s.values[name]; ok {
+		return
+	} else if _, ok := s.sections[name]; ok {
+		return
+	}
+	s.keys = append(s.keys, name)
+}
+
+// MarshalZPL returns the ZPL encoding of s.  Values that parsing would
+// change, such as "" or one with leading spaces, are written in double
+// quotes.  It is an error for a value to contain a line break.
+//
+func (s *Section) MarshalZPL() ([]byte, error) {
+	var buf bytes.Buffer
+	err := s.write(&buf, "")
+	return buf.Bytes(), err
+}
+
+func (s *Section) write(buf *bytes.Buffer, indent string) error {
+	for _, key := range s.keys {
+		if sub := s.sections[key]; sub != nil {
+			buf.WriteString(indent + key + "\n")
+			if err := sub.write(buf, indent+"    "); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, value := range s.values[key] {
+			if strings.ContainsAny(value, "\r\n") {
+				return errors.New("zpllite: value of \"" + key + "\" contains a line break")
+			}
+			if n := len(value); n == 0 || strings.Trim(value, " \t\f") != value || n >= 2 && value[0] == '"' && value[n-1] == '"' {
+				value = "\"" + value + "\""
+			}
+			buf.WriteString(indent + key + " = " + value + "\n")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpllite
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Value("version") != "0.1" || doc.Section("context").Value("iothreads") != "1" {
+		t.Errorf("unexpected document:\n%s", mustMarshal(t, doc))
+	}
+	backend := doc.Section("main").Section("backend")
+	if binds := backend.Values("bind"); len(binds) != 2 || binds[1] != "inproc://device" {
+		t.Errorf("unexpected binds: %v", binds)
+	}
+	expected := `version = 0.1
+context
+    iothreads = 1
+main
+    type = zmq_queue
+    frontend
+        option
+            hwm = 1000
+        bind = tcp://eth0:5555
+    backend
+        bind = tcp://eth0:5556
+        bind = inproc://device
+`
+	if out := mustMarshal(t, doc); out != expected {
+		t.Errorf("unexpected encoding:\n%s", out)
+	}
+	if _, err := Parse([]byte("a = 1\na\n    b = 2\n")); err == nil {
+		t.Errorf("expected an error for a key used as a property and a section")
+	}
+	doc.Section("context").Add("verbose", "a\nb")
+	if _, err := doc.MarshalZPL(); err == nil {
+		t.Errorf("expected an error for a line break")
+	}
+}
+
+func mustMarshal(t *testing.T, s *Section) string {
+	out, err := s.MarshalZPL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestSection_MarshalZPL_Quotes(t *testing.T) {
+	src := "a = \"\"\nb = \"  padded \"\nc = \"\"quoted\"\"\nd = plain\n"
+	doc, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := doc.MarshalZPL()
+	if err != nil || string(out) != src {
+		t.Errorf("unexpected result %q, %v", out, err)
+	}
+}
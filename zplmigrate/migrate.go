@@ -0,0 +1,117 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zplmigrate renames keys and sections throughout ZPL documents, for
+// fleet-wide configuration renames.
+//
+// Renames are given as a map from old to new slash-separated key paths, for
+// example "main/frontend/option/hwm" to "main/frontend/option/sndhwm".  A
+// rename may change only the last element of a path: properties and sections
+// are renamed in place, so comments, blank lines and the order of the document
+// are preserved exactly.
+//
+package zplmigrate
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// A RenameError describes a rename that cannot be performed in place.
+//
+type RenameError struct {
+	Old string
+	New string
+	msg string // description of error
+}
+
+func (e *RenameError) Error() string {
+	return "zplmigrate: cannot rename \"" + e.Old + "\" to \"" + e.New + "\": " + e.msg
+}
+
+// Migrate returns a copy of the ZPL document src in which every property or
+// section whose path is a key in renames is renamed to the corresponding
+// value.  Paths are matched against the original names of enclosing sections,
+// so renaming a section and one of its properties in the same call works as
+// expected.
+//
+func Migrate(src []byte, renames map[string]string) ([]byte, error) {
+	names := make(map[string]string)
+	for old, new := range renames {
+		parent := parentOf(old)
+		if parentOf(new) != parent {
+			return nil, &RenameError{Old: old, New: new, msg: "only the last path element may change"}
+		}
+		name := lastOf(new, parent)
+		if len(name) == 0 || keyLength([]byte(name)) != len(name) {
+			return nil, &RenameError{Old: old, New: new, msg: "invalid key name"}
+		}
+		names[old] = name
+	}
+	if err := zpl.Unmarshal(src, make(map[string]interface{})); err != nil {
+		if _, ok := err.(*zpl.SyntaxError); ok {
+			return nil, err
+		}
+	}
+	var (
+		out   bytes.Buffer
+		stack []string
+	)
+	for _, line := range bytes.SplitAfter(src, []byte("\n")) {
+		content := bytes.TrimRight(line, "\r\n")
+		trimmed := bytes.TrimLeft(content, " ")
+		if len(trimmed) == 0 || trimmed[0] == '#' {
+			out.Write(line)
+			continue
+		}
+		depth := (len(content) - len(trimmed)) / 4
+		if depth > len(stack) {
+			return nil, errors.New("zplmigrate: unexpected indentation")
+		}
+		stack = stack[:depth]
+		n := keyLength(trimmed)
+		key := string(trimmed[:n])
+		path := strings.Join(append(stack, key), "/")
+		if name, ok := names[path]; ok {
+			out.Write(content[:len(content)-len(trimmed)])
+			out.WriteString(name)
+			out.Write(line[len(content)-len(trimmed)+n:])
+		} else {
+			out.Write(line)
+		}
+		stack = append(stack, key)
+	}
+	return out.Bytes(), nil
+}
+
+// Return the length of the ZPL key at the start of line.
+func keyLength(line []byte) int {
+	for i, c := range line {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '/':
+		default:
+			return i
+		}
+	}
+	return len(line)
+}
+
+// Return the path of the section that contains path, or "" for the root.
+func parentOf(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// Return the part of path that follows parent and its separator.
+func lastOf(path string, parent string) string {
+	if parent == "" {
+		return path
+	}
+	return path[len(parent)+1:]
+}
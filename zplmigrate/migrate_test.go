@@ -0,0 +1,65 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplmigrate
+
+import (
+	"testing"
+)
+
+var raw = []byte(`# ZDCF example
+context
+    iothreads = 1
+
+main
+    type = zmq_queue
+    frontend
+        # high water mark
+        option
+            hwm = 1000
+        bind = tcp://eth0:5555
+`)
+
+func TestMigrate(t *testing.T) {
+	out, err := Migrate(raw, map[string]string{
+		"context/iothreads":        "context/io_threads",
+		"main/frontend":            "main/front",
+		"main/frontend/option/hwm": "main/frontend/option/sndhwm",
+		"main/backend":             "main/back",
+	})
+	if _, ok := err.(*RenameError); !ok {
+		t.Fatalf("expected RenameError for invalid key, got %T: %v", err, err)
+	}
+	out, err = Migrate(raw, map[string]string{
+		"context/iothreads":        "context/threads",
+		"main/frontend":            "main/front",
+		"main/frontend/option/hwm": "main/frontend/option/sndhwm",
+		"main/backend":             "main/back",
+	})
+	if err != nil {
+		t.Fatalf("failed to migrate: %s", err)
+	}
+	expect := `# ZDCF example
+context
+    threads = 1
+
+main
+    type = zmq_queue
+    front
+        # high water mark
+        option
+            sndhwm = 1000
+        bind = tcp://eth0:5555
+`
+	if string(out) != expect {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+}
+
+func TestMigrate_RenameError(t *testing.T) {
+	_, err := Migrate(raw, map[string]string{"main/type": "context/type"})
+	if _, ok := err.(*RenameError); !ok {
+		t.Errorf("expected RenameError, got %T: %v", err, err)
+	}
+}
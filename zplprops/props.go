@@ -0,0 +1,249 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zplprops converts between ZPL and the .properties files of Java,
+// for moving the configuration of JVM services to Go.
+//
+// The dot-separated keys of a .properties file correspond to the paths of
+// ZPL properties, so "main.frontend.bind" is the property "bind" in section
+// "frontend" of section "main".  A repeated ZPL property corresponds to a
+// comma-separated list; a comma that belongs to a value is escaped as "\,".
+//
+package zplprops
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// ToZPL converts the .properties file src, which is read as UTF-8 with \u
+// escapes, to ZPL.  Comments are dropped and sections and properties appear
+// in the lexical order of their keys.  Every key must consist of ZPL key
+// names separated by dots.
+//
+func ToZPL(src []byte) ([]byte, error) {
+	m, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := zpl.Unflatten(m)
+	if err != nil {
+		return nil, err
+	}
+	return zpl.Marshal(doc)
+}
+
+// FromZPL converts the ZPL document src to a .properties file with one line
+// per property, sorted by key.  Sections without any properties have no
+// representation in a .properties file and are dropped.
+//
+func FromZPL(src []byte) ([]byte, error) {
+	doc, err := zpl.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	flat := zpl.Flatten(doc)
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for _, key := range keys {
+		buf.WriteString(escape(key, true))
+		buf.WriteByte('=')
+		for i, value := range strings.Split(flat[key], "\n") {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(escape(value, false))
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// Parse a .properties file into values keyed by their dotted keys, with the
+// items of each comma-separated list joined by line feeds as by zpl.Flatten.
+func parse(src []byte) (map[string]string, error) {
+	m := make(map[string]string)
+	lines := strings.Split(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(string(src)), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineno := i + 1
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		for continues(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		rawKey, rawValue := splitKey(line)
+		key, err := unescape(rawKey)
+		if err != nil {
+			return nil, errors.New("zplprops: line " + strconv.Itoa(lineno) + ": " + err.Error())
+		}
+		var items []string
+		for _, item := range splitList(rawValue) {
+			if item, err = unescape(trim(item)); err != nil {
+				return nil, errors.New("zplprops: line " + strconv.Itoa(lineno) + ": " + err.Error())
+			}
+			items = append(items, item)
+		}
+		m[key] = strings.Join(items, "\n")
+	}
+	return m, nil
+}
+
+// Report whether line ends with an odd number of backslashes, which continues
+// it on the next line.
+func continues(line string) bool {
+	n := 0
+	for n < len(line) && line[len(line)-1-n] == '\\' {
+		n++
+	}
+	return n%2 == 1
+}
+
+// Split a logical line into its key and value, both still escaped.  The key
+// ends at the first unescaped '=', ':' or whitespace, and the value starts
+// after any whitespace and at most one '=' or ':' that follow it.
+func splitKey(line string) (key, value string) {
+	i := 0
+	for i < len(line) {
+		c := line[i]
+		if c == '\\' {
+			i += 2
+			continue
+		} else if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+			break
+		}
+		i++
+	}
+	if i > len(line) {
+		i = len(line)
+	}
+	key, value = line[:i], strings.TrimLeft(line[i:], " \t\f")
+	if value != "" && (value[0] == '=' || value[0] == ':') {
+		value = strings.TrimLeft(value[1:], " \t\f")
+	}
+	return
+}
+
+// Remove unescaped whitespace from both ends of an escaped item.
+func trim(item string) string {
+	item = strings.TrimLeft(item, " \t\f")
+	for n := len(item); n > 0 && strings.ContainsRune(" \t\f", rune(item[n-1])) && !continues(item[:n-1]); n-- {
+		item = item[:n-1]
+	}
+	return item
+}
+
+// Split an escaped value at each unescaped comma.
+func splitList(value string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			items = append(items, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, value[start:])
+}
+
+// Replace the escape sequences of a .properties file in s.
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var (
+		buf   strings.Builder
+		units []uint16 // UTF-16 code units from consecutive \u escapes
+	)
+	flush := func() {
+		buf.WriteString(string(utf16.Decode(units)))
+		units = units[:0]
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			flush()
+			buf.WriteByte(s[i])
+			continue
+		}
+		i++
+		if s[i] == 'u' {
+			if i+5 > len(s) {
+				return "", errors.New("malformed \\u escape")
+			}
+			n, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", errors.New("malformed \\u escape")
+			}
+			units = append(units, uint16(n))
+			i += 4
+			continue
+		}
+		flush()
+		switch s[i] {
+		case 't':
+			buf.WriteByte('\t')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 'f':
+			buf.WriteByte('\f')
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+	flush()
+	return buf.String(), nil
+}
+
+// Escape s for use as a key, or as an item of a comma-separated value.
+// Characters outside of printable ASCII are written as \u escapes so that
+// the result can be read as ISO 8859-1, as java.util.Properties does.
+func escape(s string, key bool) string {
+	var buf strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\' || r == ',' && !key:
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == ' ' && (key || i == 0 || i == len(s)-1):
+			buf.WriteString(`\ `)
+		case key && (r == '=' || r == ':' || r == '#' || r == '!'):
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\f':
+			buf.WriteString(`\f`)
+		case r < 0x20 || r > 0x7e:
+			for _, u := range utf16.Encode([]rune{r}) {
+				buf.WriteString(`\u`)
+				hex := strconv.FormatUint(uint64(u), 16)
+				buf.WriteString(strings.Repeat("0", 4-len(hex)) + hex)
+			}
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
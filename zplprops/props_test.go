@@ -0,0 +1,71 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplprops
+
+import (
+	"testing"
+)
+
+func TestToZPL(t *testing.T) {
+	src := []byte(`# service settings
+! another comment
+version = 1
+main.frontend.bind: tcp://eth0:5555, \
+    inproc://device
+main.type zmq_queue
+main.motd = café\, open\ 
+main.empty =
+`)
+	out, err := ToZPL(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `main
+    empty = ""
+    frontend
+        bind = tcp://eth0:5555
+        bind = inproc://device
+    motd = "café, open "
+    type = zmq_queue
+version = 1
+`
+	if string(out) != expected {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	if _, err := ToZPL([]byte("server.max-threads = 4\n")); err == nil {
+		t.Errorf("expected an error for a key that is not valid in ZPL")
+	}
+}
+
+func TestFromZPL(t *testing.T) {
+	src := []byte(`main
+    frontend
+        bind = tcp://eth0:5555
+        bind = inproc://device
+    motd = "café, open "
+    empty = ""
+version = 1
+`)
+	out, err := FromZPL(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `main.empty=
+main.frontend.bind=tcp://eth0:5555,inproc://device
+main.motd=caf\u00e9\, open\ 
+version=1
+`
+	if string(out) != expected {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	back, err := ToZPL(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := FromZPL(back)
+	if err != nil || string(again) != expected {
+		t.Errorf("round trip gave:\n%s", again)
+	}
+}
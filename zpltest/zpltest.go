@@ -0,0 +1,137 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zpltest provides assertions for tests of code that produces ZPL.
+// Rather than comparing strings, they compare documents structurally as
+// zpl.Equal does and report each property that differs:
+//
+//     func TestConfig(t *testing.T) {
+//         zpltest.AssertEquivalent(t, "main\n    type = zmq_queue\n", newConfig())
+//     }
+//
+package zpltest
+
+import (
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+var update = flag.Bool("zpltest.update", false, "rewrite golden files with the values under test")
+
+// AssertEquivalent reports a test error unless got is equivalent to the ZPL
+// document want.  If got is a string or []byte it is parsed as ZPL, and
+// otherwise it is marshalled first.  The report lists every property whose
+// values differ and every section present in only one document.  It returns
+// whether the documents are equivalent.
+//
+func AssertEquivalent(t testing.TB, want string, got interface{}) bool {
+	t.Helper()
+	wantDoc, err := zpl.Parse([]byte(want))
+	if err != nil {
+		t.Errorf("zpltest: invalid expected document: %s", err)
+		return false
+	}
+	gotDoc, err := toSection(got)
+	if err != nil {
+		t.Errorf("zpltest: %s", err)
+		return false
+	}
+	report := compare(wantDoc, gotDoc)
+	if report != "" {
+		t.Errorf("zpltest: documents differ:\n%s", report)
+		return false
+	}
+	return true
+}
+
+// AssertGolden is like AssertEquivalent but reads the expected document from
+// the named file, conventionally in the package's testdata directory.  When
+// tests are run with the -zpltest.update flag, it instead writes got to the
+// file, marshalled if necessary, so that golden files can be regenerated
+// after an intended change.
+//
+func AssertGolden(t testing.TB, filename string, got interface{}) bool {
+	t.Helper()
+	if *update {
+		data, err := toBytes(got)
+		if err == nil {
+			err = ioutil.WriteFile(filename, data, 0666)
+		}
+		if err != nil {
+			t.Errorf("zpltest: %s", err)
+			return false
+		}
+		return true
+	}
+	want, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Errorf("zpltest: %s", err)
+		return false
+	}
+	return AssertEquivalent(t, string(want), got)
+}
+
+func toBytes(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	}
+	return zpl.Marshal(v)
+}
+
+func toSection(v interface{}) (*zpl.Section, error) {
+	data, err := toBytes(v)
+	if err != nil {
+		return nil, err
+	}
+	return zpl.Parse(data)
+}
+
+// Describe the differences between two documents, one per line, or return
+// "" if they are equivalent.
+func compare(want, got *zpl.Section) string {
+	var lines []string
+	for _, c := range zpl.Diff(want, got) {
+		switch {
+		case len(c.Old) == 0:
+			lines = append(lines, "  "+c.Path+": unexpected "+quote(c.New))
+		case len(c.New) == 0:
+			lines = append(lines, "  "+c.Path+": missing, want "+quote(c.Old))
+		default:
+			lines = append(lines, "  "+c.Path+": got "+quote(c.New)+", want "+quote(c.Old))
+		}
+	}
+	lines = append(lines, compareSections(want, got, "")...)
+	return strings.Join(lines, "\n")
+}
+
+// Describe the sections present in only one of want and got.
+func compareSections(want, got *zpl.Section, path string) []string {
+	var lines []string
+	for _, name := range want.Keys() {
+		if sub := want.Section(name); sub != nil {
+			if other := got.Section(name); other == nil {
+				lines = append(lines, "  "+path+name+": missing section")
+			} else {
+				lines = append(lines, compareSections(sub, other, path+name+"/")...)
+			}
+		}
+	}
+	for _, name := range got.Keys() {
+		if got.Section(name) != nil && want.Section(name) == nil {
+			lines = append(lines, "  "+path+name+": unexpected section")
+		}
+	}
+	return lines
+}
+
+func quote(values []string) string {
+	return `"` + strings.Join(values, `", "`) + `"`
+}
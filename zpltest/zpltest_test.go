@@ -0,0 +1,69 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zpltest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A recorder collects the errors reported through it instead of failing.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+type device struct {
+	Type string   `zpl:"type"`
+	Bind []string `zpl:"bind"`
+}
+
+func TestAssertEquivalent(t *testing.T) {
+	r := &recorder{TB: t}
+	want := "main\n    type = zmq_queue\n    bind = a\n    bind = b\n"
+	if !AssertEquivalent(r, want, map[string]*device{"main": {Type: "zmq_queue", Bind: []string{"a", "b"}}}) {
+		t.Errorf("unexpected errors: %q", r.errors)
+	}
+	r.errors = nil
+	got := "# comment\nmain\n    bind = b\n    type = zmq_streamer\n    hwm = 1\nextra\n"
+	if AssertEquivalent(r, want, got) {
+		t.Fatalf("expected documents to differ")
+	}
+	expected := `zpltest: documents differ:
+  main/bind: got "b", want "a", "b"
+  main/hwm: unexpected "1"
+  main/type: got "zmq_streamer", want "zmq_queue"
+  extra: unexpected section`
+	if len(r.errors) != 1 || r.errors[0] != expected {
+		t.Errorf("unexpected report: %q", r.errors)
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zpltest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	golden := filepath.Join(dir, "device.zpl")
+	v := &device{Type: "zmq_queue", Bind: []string{"a"}}
+	*update = true
+	AssertGolden(t, golden, v)
+	*update = false
+	AssertGolden(t, golden, v)
+	r := &recorder{TB: t}
+	if AssertGolden(r, golden, &device{Type: "zmq_queue"}) || len(r.errors) != 1 {
+		t.Errorf("expected a difference, got %q", r.errors)
+	}
+}
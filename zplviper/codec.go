@@ -0,0 +1,44 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zplviper adapts go-zpl to the codec interfaces of
+// github.com/spf13/viper, so that ZPL files can be used wherever viper
+// accepts JSON, YAML or TOML:
+//
+//     registry := viper.NewCodecRegistry()
+//     registry.RegisterCodec("zpl", zplviper.Codec{})
+//     v := viper.NewWithOptions(viper.WithCodecRegistry(registry))
+//     v.SetConfigType("zpl")
+//
+// This package does not import viper: Codec satisfies viper's Encoder,
+// Decoder and Codec interfaces structurally.
+//
+package zplviper
+
+import (
+	"bytes"
+
+	"github.com/jtacoma/go-zpl"
+)
+
+// Codec encodes and decodes the map[string]interface{} trees used by viper.
+//
+// Properties that occur once decode as strings and repeated properties
+// decode as []string, which matches what viper expects from other formats.
+//
+type Codec struct{}
+
+// Encode returns the ZPL encoding of v.
+//
+func (Codec) Encode(v map[string]interface{}) ([]byte, error) {
+	return zpl.Marshal(v)
+}
+
+// Decode parses the ZPL-encoded data in b and stores the result in v.
+//
+func (Codec) Decode(b []byte, v map[string]interface{}) error {
+	d := zpl.NewDecoder(bytes.NewReader(b))
+	d.SetInterfaceMode(zpl.InterfaceStrings)
+	return d.Decode(v)
+}
@@ -0,0 +1,31 @@
+// Copyright 2013 Joshua Tacoma. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zplviper
+
+import (
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	var c Codec
+	m := make(map[string]interface{})
+	if err := c.Decode([]byte("version = 1\nmain\n    bind = a\n    bind = b"), m); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if m["version"] != "1" {
+		t.Errorf("version = %#v", m["version"])
+	}
+	main := m["main"].(map[string]interface{})
+	if bind, ok := main["bind"].([]string); !ok || len(bind) != 2 {
+		t.Errorf("main/bind = %#v", main["bind"])
+	}
+	out, err := c.Encode(map[string]interface{}{"main": map[string]interface{}{"type": "zmq_queue"}})
+	if err != nil {
+		t.Fatalf("failed to encode: %s", err)
+	}
+	if string(out) != "main\n    type = zmq_queue\n" {
+		t.Errorf("unexpected result: %s", out)
+	}
+}